@@ -0,0 +1,71 @@
+package encrypt
+
+import (
+	"io"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+)
+
+const (
+	name            = "encrypt"
+	description     = "Encrypt secret values with installation Vault."
+	longDescription = `Encrypt secret values with installation Vault.
+
+Without --file a single value is read from stdin and its ciphertext is
+written to stdout. A single trailing newline of the value is removed.
+
+	echo "password" | config-controller encrypt --installation gauss
+
+With --file all plaintext values of the YAML file are encrypted and the file
+is rewritten in place. Values which are already encrypted and secretRef
+objects are left untouched.
+
+	config-controller encrypt --installation gauss --file installations/gauss/secret.yaml
+`
+)
+
+type Config struct {
+	Logger micrologger.Logger
+	Stderr io.Writer
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.Stdin == nil {
+		config.Stdin = os.Stdin
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+
+	f := &flag{}
+
+	r := &runner{
+		flag:   f,
+		logger: config.Logger,
+		stderr: config.Stderr,
+		stdin:  config.Stdin,
+		stdout: config.Stdout,
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long:  longDescription,
+		RunE:  r.Run,
+	}
+
+	f.Init(c)
+
+	return c, nil
+}
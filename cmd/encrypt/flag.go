@@ -0,0 +1,55 @@
+package encrypt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagFile              = "file"
+	flagGithubToken       = "github-token"
+	flagInstallation      = "installation"
+	flagSSHUser           = "ssh-user"
+	flagVaultKeyName      = "vault-key-name"
+	flagVaultKeyPrefix    = "vault-key-prefix"
+	flagVaultTransitMount = "vault-transit-mount"
+
+	envConfigControllerGithubToken = "CONFIG_CONTROLLER_GITHUB_TOKEN" //nolint:gosec
+)
+
+type flag struct {
+	File              string
+	GitHubToken       string
+	Installation      string
+	SSHUser           string
+	VaultKeyName      string
+	VaultKeyPrefix    bool
+	VaultTransitMount string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.File, flagFile, "", `Path to a YAML file (e.g. "installations/gauss/secret.yaml") to encrypt in place. When empty, a single value is read from stdin.`)
+	cmd.Flags().StringVar(&f.GitHubToken, flagGithubToken, "", fmt.Sprintf(`GitHub token to use for "opsctl create vaultconfig" calls. Defaults to the value of %s env var.`, envConfigControllerGithubToken))
+	cmd.Flags().StringVar(&f.Installation, flagInstallation, "", `Installation codename (e.g. "gauss").`)
+	cmd.Flags().StringVar(&f.SSHUser, flagSSHUser, "", `User to be passed to opsctl.`)
+	cmd.Flags().StringVar(&f.VaultKeyName, flagVaultKeyName, "config", `Vault transit key used to encrypt values.`)
+	cmd.Flags().BoolVar(&f.VaultKeyPrefix, flagVaultKeyPrefix, false, fmt.Sprintf(`Prefix ciphertexts with the name of --%s (e.g. "vault:payments:v1:"), so they are decrypted with that key regardless of the installation default key.`, flagVaultKeyName))
+	cmd.Flags().StringVar(&f.VaultTransitMount, flagVaultTransitMount, "transit", `Mount path of the Vault transit secrets engine.`)
+}
+
+func (f *flag) Validate() error {
+	if f.GitHubToken == "" {
+		f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
+	}
+	if f.GitHubToken == "" {
+		return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty", flagGithubToken, envConfigControllerGithubToken)
+	}
+	if f.Installation == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagInstallation)
+	}
+
+	return nil
+}
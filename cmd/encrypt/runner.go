@@ -0,0 +1,120 @@
+package encrypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/internal/opsctl"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+type runner struct {
+	flag   *flag
+	logger micrologger.Logger
+	stderr io.Writer
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	vaultClient, err := opsctl.NewVaultClient(ctx, r.flag.GitHubToken, r.flag.SSHUser, r.flag.Installation)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var encrypter *decrypt.VaultEncrypter
+	{
+		c := decrypt.VaultEncrypterConfig{
+			KeyName:      r.flag.VaultKeyName,
+			KeyPrefix:    r.flag.VaultKeyPrefix,
+			TransitMount: r.flag.VaultTransitMount,
+			VaultClient:  vaultClient,
+		}
+
+		encrypter, err = decrypt.NewVaultEncrypter(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if r.flag.File != "" {
+		return r.encryptFile(ctx, encrypter)
+	}
+
+	plaintext, err := ioutil.ReadAll(r.stdin)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	value := strings.TrimSuffix(string(plaintext), "\n")
+
+	ciphertext, err := encrypter.Encrypt(ctx, []byte(value))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintf(r.stdout, "%s\n", ciphertext)
+
+	return nil
+}
+
+func (r *runner) encryptFile(ctx context.Context, encrypter decrypt.Encrypter) error {
+	info, err := os.Stat(r.flag.File)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	data, err := ioutil.ReadFile(r.flag.File)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var yamlEncrypter *decrypt.YAMLEncrypter
+	{
+		c := decrypt.YAMLEncrypterConfig{
+			Encrypter: encrypter,
+		}
+
+		yamlEncrypter, err = decrypt.NewYAMLEncrypter(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	encrypted, err := yamlEncrypter.Encrypt(ctx, data)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = ioutil.WriteFile(r.flag.File, encrypted, info.Mode())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintf(r.stderr, "Encrypted %#q\n", r.flag.File)
+
+	return nil
+}
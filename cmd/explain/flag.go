@@ -0,0 +1,83 @@
+package explain
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/pkg/github"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
+)
+
+const (
+	flagApp                = "app"
+	flagConfigVersion      = "config-version"
+	flagGithubToken        = "github-token"
+	flagInstallation       = "installation"
+	flagLocalPath          = "local-path"
+	flagPath               = "path"
+	flagRepositoryPassword = "repository-password"
+	flagRepositoryURL      = "repository-url"
+	flagRepositoryUsername = "repository-username"
+
+	envConfigControllerGithubToken        = "CONFIG_CONTROLLER_GITHUB_TOKEN"        //nolint:gosec
+	envConfigControllerRepositoryPassword = "CONFIG_CONTROLLER_REPOSITORY_PASSWORD" //nolint:gosec
+)
+
+type flag struct {
+	App                string
+	ConfigVersion      string
+	GitHubToken        string
+	Installation       string
+	LocalPath          string
+	Path               string
+	RepositoryPassword string
+	RepositoryURL      string
+	RepositoryUsername string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.App, flagApp, "", `Name of an application to explain the config for (e.g. "kvm-operator").`)
+	cmd.Flags().StringVar(&f.ConfigVersion, flagConfigVersion, "", `Configuration version. Can be a branch name, an exact version (e.g. "2.3.1"), a range (e.g. "2.x.x", "2.3.x", "~2.3" or ">=2.3.0 <3.0.0") or a prerelease range (e.g. "3.0.0-rc.x").`)
+	cmd.Flags().StringVar(&f.GitHubToken, flagGithubToken, "", fmt.Sprintf(`GitHub token used to fetch the config repository. Defaults to the value of %s env var.`, envConfigControllerGithubToken))
+	cmd.Flags().StringVar(&f.Installation, flagInstallation, "", `Installation codename (e.g. "gauss").`)
+	cmd.Flags().StringVar(&f.LocalPath, flagLocalPath, "", `Path to a local copy of the config repository. When set, configuration is read from the directory instead of GitHub.`)
+	cmd.Flags().StringVar(&f.Path, flagPath, "", `Path of the generated value to explain (e.g. "image.registry" or "hosts.[0].name").`)
+	cmd.Flags().StringVar(&f.RepositoryPassword, flagRepositoryPassword, "", fmt.Sprintf(`Password or token used to fetch the config repository over HTTPS when it is not hosted on GitHub. Defaults to the value of %s env var.`, envConfigControllerRepositoryPassword))
+	cmd.Flags().StringVar(&f.RepositoryURL, flagRepositoryURL, github.DefaultURL, `URL of the config repository. Any git remote is supported (e.g. GitLab, Gitea, SSH or a local git daemon).`)
+	cmd.Flags().StringVar(&f.RepositoryUsername, flagRepositoryUsername, "", fmt.Sprintf(`User name used with --%s.`, flagRepositoryPassword))
+}
+
+func (f *flag) Validate() error {
+	if f.App == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagApp)
+	}
+	if f.ConfigVersion == "" && f.LocalPath == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s is not set", flagConfigVersion, flagLocalPath)
+	}
+	if _, err := versionspec.Parse(f.ConfigVersion); err != nil {
+		return microerror.Maskf(invalidFlagError, "--%s is invalid: %s", flagConfigVersion, err)
+	}
+	if f.GitHubToken == "" {
+		f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
+	}
+	if f.GitHubToken == "" && f.LocalPath == "" && github.IsGitHubURL(f.RepositoryURL) {
+		return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty when --%s is not set and --%s is a GitHub repository", flagGithubToken, envConfigControllerGithubToken, flagLocalPath, flagRepositoryURL)
+	}
+	if f.RepositoryPassword == "" {
+		f.RepositoryPassword = os.Getenv(envConfigControllerRepositoryPassword)
+	}
+	if f.RepositoryPassword != "" && f.RepositoryUsername == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s or $%s is set", flagRepositoryUsername, flagRepositoryPassword, envConfigControllerRepositoryPassword)
+	}
+	if f.Installation == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagInstallation)
+	}
+	if f.Path == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagPath)
+	}
+
+	return nil
+}
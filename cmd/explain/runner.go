@@ -0,0 +1,98 @@
+package explain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/internal/generator"
+)
+
+type runner struct {
+	flag   *flag
+	logger micrologger.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	var err error
+
+	// Secret values are masked in the output so there is no need to
+	// decrypt them.
+	var gen *generator.Service
+	{
+		c := generator.Config{
+			GitHubToken:        r.flag.GitHubToken,
+			Installation:       r.flag.Installation,
+			LocalPath:          r.flag.LocalPath,
+			RepositoryPassword: r.flag.RepositoryPassword,
+			RepositoryURL:      r.flag.RepositoryURL,
+			RepositoryUsername: r.flag.RepositoryUsername,
+			SkipDecryption:     true,
+		}
+
+		gen, err = generator.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	in := generator.ExplainInput{
+		App:           r.flag.App,
+		ConfigVersion: r.flag.ConfigVersion,
+		Path:          r.flag.Path,
+	}
+
+	explanation, err := gen.Explain(ctx, in)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	file := "configmap-values.yaml"
+	if explanation.Secret {
+		file = "secret-values.yaml"
+	}
+
+	fmt.Fprintf(r.stdout, "Path %#q in %s of %#q app for %#q installation\n", explanation.Path, file, r.flag.App, r.flag.Installation)
+
+	for i, step := range explanation.Steps {
+		fmt.Fprintf(r.stdout, "\n%d. %s (%s)\n", i+1, step.Filepath, step.Layer)
+		if step.Snippet != "" {
+			fmt.Fprintln(r.stdout, indent(step.Snippet, "   "))
+		}
+	}
+
+	fmt.Fprintf(r.stdout, "\nFinal value:\n%s\n", indent(explanation.Value, "   "))
+
+	return nil
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
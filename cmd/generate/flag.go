@@ -6,59 +6,130 @@ import (
 
 	"github.com/giantswarm/microerror"
 	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/internal/generator"
+	"github.com/giantswarm/config-controller/pkg/github"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 )
 
 const (
-	flagApp           = "app"
-	flagConfigVersion = "config-version"
-	flagGithubToken   = "github-token"
-	flagInstallation  = "installation"
-	flagName          = "name"
-	flagNamespace     = "namespace"
-	flagRaw           = "raw"
-	flagSSHUser       = "ssh-user"
-	flagVerbose       = "verbose"
+	flagAgeKeyFile         = "age-key-file"
+	flagAllApps            = "all-apps"
+	flagApp                = "app"
+	flagConfigVersion      = "config-version"
+	flagDecryptionBackend  = "decryption-backend"
+	flagGithubToken        = "github-token"
+	flagInstallation       = "installation"
+	flagKubeconfig         = "kubeconfig"
+	flagLocalPath          = "local-path"
+	flagName               = "name"
+	flagNamespace          = "namespace"
+	flagRaw                = "raw"
+	flagRepositoryPassword = "repository-password"
+	flagRepositoryURL      = "repository-url"
+	flagRepositoryUsername = "repository-username"
+	flagSecretRefAllowed   = "secret-ref-allowed"
+	flagSSHUser            = "ssh-user"
+	flagTrace              = "trace"
+	flagVaultKeyName       = "vault-key-name"
+	flagVaultTransitMount  = "vault-transit-mount"
+	flagVerbose            = "verbose"
 
-	envConfigControllerGithubToken = "CONFIG_CONTROLLER_GITHUB_TOKEN" //nolint:gosec
+	envConfigControllerGithubToken        = "CONFIG_CONTROLLER_GITHUB_TOKEN"        //nolint:gosec
+	envConfigControllerRepositoryPassword = "CONFIG_CONTROLLER_REPOSITORY_PASSWORD" //nolint:gosec
+	envConfigControllerAgeKeyFile         = "CONFIG_CONTROLLER_AGE_KEY_FILE"
 )
 
 type flag struct {
-	App           string
-	ConfigVersion string
-	GitHubToken   string
-	Installation  string
-	Name          string
-	Namespace     string
-	Raw           bool
-	SSHUser       string
-	Verbose       bool
+	AgeKeyFile         string
+	AllApps            bool
+	App                string
+	ConfigVersion      string
+	DecryptionBackend  string
+	GitHubToken        string
+	Installation       string
+	Kubeconfig         string
+	LocalPath          string
+	Name               string
+	Namespace          string
+	Raw                bool
+	RepositoryPassword string
+	RepositoryURL      string
+	RepositoryUsername string
+	SecretRefAllowed   []string
+	SSHUser            string
+	Trace              bool
+	VaultKeyName       string
+	VaultTransitMount  string
+	Verbose            bool
 }
 
 func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.AgeKeyFile, flagAgeKeyFile, "", fmt.Sprintf(`Path to the age identities file used when --%s is %q. Defaults to the value of %s env var.`, flagDecryptionBackend, generator.DecryptionBackendAge, envConfigControllerAgeKeyFile))
+	cmd.Flags().BoolVar(&f.AllApps, flagAllApps, false, fmt.Sprintf(`Generate the config for all applications. Generated ConfigMaps/Secrets are named after applications and --%s is ignored.`, flagName))
 	cmd.Flags().StringVar(&f.App, flagApp, "", `Name of an application to generate the config for (e.g. "kvm-operator").`)
-	cmd.Flags().StringVar(&f.ConfigVersion, flagConfigVersion, "", `Configuration version. Can be a major version range in format "2.x.x" or a branch name.`)
+	cmd.Flags().StringVar(&f.ConfigVersion, flagConfigVersion, "", `Configuration version. Can be a branch name, an exact version (e.g. "2.3.1"), a range (e.g. "2.x.x", "2.3.x", "~2.3" or ">=2.3.0 <3.0.0") or a prerelease range (e.g. "3.0.0-rc.x").`)
+	cmd.Flags().StringVar(&f.DecryptionBackend, flagDecryptionBackend, generator.DecryptionBackendVault, fmt.Sprintf(`Backend used to decrypt secret values of the installation. One of %q. Vault access is set up with opsctl only for %q. %q decrypts values which are separate age files, not SOPS encrypted files.`, generator.DecryptionBackends, generator.DecryptionBackendVault, generator.DecryptionBackendAge))
 	cmd.Flags().StringVar(&f.GitHubToken, flagGithubToken, "", fmt.Sprintf(`GitHub token to use for "opsctl create vaultconfig" calls. Defaults to the value of %s env var.`, envConfigControllerGithubToken))
 	cmd.Flags().StringVar(&f.Installation, flagInstallation, "", `Installation codename (e.g. "gauss").`)
+	cmd.Flags().StringVar(&f.Kubeconfig, flagKubeconfig, "", `Path to the kubeconfig of the management cluster used to resolve secretRef objects of the installation secret. Defaults to $KUBECONFIG or ~/.kube/config. When none of them exists, placeholders are generated instead of the referenced values.`)
+	cmd.Flags().StringVar(&f.LocalPath, flagLocalPath, "", `Path to a local copy of the config repository. When set, configuration is read from the directory instead of GitHub.`)
 	cmd.Flags().StringVar(&f.Name, flagName, "giantswarm", `Name of the generated ConfigMap/Secret.`)
 	cmd.Flags().StringVar(&f.Namespace, flagNamespace, "giantswarm", `Namespace of the generated ConfigMap/Secret.`)
 	cmd.Flags().BoolVar(&f.Raw, flagRaw, false, `Forces generator to output YAML instead of ConfigMap & Secret.`)
+	cmd.Flags().StringVar(&f.RepositoryPassword, flagRepositoryPassword, "", fmt.Sprintf(`Password or token used to fetch the config repository over HTTPS when it is not hosted on GitHub. Defaults to the value of %s env var.`, envConfigControllerRepositoryPassword))
+	cmd.Flags().StringVar(&f.RepositoryURL, flagRepositoryURL, github.DefaultURL, `URL of the config repository. Any git remote is supported (e.g. GitLab, Gitea, SSH or a local git daemon).`)
+	cmd.Flags().StringVar(&f.RepositoryUsername, flagRepositoryUsername, "", fmt.Sprintf(`User name used with --%s.`, flagRepositoryPassword))
+	cmd.Flags().StringSliceVar(&f.SecretRefAllowed, flagSecretRefAllowed, []string{}, fmt.Sprintf(`Kubernetes Secrets which secretRef objects may reference when they are resolved with --%s. Entries are either "<namespace>" or "<namespace>/<name>". Use the same list as the controller of the installation.`, flagKubeconfig))
 	cmd.Flags().StringVar(&f.SSHUser, flagSSHUser, "", `User to be passed to opsctl.`)
+	cmd.Flags().BoolVar(&f.Trace, flagTrace, false, `Writes intermediate YAML of each generation step to stderr. WARNING: the output contains decrypted secret values.`)
+	cmd.Flags().StringVar(&f.VaultKeyName, flagVaultKeyName, "config", `Vault transit key used for secret values without key name prefix extension. Values with "vault:<key name>:v<key version>:" prefix use the named key.`)
+	cmd.Flags().StringVar(&f.VaultTransitMount, flagVaultTransitMount, "transit", `Mount path of the Vault transit secrets engine.`)
 	cmd.Flags().BoolVar(&f.Verbose, flagVerbose, false, `Enables generator to output consecutive generation stages.`)
 }
 
 func (f *flag) Validate() error {
-	if f.App == "" {
-		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagApp)
+	if f.App == "" && !f.AllApps {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s is not set", flagApp, flagAllApps)
+	}
+	if f.App != "" && f.AllApps {
+		return microerror.Maskf(invalidFlagError, "--%s and --%s are mutually exclusive", flagApp, flagAllApps)
+	}
+	if f.Trace && f.Verbose {
+		return microerror.Maskf(invalidFlagError, "--%s and --%s are mutually exclusive", flagTrace, flagVerbose)
+	}
+	if f.ConfigVersion == "" && f.LocalPath == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s is not set", flagConfigVersion, flagLocalPath)
 	}
-	if f.ConfigVersion == "" {
-		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagConfigVersion)
+	if _, err := versionspec.Parse(f.ConfigVersion); err != nil {
+		return microerror.Maskf(invalidFlagError, "--%s is invalid: %s", flagConfigVersion, err)
+	}
+	switch f.DecryptionBackend {
+	case generator.DecryptionBackendVault:
+	case generator.DecryptionBackendAge:
+		if f.AgeKeyFile == "" {
+			f.AgeKeyFile = os.Getenv(envConfigControllerAgeKeyFile)
+		}
+		if f.AgeKeyFile == "" {
+			return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty when --%s is %q", flagAgeKeyFile, envConfigControllerAgeKeyFile, flagDecryptionBackend, generator.DecryptionBackendAge)
+		}
+	default:
+		return microerror.Maskf(invalidFlagError, "--%s must be one of %q", flagDecryptionBackend, generator.DecryptionBackends)
 	}
 	if f.GitHubToken == "" {
 		f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
 	}
-	if f.GitHubToken == "" {
+	// GitHub token is used to fetch the config repository from GitHub and
+	// to set up Vault access with opsctl.
+	if f.GitHubToken == "" && ((f.LocalPath == "" && github.IsGitHubURL(f.RepositoryURL)) || f.DecryptionBackend == generator.DecryptionBackendVault) {
 		return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty", flagGithubToken, envConfigControllerGithubToken)
 	}
+	if f.RepositoryPassword == "" {
+		f.RepositoryPassword = os.Getenv(envConfigControllerRepositoryPassword)
+	}
+	if f.RepositoryPassword != "" && f.RepositoryUsername == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s or $%s is set", flagRepositoryUsername, flagRepositoryPassword, envConfigControllerRepositoryPassword)
+	}
 	if f.Installation == "" {
 		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagInstallation)
 	}
@@ -4,15 +4,23 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/giantswarm/config-controller/internal/generator"
 	"github.com/giantswarm/config-controller/internal/meta"
+	"github.com/giantswarm/config-controller/internal/opsctl"
+	pkggenerator "github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/generator/tracer"
+	"github.com/giantswarm/config-controller/pkg/secretref"
 )
 
 type runner struct {
@@ -42,21 +50,57 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 	var err error
 
 	var vaultClient *vaultapi.Client
-	{
-		vaultClient, err = createVaultClientUsingOpsctl(ctx, r.flag.GitHubToken, r.flag.SSHUser, r.flag.Installation)
+	if r.flag.DecryptionBackend == generator.DecryptionBackendVault {
+		vaultClient, err = opsctl.NewVaultClient(ctx, r.flag.GitHubToken, r.flag.SSHUser, r.flag.Installation)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var genTracer pkggenerator.Tracer
+	if r.flag.Trace {
+		c := tracer.YAMLDumpConfig{
+			Writer: r.stderr,
+		}
+
+		genTracer, err = tracer.NewYAMLDump(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else if r.flag.Verbose {
+		c := tracer.LoggerConfig{
+			Logger: r.logger,
+		}
+
+		genTracer, err = tracer.NewLogger(c)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 	}
 
+	secretRefResolver, err := r.newSecretRefResolver()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	var gen *generator.Service
 	{
 		c := generator.Config{
-			VaultClient: vaultClient,
-
-			GitHubToken:  r.flag.GitHubToken,
-			Installation: r.flag.Installation,
-			Verbose:      r.flag.Verbose,
+			VaultClient:       vaultClient,
+			VaultKeyName:      r.flag.VaultKeyName,
+			VaultTransitMount: r.flag.VaultTransitMount,
+
+			AgeKeyFile:        r.flag.AgeKeyFile,
+			DecryptionBackend: r.flag.DecryptionBackend,
+
+			GitHubToken:        r.flag.GitHubToken,
+			Installation:       r.flag.Installation,
+			LocalPath:          r.flag.LocalPath,
+			RepositoryPassword: r.flag.RepositoryPassword,
+			RepositoryURL:      r.flag.RepositoryURL,
+			RepositoryUsername: r.flag.RepositoryUsername,
+			SecretRefResolver:  secretRefResolver,
+			Tracer:             genTracer,
 		}
 
 		gen, err = generator.New(c)
@@ -65,6 +109,10 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		}
 	}
 
+	if r.flag.AllApps {
+		return r.generateAll(ctx, gen)
+	}
+
 	in := generator.GenerateInput{
 		App:           r.flag.App,
 		ConfigVersion: r.flag.ConfigVersion,
@@ -87,10 +135,104 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		return microerror.Mask(err)
 	}
 
+	err = r.print(configmap, secret, "")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) generateAll(ctx context.Context, gen *generator.Service) error {
+	in := generator.GenerateAllInput{
+		ConfigVersion: r.flag.ConfigVersion,
+
+		Namespace: r.flag.Namespace,
+
+		ExtraAnnotations: map[string]string{
+			meta.Annotation.ConfigVersion.Key():   r.flag.ConfigVersion,
+			meta.Annotation.XCreator.Key():        meta.Annotation.XCreator.Default(),
+			meta.Annotation.XInstallation.Key():   r.flag.Installation,
+			meta.Annotation.XProjectVersion.Key(): meta.Annotation.XProjectVersion.Val(false),
+		},
+		ExtraLabels: nil,
+	}
+
+	results, err := gen.GenerateAll(ctx, in)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(r.stderr, "Failed to generate config for app %#q: %s\n", result.App, result.Err)
+			failed = append(failed, result.App)
+			continue
+		}
+
+		appInfo := meta.Annotation.XAppInfo.Val("<unknown>", result.App, "<unknown>")
+		result.ConfigMap.Annotations[meta.Annotation.XAppInfo.Key()] = appInfo
+		result.Secret.Annotations[meta.Annotation.XAppInfo.Key()] = appInfo
+
+		err = r.print(result.ConfigMap, result.Secret, result.App)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return microerror.Maskf(executionFailedError, "failed to generate config for apps %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// newSecretRefResolver returns a resolver reading Kubernetes Secrets with the
+// kubeconfig set with --kubeconfig or found in the default locations. When
+// there is no kubeconfig, secretRef objects are resolved to placeholders.
+func (r *runner) newSecretRefResolver() (pkggenerator.SecretRefResolver, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = r.flag.Kubeconfig
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if clientcmd.IsEmptyConfig(err) && r.flag.Kubeconfig == "" {
+		fmt.Fprintf(r.stderr, "No kubeconfig found, secretRef values are replaced with placeholders\n")
+		return pkggenerator.PlaceholderSecretRefResolver{}, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	c := secretref.Config{
+		K8sClient: k8sClient,
+		Allowed:   r.flag.SecretRefAllowed,
+	}
+
+	resolver, err := secretref.New(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return resolver, nil
+}
+
+// print writes generated objects to stdout. In raw mode, YAML documents are
+// annotated with the app name when it is not empty.
+func (r *runner) print(configmap *corev1.ConfigMap, secret *corev1.Secret, app string) error {
 	if r.flag.Raw {
-		fmt.Println("---")
+		var comment string
+		if app != "" {
+			comment = " # " + app
+		}
+
+		fmt.Println("---" + comment)
 		fmt.Printf(string(configmap.Data["configmap-values.yaml"]) + "\n")
-		fmt.Println("---")
+		fmt.Println("---" + comment)
 		fmt.Printf(string(secret.Data["secret-values.yaml"]) + "\n")
 		return nil
 	}
@@ -8,55 +8,93 @@ import (
 
 	"github.com/giantswarm/microerror"
 	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/pkg/github"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 )
 
 const (
-	flagBranch           = "branch"
-	flagConfigVersion    = "config-version"
-	flagFilterFunctions  = "filter-functions"
-	flagGithubToken      = "github-token"
-	flagMaxMessages      = "max-messages"
-	flagNoDescriptions   = "no-descriptions"
-	flagNoFuncNames      = "no-function-names"
-	flagOnlyErrors       = "only-errors"
-	flagSkipFieldsRegexp = "skip-fields-regexp"
+	flagBranch             = "branch"
+	flagConfigVersion      = "config-version"
+	flagFilterFunctions    = "filter-functions"
+	flagGithubToken        = "github-token"
+	flagLocalPath          = "local-path"
+	flagMaxMessages        = "max-messages"
+	flagMinKeyVersion      = "min-key-version"
+	flagNoDescriptions     = "no-descriptions"
+	flagNoFuncNames        = "no-function-names"
+	flagOnlyErrors         = "only-errors"
+	flagRepositoryPassword = "repository-password"
+	flagRepositoryURL      = "repository-url"
+	flagRepositoryUsername = "repository-username"
+	flagSkipFieldsRegexp   = "skip-fields-regexp"
 
-	envConfigControllerGithubToken = "CONFIG_CONTROLLER_GITHUB_TOKEN" //nolint:gosec
+	envConfigControllerGithubToken        = "CONFIG_CONTROLLER_GITHUB_TOKEN"        //nolint:gosec
+	envConfigControllerRepositoryPassword = "CONFIG_CONTROLLER_REPOSITORY_PASSWORD" //nolint:gosec
 )
 
 type flag struct {
-	Branch           string
-	ConfigVersion    string
-	FilterFunctions  []string
-	GitHubToken      string
-	MaxMessages      int
-	NoDescriptions   bool
-	NoFuncNames      bool
-	OnlyErrors       bool
-	SkipFieldsRegexp string
+	Branch             string
+	ConfigVersion      string
+	FilterFunctions    []string
+	GitHubToken        string
+	LocalPath          string
+	MaxMessages        int
+	MinKeyVersion      int
+	NoDescriptions     bool
+	NoFuncNames        bool
+	OnlyErrors         bool
+	RepositoryPassword string
+	RepositoryURL      string
+	RepositoryUsername string
+	SkipFieldsRegexp   string
 }
 
 func (f *flag) Init(cmd *cobra.Command) {
-	cmd.Flags().StringVar(&f.Branch, flagBranch, "", "Branch of giantswarm/config used to generate configuraton.")
-	cmd.Flags().StringVar(&f.ConfigVersion, flagConfigVersion, "", `Major part of the configuration version to use for generation (e.g. "v2").`)
+	cmd.Flags().StringVar(&f.Branch, flagBranch, "", "Branch of the config repository used to generate configuraton.")
+	cmd.Flags().StringVar(&f.ConfigVersion, flagConfigVersion, "", `Configuration version. Can be a branch name, an exact version (e.g. "2.3.1"), a range (e.g. "2.x.x", "2.3.x", "~2.3" or ">=2.3.0 <3.0.0") or a prerelease range (e.g. "3.0.0-rc.x").`)
 	cmd.Flags().StringSliceVar(&f.FilterFunctions, flagFilterFunctions, []string{}, `Enables filtering linter functions by supplying a list of patterns to match, (e.g. "Lint.*,LintUnusedConfigValues").`)
 	cmd.Flags().StringVar(&f.GitHubToken, flagGithubToken, "", fmt.Sprintf(`GitHub token to use for "opsctl create vaultconfig" calls. Defaults to the value of %s env var.`, envConfigControllerGithubToken))
+	cmd.Flags().StringVar(&f.LocalPath, flagLocalPath, "", "Path to a local copy of giantswarm/config. When set, the directory is linted instead of a branch or tag fetched from GitHub.")
 	cmd.Flags().IntVar(&f.MaxMessages, flagMaxMessages, 50, "Max number of linter messages to display. Unlimited output if set to 0. Defaults to 50.")
+	cmd.Flags().IntVar(&f.MinKeyVersion, flagMinKeyVersion, 0, "Minimum Vault transit key version secret values must be encrypted with. The check is disabled if set to 0.")
 	cmd.Flags().BoolVar(&f.NoDescriptions, flagNoDescriptions, false, "Disables output of message descriptions.")
 	cmd.Flags().BoolVar(&f.NoFuncNames, flagNoFuncNames, false, "Disables output of linter function names.")
 	cmd.Flags().BoolVar(&f.OnlyErrors, flagOnlyErrors, false, "Enables linter to output only errors, omitting suggestions.")
+	cmd.Flags().StringVar(&f.RepositoryPassword, flagRepositoryPassword, "", fmt.Sprintf(`Password or token used to fetch the config repository over HTTPS when it is not hosted on GitHub. Defaults to the value of %s env var.`, envConfigControllerRepositoryPassword))
+	cmd.Flags().StringVar(&f.RepositoryURL, flagRepositoryURL, github.DefaultURL, "URL of the config repository. Any git remote is supported (e.g. GitLab, Gitea, SSH or a local git daemon).")
+	cmd.Flags().StringVar(&f.RepositoryUsername, flagRepositoryUsername, "", fmt.Sprintf(`User name used with --%s.`, flagRepositoryPassword))
 	cmd.Flags().StringVar(&f.SkipFieldsRegexp, flagSkipFieldsRegexp, "", "List of regexp matchers to match field paths, which don't require validation.")
 }
 
 func (f *flag) Validate() error {
-	if f.ConfigVersion == "" && f.Branch == "" {
-		f.Branch = "main"
-	}
-	if f.GitHubToken == "" {
-		f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
+	if f.LocalPath != "" {
+		if f.Branch != "" || f.ConfigVersion != "" {
+			return microerror.Maskf(invalidFlagError, "--%s and --%s must be empty when --%s is set", flagBranch, flagConfigVersion, flagLocalPath)
+		}
+	} else {
+		if f.ConfigVersion == "" && f.Branch == "" {
+			f.Branch = "main"
+		}
+		if _, err := versionspec.Parse(f.ConfigVersion); err != nil {
+			return microerror.Maskf(invalidFlagError, "--%s is invalid: %s", flagConfigVersion, err)
+		}
+		if f.GitHubToken == "" {
+			f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
+		}
+		if f.GitHubToken == "" && github.IsGitHubURL(f.RepositoryURL) {
+			return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty when --%s is a GitHub repository", flagGithubToken, envConfigControllerGithubToken, flagRepositoryURL)
+		}
+		if f.RepositoryPassword == "" {
+			f.RepositoryPassword = os.Getenv(envConfigControllerRepositoryPassword)
+		}
+		if f.RepositoryPassword != "" && f.RepositoryUsername == "" {
+			return microerror.Maskf(invalidFlagError, "--%s must not be empty when --%s or $%s is set", flagRepositoryUsername, flagRepositoryPassword, envConfigControllerRepositoryPassword)
+		}
 	}
-	if f.GitHubToken == "" {
-		return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty", flagGithubToken, envConfigControllerGithubToken)
+
+	if f.MinKeyVersion < 0 {
+		return microerror.Maskf(invalidFlagError, "--%s must not be negative", flagMinKeyVersion)
 	}
 
 	res := strings.Split(f.SkipFieldsRegexp, ",")
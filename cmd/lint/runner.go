@@ -13,11 +13,8 @@ import (
 	"github.com/giantswarm/config-controller/pkg/generator"
 	"github.com/giantswarm/config-controller/pkg/github"
 	"github.com/giantswarm/config-controller/pkg/lint"
-)
-
-const (
-	owner = "giantswarm"
-	repo  = "config"
+	"github.com/giantswarm/config-controller/pkg/localfs"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 )
 
 type runner struct {
@@ -45,31 +42,57 @@ func (r *runner) Run(cmd *cobra.Command, args []string) error {
 
 func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
 	var store generator.Filesystem
-	{
+	if r.flag.LocalPath != "" {
+		var err error
+		store, err = localfs.New(localfs.Config{
+			Path: r.flag.LocalPath,
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
 		gh, err := github.New(github.Config{
-			Token: r.flag.GitHubToken,
+			Token:    r.flag.GitHubToken,
+			Username: r.flag.RepositoryUsername,
+			Password: r.flag.RepositoryPassword,
+			URL:      r.flag.RepositoryURL,
 		})
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
-		if r.flag.ConfigVersion != "" {
-			tag, err := gh.GetLatestTag(ctx, owner, repo, r.flag.ConfigVersion)
-			if err != nil {
-				return microerror.Mask(err)
-			}
+		// --config-version is interpreted like in the controller and
+		// takes precedence over --branch.
+		configVersion := r.flag.ConfigVersion
+		if configVersion == "" {
+			configVersion = r.flag.Branch
+		}
 
-			store, err = gh.GetFilesByTag(ctx, owner, repo, tag)
-			if err != nil {
-				return microerror.Mask(err)
-			}
+		spec, err := versionspec.Parse(configVersion)
+		if err != nil {
+			return microerror.Mask(err)
+		}
 
-		} else if r.flag.Branch != "" {
-			store, err = gh.GetFilesByBranch(ctx, owner, repo, r.flag.Branch)
-			if err != nil {
+		var tag string
+		if !spec.IsBranch() {
+			tag, err = gh.GetLatestTag(ctx, spec)
+			// Fall back to the branch when no tag satisfies a
+			// version that is also a branch name, e.g. "123-fix".
+			if github.IsNotFound(err) && spec.Branch() != "" {
+				tag, err = "", nil
+			} else if err != nil {
 				return microerror.Mask(err)
 			}
 		}
+
+		if tag != "" {
+			store, err = gh.GetFilesByTag(ctx, tag)
+		} else {
+			store, err = gh.GetFilesByBranch(ctx, spec.Branch())
+		}
+		if err != nil {
+			return microerror.Mask(err)
+		}
 	}
 
 	var linter *lint.Linter
@@ -82,6 +105,7 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 			OnlyErrors:       r.flag.OnlyErrors,
 			MaxMessages:      r.flag.MaxMessages,
 			SkipFieldsRegexp: skipFieldsREs,
+			MinKeyVersion:    r.flag.MinKeyVersion,
 		}
 
 		l, err := lint.New(c)
@@ -0,0 +1,59 @@
+package rewrap
+
+import (
+	"io"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+)
+
+const (
+	name            = "rewrap"
+	description     = "Rewrap installation secret values with the latest Vault key version."
+	longDescription = `Rewrap installation secret values with the latest Vault key version.
+
+All Vault ciphertexts found in installations/<installation>/secret.yaml and
+in every secret-values.yaml.patch of the installation are rewrapped with
+Vault transit "rewrap" endpoint and the files are rewritten in place.
+Plaintexts never leave Vault. Formatting, comments and template actions are
+preserved. Ciphertexts already encrypted with the latest key version are
+left untouched.
+
+	config-controller rewrap --installation gauss --local-path ~/giantswarm/config
+`
+)
+
+type Config struct {
+	Logger micrologger.Logger
+	Stderr io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+
+	f := &flag{}
+
+	r := &runner{
+		flag:   f,
+		logger: config.Logger,
+		stderr: config.Stderr,
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long:  longDescription,
+		RunE:  r.Run,
+	}
+
+	f.Init(c)
+
+	return c, nil
+}
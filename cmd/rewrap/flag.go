@@ -0,0 +1,55 @@
+package rewrap
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagGithubToken       = "github-token"
+	flagInstallation      = "installation"
+	flagLocalPath         = "local-path"
+	flagSSHUser           = "ssh-user"
+	flagVaultKeyName      = "vault-key-name"
+	flagVaultTransitMount = "vault-transit-mount"
+
+	envConfigControllerGithubToken = "CONFIG_CONTROLLER_GITHUB_TOKEN" //nolint:gosec
+)
+
+type flag struct {
+	GitHubToken       string
+	Installation      string
+	LocalPath         string
+	SSHUser           string
+	VaultKeyName      string
+	VaultTransitMount string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.GitHubToken, flagGithubToken, "", fmt.Sprintf(`GitHub token to use for "opsctl create vaultconfig" calls. Defaults to the value of %s env var.`, envConfigControllerGithubToken))
+	cmd.Flags().StringVar(&f.Installation, flagInstallation, "", `Installation codename (e.g. "gauss").`)
+	cmd.Flags().StringVar(&f.LocalPath, flagLocalPath, ".", `Path to a local copy of the config repository where files are rewritten.`)
+	cmd.Flags().StringVar(&f.SSHUser, flagSSHUser, "", `User to be passed to opsctl.`)
+	cmd.Flags().StringVar(&f.VaultKeyName, flagVaultKeyName, "config", `Vault transit key used for secret values without key name prefix extension. Values with "vault:<key name>:v<key version>:" prefix use the named key.`)
+	cmd.Flags().StringVar(&f.VaultTransitMount, flagVaultTransitMount, "transit", `Mount path of the Vault transit secrets engine.`)
+}
+
+func (f *flag) Validate() error {
+	if f.GitHubToken == "" {
+		f.GitHubToken = os.Getenv(envConfigControllerGithubToken)
+	}
+	if f.GitHubToken == "" {
+		return microerror.Maskf(invalidFlagError, "--%s or $%s must not be empty", flagGithubToken, envConfigControllerGithubToken)
+	}
+	if f.Installation == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagInstallation)
+	}
+	if f.LocalPath == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagLocalPath)
+	}
+
+	return nil
+}
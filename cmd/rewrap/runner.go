@@ -0,0 +1,143 @@
+package rewrap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/config-controller/internal/opsctl"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+type runner struct {
+	flag   *flag
+	logger micrologger.Logger
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	files, err := secretFiles(r.flag.LocalPath, r.flag.Installation)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	vaultClient, err := opsctl.NewVaultClient(ctx, r.flag.GitHubToken, r.flag.SSHUser, r.flag.Installation)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var rewrapper *decrypt.VaultRewrapper
+	{
+		c := decrypt.VaultRewrapperConfig{
+			KeyName:      r.flag.VaultKeyName,
+			TransitMount: r.flag.VaultTransitMount,
+			VaultClient:  vaultClient,
+		}
+
+		rewrapper, err = decrypt.NewVaultRewrapper(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	for _, file := range files {
+		err = r.rewrapFile(ctx, rewrapper, file)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *runner) rewrapFile(ctx context.Context, rewrapper *decrypt.VaultRewrapper, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	rewrapped, count, err := rewrapper.Rewrap(ctx, data)
+	if err != nil {
+		return microerror.Maskf(executionFailedError, "failed to rewrap %#q: %s", file, err)
+	}
+
+	if count == 0 {
+		fmt.Fprintf(r.stderr, "Skipped %#q, all ciphertexts use the latest key version\n", file)
+		return nil
+	}
+
+	err = ioutil.WriteFile(file, rewrapped, info.Mode())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintf(r.stderr, "Rewrapped %d ciphertexts in %#q\n", count, file)
+
+	return nil
+}
+
+// secretFiles returns installations/<installation>/secret.yaml and all
+// secret-values.yaml.patch files of the installation found in the config
+// repository at root.
+func secretFiles(root, installation string) ([]string, error) {
+	dir := filepath.Join(root, "installations", installation)
+
+	_, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil, microerror.Maskf(notFoundError, "installation directory %#q not found", dir)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var files []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		switch {
+		case path == filepath.Join(dir, "secret.yaml"):
+			files = append(files, path)
+		case info.Name() == "secret-values.yaml.patch":
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return files, nil
+}
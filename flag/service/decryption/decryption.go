@@ -0,0 +1,12 @@
+package decryption
+
+type Decryption struct {
+	AgeKeyFile string
+	Backend    string
+	Cache      Cache
+}
+
+type Cache struct {
+	MaxEntries string
+	TTL        string
+}
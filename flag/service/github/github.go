@@ -1,5 +1,12 @@
 package github
 
 type GitHub struct {
+	App   App
 	Token string
 }
+
+type App struct {
+	ID             string
+	InstallationID string
+	PrivateKeyFile string
+}
@@ -0,0 +1,9 @@
+package repository
+
+type Repository struct {
+	CacheDir     string
+	CacheMaxSize string
+	Password     string
+	URL          string
+	Username     string
+}
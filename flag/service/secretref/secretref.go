@@ -0,0 +1,5 @@
+package secretref
+
+type SecretRef struct {
+	Allowed string
+}
@@ -4,16 +4,24 @@ import (
 	"github.com/giantswarm/operatorkit/v4/pkg/flag/service/kubernetes"
 
 	"github.com/giantswarm/config-controller/flag/service/app"
+	"github.com/giantswarm/config-controller/flag/service/decryption"
 	"github.com/giantswarm/config-controller/flag/service/github"
 	"github.com/giantswarm/config-controller/flag/service/installation"
+	"github.com/giantswarm/config-controller/flag/service/repository"
+	"github.com/giantswarm/config-controller/flag/service/secretref"
 	"github.com/giantswarm/config-controller/flag/service/vault"
+	"github.com/giantswarm/config-controller/flag/service/webhook"
 )
 
 // Service is an intermediate data structure for command line configuration flags.
 type Service struct {
 	App          app.App
+	Decryption   decryption.Decryption
 	GitHub       github.GitHub
 	Installation installation.Installation
 	Kubernetes   kubernetes.Kubernetes
+	Repository   repository.Repository
+	SecretRef    secretref.SecretRef
 	Vault        vault.Vault
+	Webhook      webhook.Webhook
 }
@@ -2,5 +2,30 @@ package vault
 
 type Vault struct {
 	Address string
+	Auth    Auth
 	Token   string
+	Transit Transit
+}
+
+type Auth struct {
+	AppRole    AppRole
+	Kubernetes Kubernetes
+	Method     string
+}
+
+type AppRole struct {
+	MountPath string
+	RoleID    string
+	SecretID  string
+}
+
+type Kubernetes struct {
+	JWTFile   string
+	MountPath string
+	Role      string
+}
+
+type Transit struct {
+	KeyName string
+	Mount   string
 }
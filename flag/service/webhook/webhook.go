@@ -0,0 +1,5 @@
+package webhook
+
+type Webhook struct {
+	Secret string
+}
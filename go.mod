@@ -3,6 +3,8 @@ module github.com/giantswarm/config-controller
 go 1.14
 
 require (
+	filippo.io/age v1.0.0
+	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/Masterminds/sprig/v3 v3.2.0
 	github.com/fatih/color v1.10.0
 	github.com/ghodss/yaml v1.0.0
@@ -18,6 +20,7 @@ require (
 	github.com/giantswarm/valuemodifier v0.3.1
 	github.com/go-git/go-billy/v5 v5.0.0
 	github.com/go-git/go-git/v5 v5.2.0
+	github.com/go-kit/kit v0.10.0
 	github.com/go-test/deep v1.0.7 // indirect
 	github.com/google/go-cmp v0.5.4
 	github.com/hashicorp/go-retryablehttp v0.6.7 // indirect
@@ -31,11 +34,12 @@ require (
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/viper v1.7.1
 	github.com/tidwall/pretty v1.0.1 // indirect
+	github.com/xeipuuv/gojsonschema v1.2.0
 	go.mongodb.org/mongo-driver v1.4.2 // indirect
 	go.uber.org/zap v1.14.1 // indirect
-	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0 // indirect
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 	honnef.co/go/tools v0.0.1-2020.1.3 // indirect
 	k8s.io/api v0.18.9
 	k8s.io/apimachinery v0.18.9
@@ -32,6 +32,10 @@ func (r *Repository) Set(ctx context.Context, key string, value github.Store) {
 	r.underlying.SetDefault(key, value)
 }
 
-func (r *Repository) Key(owner, name, reference string) string {
-	return fmt.Sprintf("%s/%s@%s", owner, name, reference)
+func (r *Repository) Delete(ctx context.Context, key string) {
+	r.underlying.Delete(key)
+}
+
+func (r *Repository) Key(url, reference string) string {
+	return fmt.Sprintf("%s@%s", url, reference)
 }
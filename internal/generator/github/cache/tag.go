@@ -30,6 +30,10 @@ func (t *Tag) Set(ctx context.Context, key, value string) {
 	t.underlying.SetDefault(key, value)
 }
 
-func (t *Tag) Key(owner, name, tag string) string {
-	return fmt.Sprintf("%s/%s@%s", owner, name, tag)
+func (t *Tag) Delete(ctx context.Context, key string) {
+	t.underlying.Delete(key)
+}
+
+func (t *Tag) Key(url, tag string) string {
+	return fmt.Sprintf("%s@%s", url, tag)
 }
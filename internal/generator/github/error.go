@@ -0,0 +1,10 @@
+package github
+
+import (
+	"github.com/giantswarm/config-controller/pkg/github"
+)
+
+// IsNotFound asserts that no tag satisfying the version spec was found.
+func IsNotFound(err error) bool {
+	return github.IsNotFound(err)
+}
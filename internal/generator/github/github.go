@@ -7,10 +7,34 @@ import (
 
 	"github.com/giantswarm/config-controller/internal/generator/github/cache"
 	"github.com/giantswarm/config-controller/pkg/github"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 )
 
 type Config struct {
-	Token string
+	// AppID, AppInstallationID and AppPrivateKey configure authentication
+	// as a GitHub App. They are optional and mutually exclusive with
+	// Token. See github.Config.App for details.
+	AppID             int64
+	AppInstallationID int64
+	AppPrivateKey     []byte
+	Token             string
+	// Username and Password are optional. See github.Config for details.
+	Username string
+	Password string
+	// URL of the config repository. Defaults to github.DefaultURL. See
+	// github.Config.URL for details.
+	URL string
+
+	// CacheDir and CacheMaxSize are optional. See github.Config for
+	// details.
+	CacheDir     string
+	CacheMaxSize int64
+
+	// RepositoryCache and TagCache are optional. They can be shared to
+	// invalidate entries from outside, e.g. when the repository changes.
+	// When empty, new caches are created.
+	RepositoryCache *cache.Repository
+	TagCache        *cache.Tag
 }
 
 type GitHub struct {
@@ -20,29 +44,54 @@ type GitHub struct {
 }
 
 func New(c Config) (*GitHub, error) {
+	var app *github.AppConfig
+	if c.AppID != 0 {
+		app = &github.AppConfig{
+			ID:             c.AppID,
+			InstallationID: c.AppInstallationID,
+			PrivateKey:     c.AppPrivateKey,
+		}
+	}
+
 	client, err := github.New(github.Config{
-		Token: c.Token,
+		App:      app,
+		Token:    c.Token,
+		Username: c.Username,
+		Password: c.Password,
+		URL:      c.URL,
+
+		CacheDir:     c.CacheDir,
+		CacheMaxSize: c.CacheMaxSize,
 	})
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
+	repoCache := c.RepositoryCache
+	if repoCache == nil {
+		repoCache = cache.NewRepository()
+	}
+	tagCache := c.TagCache
+	if tagCache == nil {
+		tagCache = cache.NewTag()
+	}
+
 	gh := &GitHub{
 		client:    client,
-		repoCache: cache.NewRepository(),
-		tagCache:  cache.NewTag(),
+		repoCache: repoCache,
+		tagCache:  tagCache,
 	}
 	return gh, nil
 }
 
-func (gh *GitHub) GetLatestTag(ctx context.Context, owner, name, tagReference string) (string, error) {
-	key := gh.tagCache.Key(owner, name, tagReference)
+func (gh *GitHub) GetLatestTag(ctx context.Context, spec *versionspec.Spec) (string, error) {
+	key := gh.tagCache.Key(gh.client.URL(), spec.String())
 	tag, cached := gh.tagCache.Get(ctx, key)
 	if cached {
 		return tag, nil
 	}
 
-	tag, err := gh.client.GetLatestTag(ctx, owner, name, tagReference)
+	tag, err := gh.client.GetLatestTag(ctx, spec)
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
@@ -51,14 +100,14 @@ func (gh *GitHub) GetLatestTag(ctx context.Context, owner, name, tagReference st
 	return tag, nil
 }
 
-func (gh *GitHub) GetFilesByTag(ctx context.Context, owner, name, tag string) (github.Store, error) {
-	key := gh.repoCache.Key(owner, name, tag)
+func (gh *GitHub) GetFilesByTag(ctx context.Context, tag string) (github.Store, error) {
+	key := gh.repoCache.Key(gh.client.URL(), tag)
 	store, cached := gh.repoCache.Get(ctx, key)
 	if cached {
 		return store, nil
 	}
 
-	store, err := gh.client.GetFilesByTag(ctx, owner, name, tag)
+	store, err := gh.client.GetFilesByTag(ctx, tag)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -67,14 +116,14 @@ func (gh *GitHub) GetFilesByTag(ctx context.Context, owner, name, tag string) (g
 	return store, nil
 }
 
-func (gh *GitHub) GetFilesByBranch(ctx context.Context, owner, name, branch string) (github.Store, error) {
-	key := gh.repoCache.Key(owner, name, branch)
+func (gh *GitHub) GetFilesByBranch(ctx context.Context, branch string) (github.Store, error) {
+	key := gh.repoCache.Key(gh.client.URL(), branch)
 	store, cached := gh.repoCache.Get(ctx, key)
 	if cached {
 		return store, nil
 	}
 
-	store, err := gh.client.GetFilesByBranch(ctx, owner, name, branch)
+	store, err := gh.client.GetFilesByBranch(ctx, branch)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
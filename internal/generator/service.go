@@ -2,6 +2,9 @@ package generator
 
 import (
 	"context"
+	"io/ioutil"
+	"sort"
+	"sync"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
@@ -10,37 +13,160 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/giantswarm/config-controller/internal/generator/github"
+	"github.com/giantswarm/config-controller/internal/generator/github/cache"
 	"github.com/giantswarm/config-controller/internal/meta"
 	"github.com/giantswarm/config-controller/pkg/decrypt"
 	"github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/localfs"
+	"github.com/giantswarm/config-controller/pkg/vaultkv"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 	"github.com/giantswarm/config-controller/pkg/xstrings"
 )
 
+const (
+	defaultWorkers = 4
+)
+
+const (
+	// DecryptionBackendVault decrypts secret values with Vault transit
+	// secrets engine of the installation. It is the default.
+	DecryptionBackendVault = "vault"
+	// DecryptionBackendAge decrypts secret values, each a separate age
+	// file, with age identities from a local key file. SOPS encrypted
+	// files are not supported.
+	DecryptionBackendAge = "age"
+)
+
+// DecryptionBackends lists supported values of Config.DecryptionBackend.
+var DecryptionBackends = []string{
+	DecryptionBackendVault,
+	DecryptionBackendAge,
+}
+
 type Config struct {
 	Log         micrologger.Logger
 	VaultClient *vaultapi.Client
-
+	// VaultAuthWaiter is optional. When set, Vault requests wait until it
+	// reports a valid token.
+	VaultAuthWaiter decrypt.VaultAuthWaiter
+	// VaultKeyName is the transit key used to decrypt secret values
+	// without key name prefix extension. Defaults to "config".
+	VaultKeyName string
+	// VaultTransitMount is the mount path of the Vault transit secrets
+	// engine. Defaults to "transit".
+	VaultTransitMount string
+
+	// AgeKeyFile is a path to the age identities file. It is required
+	// when DecryptionBackend is DecryptionBackendAge.
+	AgeKeyFile string
+	// DecryptionBackend selects how secret values of the installation are
+	// decrypted. It is one of DecryptionBackends and defaults to
+	// DecryptionBackendVault.
+	DecryptionBackend string
+	// DecryptionCache is optional. It keeps decrypted secret values in
+	// memory and can be shared by multiple Services, e.g. all controller
+	// handlers, so every ciphertext is decrypted only once per TTL. When
+	// empty, secret values are decrypted on every generation.
+	DecryptionCache *decrypt.Cache
+
+	// GitHubAppID, GitHubAppInstallationID and GitHubAppPrivateKeyFile
+	// are optional. When set, requests to the config repository are
+	// authenticated with installation tokens of the GitHub App instead of
+	// GitHubToken. Tokens are refreshed before they expire.
+	GitHubAppID             int64
+	GitHubAppInstallationID int64
+	GitHubAppPrivateKeyFile string
+	// GitHubToken authenticates requests to the config repository hosted
+	// on GitHub. It or the GitHub App is required for the default
+	// repository. It is never sent to other hosts.
 	GitHubToken  string
 	Installation string
-	Verbose      bool
+	// LocalPath is an optional path to a local copy of the config
+	// repository. When set, configuration is read from the directory and
+	// GenerateInput.ConfigVersion is used only for annotations.
+	LocalPath string
+	// RepositoryURL is the URL of the config repository. Any git remote is
+	// supported. Defaults to the giantswarm/config GitHub repository.
+	RepositoryURL string
+	// RepositoryUsername and RepositoryPassword are optional. When
+	// RepositoryPassword is set, they authenticate HTTPS requests to the
+	// config repository when it is not hosted on GitHub.
+	RepositoryUsername string
+	RepositoryPassword string
+	// RepositoryCacheDir is optional. When set, the config repository is
+	// kept on disk and updated with incremental fetches instead of being
+	// cloned into memory on every cache miss.
+	RepositoryCacheDir string
+	// RepositoryCacheMaxSize is the maximum size in bytes of checkouts and
+	// the bare clone kept in RepositoryCacheDir. Zero means no limit.
+	RepositoryCacheMaxSize int64
+	// RepositoryFilesCache and RepositoryTagCache are optional. They keep
+	// files and latest tags of the config repository in memory. They can be
+	// shared to invalidate entries when the repository changes.
+	RepositoryFilesCache *cache.Repository
+	RepositoryTagCache   *cache.Tag
+	// SecretRefResolver is optional. It resolves secretRef objects of
+	// installation secret.yaml, e.g. from Kubernetes Secrets of the
+	// management cluster. When empty, installations with such objects
+	// fail to generate.
+	SecretRefResolver generator.SecretRefResolver
+	// SkipDecryption disables decryption of secret values. Generated
+	// Secrets then contain values exactly as they are stored in the config
+	// repository and placeholders instead of values read by the vaultKV
+	// template function or referenced by secretRef objects. It is meant
+	// for tools which never output secret values. VaultClient is not
+	// required then.
+	SkipDecryption bool
+	// Tracer is optional. When set, it receives events of configuration
+	// generation.
+	Tracer generator.Tracer
 }
 
 type Service struct {
-	log              micrologger.Logger
-	decryptTraverser generator.DecryptTraverser
-	gitHub           *github.GitHub
+	log                        micrologger.Logger
+	decryptTraverser           generator.DecryptTraverser
+	permissiveDecryptTraverser generator.DecryptTraverser
+	gitHub                     *github.GitHub
+	localStore                 github.Store
+	secretRefResolver          generator.SecretRefResolver
+	vaultKVReader              generator.VaultKVReader
 
 	installation string
-	verbose      bool
+	tracer       generator.Tracer
 }
 
 func New(config Config) (*Service, error) {
-	if config.VaultClient == nil {
-		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	if config.DecryptionBackend == "" {
+		config.DecryptionBackend = DecryptionBackendVault
+	}
+	if !config.SkipDecryption {
+		switch config.DecryptionBackend {
+		case DecryptionBackendVault:
+			if config.VaultClient == nil {
+				return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+			}
+		case DecryptionBackendAge:
+			if config.AgeKeyFile == "" {
+				return nil, microerror.Maskf(invalidConfigError, "%T.AgeKeyFile must not be empty when %T.DecryptionBackend is %#q", config, config, DecryptionBackendAge)
+			}
+		default:
+			return nil, microerror.Maskf(invalidConfigError, "%T.DecryptionBackend must be one of %v, got %#q", config, DecryptionBackends, config.DecryptionBackend)
+		}
 	}
 
-	if config.GitHubToken == "" {
-		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken must not be empty", config)
+	if config.GitHubToken == "" && config.GitHubAppID == 0 && config.LocalPath == "" && config.RepositoryURL == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken or %T.GitHubAppID must not be empty when %T.LocalPath and %T.RepositoryURL are empty", config, config, config, config)
+	}
+	if config.GitHubAppID != 0 {
+		if config.GitHubAppInstallationID == 0 {
+			return nil, microerror.Maskf(invalidConfigError, "%T.GitHubAppInstallationID must not be empty when %T.GitHubAppID is set", config, config)
+		}
+		if config.GitHubAppPrivateKeyFile == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.GitHubAppPrivateKeyFile must not be empty when %T.GitHubAppID is set", config, config)
+		}
+		if config.GitHubToken != "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken must be empty when %T.GitHubAppID is set", config, config)
+		}
 	}
 	if config.Installation == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Installation must not be empty", config)
@@ -48,35 +174,147 @@ func New(config Config) (*Service, error) {
 
 	var err error
 
-	var decrypter *decrypt.VaultDecrypter
-	{
-		c := decrypt.VaultDecrypterConfig{
+	// decryptTraverser is used for installations with strict secret policy
+	// and permissiveDecryptTraverser for the permissive one.
+	var decryptTraverser generator.DecryptTraverser
+	var permissiveDecryptTraverser generator.DecryptTraverser
+	if config.SkipDecryption {
+		decryptTraverser = noopTraverser{}
+		permissiveDecryptTraverser = noopTraverser{}
+	} else {
+		var decrypter decrypt.Decrypter
+		var decrypterScope string
+		switch config.DecryptionBackend {
+		case DecryptionBackendAge:
+			c := decrypt.AgeDecrypterConfig{
+				KeyFile: config.AgeKeyFile,
+			}
+
+			ageDecrypter, err := decrypt.NewAgeDecrypter(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			decrypter = ageDecrypter
+			decrypterScope = ageDecrypter.CacheScope()
+		default:
+			c := decrypt.VaultDecrypterConfig{
+				AuthWaiter:   config.VaultAuthWaiter,
+				KeyName:      config.VaultKeyName,
+				TransitMount: config.VaultTransitMount,
+				VaultClient:  config.VaultClient,
+			}
+
+			vaultDecrypter, err := decrypt.NewVaultDecrypter(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			decrypter = vaultDecrypter
+			decrypterScope = vaultDecrypter.CacheScope()
+		}
+
+		if config.DecryptionCache != nil {
+			c := decrypt.CachingDecrypterConfig{
+				Cache:     config.DecryptionCache,
+				Decrypter: decrypter,
+				Scope:     decrypterScope,
+			}
+
+			decrypter, err = decrypt.NewCachingDecrypter(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		isCiphertext := decrypt.IsVaultCiphertext
+		if config.DecryptionBackend == DecryptionBackendAge {
+			isCiphertext = decrypt.IsAgeCiphertext
+		}
+
+		{
+			c := decrypt.YAMLTraverserConfig{
+				Decrypter:    decrypter,
+				IsCiphertext: isCiphertext,
+			}
+
+			decryptTraverser, err = decrypt.NewYAMLTraverser(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		{
+			c := decrypt.YAMLTraverserConfig{
+				Decrypter:      decrypter,
+				AllowPlaintext: true,
+				IsCiphertext:   isCiphertext,
+			}
+
+			permissiveDecryptTraverser, err = decrypt.NewYAMLTraverser(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+	}
+
+	secretRefResolver := config.SecretRefResolver
+	if config.SkipDecryption {
+		secretRefResolver = generator.PlaceholderSecretRefResolver{}
+	}
+
+	// vaultKVReader reads secrets for the vaultKV template function. It
+	// stays empty when secret values are decrypted with age and no Vault
+	// client is configured.
+	var vaultKVReader generator.VaultKVReader
+	if config.SkipDecryption {
+		vaultKVReader = generator.PlaceholderVaultKVReader{}
+	} else if config.VaultClient != nil {
+		c := vaultkv.Config{
+			AuthWaiter:  config.VaultAuthWaiter,
 			VaultClient: config.VaultClient,
 		}
 
-		decrypter, err = decrypt.NewVaultDecrypter(c)
+		vaultKVReader, err = vaultkv.New(c)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
 	}
 
-	var decryptTraverser *decrypt.YAMLTraverser
-	{
-		c := decrypt.YAMLTraverserConfig{
-			Decrypter: decrypter,
+	var gitHub *github.GitHub
+	var localStore github.Store
+	if config.LocalPath != "" {
+		c := localfs.Config{
+			Path: config.LocalPath,
 		}
 
-		decryptTraverser, err = decrypt.NewYAMLTraverser(c)
+		localStore, err = localfs.New(c)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
+	} else {
+		var appPrivateKey []byte
+		if config.GitHubAppPrivateKeyFile != "" {
+			appPrivateKey, err = ioutil.ReadFile(config.GitHubAppPrivateKeyFile)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
 
-	}
-
-	var gitHub *github.GitHub
-	{
 		c := github.Config{
-			Token: config.GitHubToken,
+			AppID:             config.GitHubAppID,
+			AppInstallationID: config.GitHubAppInstallationID,
+			AppPrivateKey:     appPrivateKey,
+			Token:             config.GitHubToken,
+			Username:          config.RepositoryUsername,
+			Password:          config.RepositoryPassword,
+			URL:               config.RepositoryURL,
+
+			CacheDir:     config.RepositoryCacheDir,
+			CacheMaxSize: config.RepositoryCacheMaxSize,
+
+			RepositoryCache: config.RepositoryFilesCache,
+			TagCache:        config.RepositoryTagCache,
 		}
 
 		gitHub, err = github.New(c)
@@ -86,12 +324,16 @@ func New(config Config) (*Service, error) {
 	}
 
 	s := &Service{
-		log:              config.Log,
-		decryptTraverser: decryptTraverser,
-		gitHub:           gitHub,
+		log:                        config.Log,
+		decryptTraverser:           decryptTraverser,
+		permissiveDecryptTraverser: permissiveDecryptTraverser,
+		gitHub:                     gitHub,
+		localStore:                 localStore,
+		secretRefResolver:          secretRefResolver,
+		vaultKVReader:              vaultKVReader,
 
 		installation: config.Installation,
-		verbose:      config.Verbose,
+		tracer:       config.Tracer,
 	}
 
 	return s, nil
@@ -100,9 +342,9 @@ func New(config Config) (*Service, error) {
 type GenerateInput struct {
 	// App for which the configuration is generated.
 	App string
-	// ConfigVersion used to generate the configuration which is either a major
-	// version range in format "2.x.x" or a branch name. Exact version
-	// names (e.g. "1.2.3" are not supported.
+	// ConfigVersion used to generate the configuration which is either a
+	// version constraint (e.g. "2.x.x", "2.3.1" or ">=2.3.0 <3.0.0") or a
+	// branch name. See pkg/versionspec for details.
 	ConfigVersion string
 
 	// Name of the generated ConfigMap and Secret.
@@ -120,67 +362,240 @@ type GenerateInput struct {
 }
 
 func (s *Service) Generate(ctx context.Context, in GenerateInput) (configmap *corev1.ConfigMap, secret *corev1.Secret, err error) {
-	tagPrefix, isTagRange, err := toTagPrefix(in.ConfigVersion)
+	gen, err := s.newGenerator(ctx, in.ConfigVersion)
 	if err != nil {
 		return nil, nil, microerror.Mask(err)
 	}
 
-	const (
-		owner = "giantswarm"
-		repo  = "config"
-	)
+	annotations := xstrings.CopyMap(in.ExtraAnnotations)
+	annotations[meta.Annotation.ConfigVersion.Key()] = in.ConfigVersion
 
-	var store github.Store
-	if isTagRange {
-		tag, err := s.gitHub.GetLatestTag(ctx, owner, repo, tagPrefix)
-		if err != nil {
-			return nil, nil, microerror.Mask(err)
-		}
+	meta := metav1.ObjectMeta{
+		Name:      in.Name,
+		Namespace: in.Namespace,
 
-		store, err = s.gitHub.GetFilesByTag(ctx, owner, repo, tag)
-		if err != nil {
-			return nil, nil, microerror.Mask(err)
-		}
-	} else {
-		branch := in.ConfigVersion
+		Annotations: annotations,
+		Labels:      in.ExtraLabels,
+	}
 
-		store, err = s.gitHub.GetFilesByBranch(ctx, owner, repo, branch)
-		if err != nil {
-			return nil, nil, microerror.Mask(err)
-		}
+	configMap, secret, err := gen.GenerateConfig(ctx, in.App, meta)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
 	}
 
-	var gen *generator.Generator
-	{
-		c := generator.Config{
-			Fs:               store,
-			DecryptTraverser: s.decryptTraverser,
+	return configMap, secret, nil
+}
+
+type GenerateAllInput struct {
+	// Apps for which the configuration is generated. When empty, the
+	// configuration is generated for all apps found in the config
+	// repository.
+	Apps []string
+	// ConfigVersion used to generate the configuration. See
+	// GenerateInput.ConfigVersion for details.
+	ConfigVersion string
 
-			Installation: s.installation,
-			Verbose:      s.verbose,
-		}
+	// Namespace of the generated ConfigMaps and Secrets. They are named
+	// after apps.
+	Namespace string
+
+	// ExtraAnnotations are additional annotations to be set on all
+	// generated ConfigMaps and Secrets. See GenerateInput.ExtraAnnotations
+	// for details.
+	ExtraAnnotations map[string]string
+	// ExtraLabels are additional labels to be set on all generated
+	// ConfigMaps and Secrets.
+	ExtraLabels map[string]string
+
+	// Workers is the maximum number of apps generated concurrently.
+	// Defaults to 4.
+	Workers int
+}
+
+// GenerateResult is the configuration generated for a single app by
+// GenerateAll.
+type GenerateResult struct {
+	App       string
+	ConfigMap *corev1.ConfigMap
+	Secret    *corev1.Secret
+	// Err is set when generating the configuration for the app failed.
+	// ConfigMap and Secret are nil then.
+	Err error
+}
 
-		gen, err = generator.New(c)
+// GenerateAll generates configuration for many apps at once. The config
+// repository is fetched and the installation secret is decrypted only once.
+// Results are returned in the same order as apps in the input (or sorted by
+// app name when all apps are generated). Errors specific to a single app are
+// returned in its result.
+func (s *Service) GenerateAll(ctx context.Context, in GenerateAllInput) ([]GenerateResult, error) {
+	gen, err := s.newGenerator(ctx, in.ConfigVersion)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	apps := in.Apps
+	if len(apps) == 0 {
+		apps, err = gen.Apps()
 		if err != nil {
-			return nil, nil, microerror.Mask(err)
+			return nil, microerror.Mask(err)
 		}
+		sort.Strings(apps)
 	}
 
-	annotations := xstrings.CopyMap(in.ExtraAnnotations)
-	annotations[meta.Annotation.ConfigVersion.Key()] = in.ConfigVersion
+	data, err := gen.LoadTemplateData(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	gen = gen.WithTemplateData(data)
 
-	meta := metav1.ObjectMeta{
-		Name:      in.Name,
-		Namespace: in.Namespace,
+	workers := in.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
 
-		Annotations: annotations,
-		Labels:      in.ExtraLabels,
+	results := make([]GenerateResult, len(apps))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(apps); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				app := apps[i]
+
+				annotations := xstrings.CopyMap(in.ExtraAnnotations)
+				annotations[meta.Annotation.ConfigVersion.Key()] = in.ConfigVersion
+
+				objectMeta := metav1.ObjectMeta{
+					Name:      app,
+					Namespace: in.Namespace,
+
+					Annotations: annotations,
+					Labels:      in.ExtraLabels,
+				}
+
+				configMap, secret, err := gen.GenerateConfig(ctx, app, objectMeta)
+				results[i] = GenerateResult{
+					App:       app,
+					ConfigMap: configMap,
+					Secret:    secret,
+					Err:       err,
+				}
+			}
+		}()
 	}
 
-	configMap, secret, err := gen.GenerateConfig(ctx, in.App, meta)
+	for i := range apps {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, nil
+}
+
+type ExplainInput struct {
+	// App for which the configuration is generated.
+	App string
+	// ConfigVersion used to generate the configuration. See
+	// GenerateInput.ConfigVersion for details.
+	ConfigVersion string
+	// Path of the generated value to explain (e.g. "a.b.c").
+	Path string
+}
+
+// Explain generates the configuration and describes how the value under the
+// given path was resolved.
+func (s *Service) Explain(ctx context.Context, in ExplainInput) (*generator.Explanation, error) {
+	gen, err := s.newGenerator(ctx, in.ConfigVersion)
 	if err != nil {
-		return nil, nil, microerror.Mask(err)
+		return nil, microerror.Mask(err)
 	}
 
-	return configMap, secret, nil
+	explanation, err := gen.Explain(ctx, in.App, in.Path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return explanation, nil
+}
+
+func (s *Service) newGenerator(ctx context.Context, configVersion string) (*generator.Generator, error) {
+	store, err := s.getStore(ctx, configVersion)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	secretPolicy, err := generator.InstallationSecretPolicy(store, s.installation)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	decryptTraverser := s.decryptTraverser
+	if secretPolicy == generator.SecretPolicyPermissive {
+		decryptTraverser = s.permissiveDecryptTraverser
+	}
+
+	c := generator.Config{
+		Fs:               store,
+		DecryptTraverser: decryptTraverser,
+
+		Installation:      s.installation,
+		SecretRefResolver: s.secretRefResolver,
+		Tracer:            s.tracer,
+		VaultKVReader:     s.vaultKVReader,
+	}
+
+	gen, err := generator.New(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return gen, nil
+}
+
+func (s *Service) getStore(ctx context.Context, configVersion string) (github.Store, error) {
+	if s.localStore != nil {
+		return s.localStore, nil
+	}
+
+	spec, err := versionspec.Parse(configVersion)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if !spec.IsBranch() {
+		tag, err := s.gitHub.GetLatestTag(ctx, spec)
+		if err == nil {
+			store, err := s.gitHub.GetFilesByTag(ctx, tag)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return store, nil
+		}
+
+		// Versions like "123-fix" are valid constraints and branch
+		// names at the same time. When no tag satisfies the
+		// constraint, fall back to the branch.
+		if !github.IsNotFound(err) || spec.Branch() == "" {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	store, err := s.gitHub.GetFilesByBranch(ctx, spec.Branch())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return store, nil
+}
+
+type noopTraverser struct{}
+
+func (noopTraverser) Traverse(ctx context.Context, data []byte) ([]byte, error) {
+	return data, nil
 }
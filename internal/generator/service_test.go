@@ -0,0 +1,524 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	"github.com/giantswarm/config-controller/pkg/generator"
+	"github.com/giantswarm/config-controller/pkg/localfs"
+	"github.com/giantswarm/config-controller/pkg/vaultkv"
+)
+
+func TestService_GenerateAll(t *testing.T) {
+	files := map[string]string{
+		"default/config.yaml":                                 "region: eu-west-1\n",
+		"default/apps/alpha/configmap-values.yaml.template":   "region: {{ .region }}\n",
+		"default/apps/alpha/secret-values.yaml.template":      "password: {{ .password }}\n",
+		"default/apps/bravo/configmap-values.yaml.template":   "name: bravo\n",
+		"default/apps/charlie/configmap-values.yaml.template": "missing: {{ .missing }}\n",
+		"installations/puma/secret.yaml":                      "password: secret\n",
+	}
+
+	testCases := []struct {
+		name string
+		apps []string
+
+		expectedApps      []string
+		expectedFailed    map[string]bool
+		expectedConfigmap map[string]string
+	}{
+		{
+			name: "case 0: all apps",
+
+			expectedApps:   []string{"alpha", "bravo", "charlie"},
+			expectedFailed: map[string]bool{"charlie": true},
+			expectedConfigmap: map[string]string{
+				"alpha": "region: eu-west-1\n",
+				"bravo": "name: bravo\n",
+			},
+		},
+		{
+			name: "case 1: selected apps",
+			apps: []string{"bravo", "alpha"},
+
+			expectedApps:   []string{"bravo", "alpha"},
+			expectedFailed: map[string]bool{},
+			expectedConfigmap: map[string]string{
+				"alpha": "region: eu-west-1\n",
+				"bravo": "name: bravo\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			for p, data := range files {
+				err = os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				err = ioutil.WriteFile(filepath.Join(dir, p), []byte(data), 0644) // nolint:gosec
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			store, err := localfs.New(localfs.Config{Path: dir})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			traverser := &countingTraverser{}
+			s := &Service{
+				decryptTraverser: traverser,
+				localStore:       store,
+
+				installation: "puma",
+			}
+
+			in := GenerateAllInput{
+				Apps:      tc.apps,
+				Namespace: "giantswarm",
+				Workers:   2,
+			}
+			results, err := s.GenerateAll(context.Background(), in)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if c := atomic.LoadInt32(&traverser.count); c != 1 {
+				t.Fatalf("expected secret.yaml to be decrypted once, got %d decryptions", c)
+			}
+
+			if len(results) != len(tc.expectedApps) {
+				t.Fatalf("expected %d results, got %d", len(tc.expectedApps), len(results))
+			}
+			for j, r := range results {
+				if r.App != tc.expectedApps[j] {
+					t.Fatalf("expected result %d for app %#q, got %#q", j, tc.expectedApps[j], r.App)
+				}
+				if tc.expectedFailed[r.App] {
+					if r.Err == nil {
+						t.Fatalf("expected error for app %#q", r.App)
+					}
+					continue
+				}
+				if r.Err != nil {
+					t.Fatalf("unexpected error for app %#q: %s", r.App, r.Err)
+				}
+				if r.ConfigMap.Name != r.App {
+					t.Fatalf("expected ConfigMap name %#q, got %#q", r.App, r.ConfigMap.Name)
+				}
+				if cm := r.ConfigMap.Data["configmap-values.yaml"]; cm != tc.expectedConfigmap[r.App] {
+					t.Fatalf("expected configmap values %q for app %#q, got %q", tc.expectedConfigmap[r.App], r.App, cm)
+				}
+			}
+		})
+	}
+}
+
+func TestService_Generate_ageDecryption(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var encrypted bytes.Buffer
+	{
+		a := armor.NewWriter(&encrypted)
+		w, err := age.Encrypt(a, identity.Recipient())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = io.WriteString(w, "secret")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = w.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = a.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"keys.txt":                 identity.String() + "\n",
+		"repo/default/config.yaml": "region: eu-west-1\n",
+		"repo/default/apps/alpha/configmap-values.yaml.template": "region: {{ .region }}\n",
+		"repo/default/apps/alpha/secret-values.yaml.template":    "password: {{ .password }}\n",
+		"repo/installations/puma/secret.yaml":                    "password: |\n  " + strings.ReplaceAll(strings.TrimSpace(encrypted.String()), "\n", "\n  ") + "\n",
+	}
+	for p, data := range files {
+		err = os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, p), []byte(data), 0600)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	c := Config{
+		AgeKeyFile:        filepath.Join(dir, "keys.txt"),
+		DecryptionBackend: DecryptionBackendAge,
+
+		Installation: "puma",
+		LocalPath:    filepath.Join(dir, "repo"),
+	}
+	s, err := New(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	in := GenerateInput{
+		App:       "alpha",
+		Name:      "alpha",
+		Namespace: "giantswarm",
+	}
+	_, secret, err := s.Generate(context.Background(), in)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "password: secret\n"
+	if v := string(secret.Data["secret-values.yaml"]); v != expected {
+		t.Fatalf("expected secret values %q, got %q", expected, v)
+	}
+}
+
+func TestService_Generate_secretPolicy(t *testing.T) {
+	// Fake Vault "decrypts" ciphertexts by stripping the prefix. The rest
+	// is base64 encoded plaintext already.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			BatchInput []struct {
+				Ciphertext string `json:"ciphertext"`
+			} `json:"batch_input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var results []map[string]string
+		for _, item := range req.BatchInput {
+			results = append(results, map[string]string{"plaintext": strings.TrimPrefix(item.Ciphertext, "vault:v1:")})
+		}
+		resp := map[string]interface{}{
+			"data": map[string]interface{}{
+				"batch_results": results,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	vaultConfig := vaultapi.DefaultConfig()
+	vaultConfig.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(vaultConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name           string
+		metadata       string
+		expectedSecret string
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:         "case 0: plaintext values are rejected by default",
+			metadata:     "",
+			errorMatcher: decrypt.IsUnencryptedValue,
+		},
+		{
+			name:         "case 1: plaintext values are rejected with strict policy",
+			metadata:     "secretPolicy: strict\n",
+			errorMatcher: decrypt.IsUnencryptedValue,
+		},
+		{
+			name:           "case 2: plaintext values are used untouched with permissive policy",
+			metadata:       "secretPolicy: permissive\n",
+			expectedSecret: "password: secret\nport: 5432\nusername: admin\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			files := map[string]string{
+				"default/config.yaml":                               "region: eu-west-1\n",
+				"default/apps/alpha/configmap-values.yaml.template": "region: {{ .region }}\n",
+				"default/apps/alpha/secret-values.yaml.template":    "password: {{ .password }}\nport: {{ .port }}\nusername: {{ .username }}\n",
+				"installations/puma/secret.yaml":                    "password: vault:v1:c2VjcmV0\nport: 5432\nusername: admin\n",
+			}
+			if tc.metadata != "" {
+				files["installations/puma/metadata.yaml"] = tc.metadata
+			}
+			for p, data := range files {
+				err = os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				err = ioutil.WriteFile(filepath.Join(dir, p), []byte(data), 0644) // nolint:gosec
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			c := Config{
+				VaultClient: vaultClient,
+
+				Installation: "puma",
+				LocalPath:    dir,
+			}
+			s, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			in := GenerateInput{
+				App:       "alpha",
+				Name:      "alpha",
+				Namespace: "giantswarm",
+			}
+			_, secret, err := s.Generate(context.Background(), in)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if v := string(secret.Data["secret-values.yaml"]); v != tc.expectedSecret {
+				t.Fatalf("expected secret values %q, got %q", tc.expectedSecret, v)
+			}
+		})
+	}
+}
+
+type countingTraverser struct {
+	count int32
+}
+
+func (t *countingTraverser) Traverse(ctx context.Context, data []byte) ([]byte, error) {
+	atomic.AddInt32(&t.count, 1)
+	return data, nil
+}
+
+func TestService_Generate_vaultKV(t *testing.T) {
+	// Fake Vault serves a KV version 2 secret and "decrypts" transit
+	// ciphertexts by stripping the prefix like in
+	// TestService_Generate_secretPolicy.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			if r.URL.Path != "/v1/secret/data/alpha" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			resp := map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]interface{}{
+						"password": "kv-secret",
+						"token":    "kv-token",
+					},
+					"metadata": map[string]interface{}{
+						"version": 1,
+					},
+				},
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		var req struct {
+			BatchInput []struct {
+				Ciphertext string `json:"ciphertext"`
+			} `json:"batch_input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var results []map[string]string
+		for _, item := range req.BatchInput {
+			results = append(results, map[string]string{"plaintext": strings.TrimPrefix(item.Ciphertext, "vault:v1:")})
+		}
+		resp := map[string]interface{}{
+			"data": map[string]interface{}{
+				"batch_results": results,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	vaultConfig := vaultapi.DefaultConfig()
+	vaultConfig.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(vaultConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name              string
+		files             map[string]string
+		expectedConfigmap string
+		expectedSecret    string
+		errorMatcher      func(error) bool
+	}{
+		{
+			name: "case 0: vaultKV in secret template",
+			files: map[string]string{
+				"default/apps/alpha/secret-values.yaml.template": "password: {{ vaultKV \"secret/data/alpha\" \"password\" | quote }}\nusername: {{ .username }}\n",
+			},
+			expectedConfigmap: "region: eu-west-1\n",
+			expectedSecret:    "password: \"kv-secret\"\nusername: admin\n",
+		},
+		{
+			name: "case 1: vaultKV in secret patch of strict installation",
+			files: map[string]string{
+				"default/apps/alpha/secret-values.yaml.template":         "password: {{ .username }}\ntoken: \"\"\nusername: {{ .username }}\n",
+				"installations/puma/apps/alpha/secret-values.yaml.patch": "password: vault:v1:cGF0Y2hlZA==\ntoken: {{ vaultKV \"secret/data/alpha\" \"token\" | b64enc }}\n",
+			},
+			expectedConfigmap: "region: eu-west-1\n",
+			expectedSecret:    "password: patched\ntoken: a3YtdG9rZW4=\nusername: admin\n",
+		},
+		{
+			name: "case 2: vaultKV in configmap template",
+			files: map[string]string{
+				"default/apps/alpha/configmap-values.yaml.template": "region: {{ vaultKV \"secret/data/alpha\" \"password\" }}\n",
+			},
+			errorMatcher: generator.IsVaultKVNotAllowed,
+		},
+		{
+			name: "case 3: vaultKV in configmap patch",
+			files: map[string]string{
+				"installations/puma/apps/alpha/configmap-values.yaml.patch": "region: {{ vaultKV \"secret/data/alpha\" \"password\" }}\n",
+			},
+			errorMatcher: generator.IsVaultKVNotAllowed,
+		},
+		{
+			name: "case 4: missing Vault KV key",
+			files: map[string]string{
+				"default/apps/alpha/secret-values.yaml.template": "password: {{ vaultKV \"secret/data/alpha\" \"missing\" }}\n",
+			},
+			errorMatcher: vaultkv.IsNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			files := map[string]string{
+				"default/config.yaml":                               "region: eu-west-1\n",
+				"default/apps/alpha/configmap-values.yaml.template": "region: {{ .region }}\n",
+				"installations/puma/secret.yaml":                    "username: vault:v1:YWRtaW4=\n",
+			}
+			for p, data := range tc.files {
+				files[p] = data
+			}
+			for p, data := range files {
+				err = os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				err = ioutil.WriteFile(filepath.Join(dir, p), []byte(data), 0644) // nolint:gosec
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			c := Config{
+				VaultClient: vaultClient,
+
+				Installation: "puma",
+				LocalPath:    dir,
+			}
+			s, err := New(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			in := GenerateInput{
+				App:       "alpha",
+				Name:      "alpha",
+				Namespace: "giantswarm",
+			}
+			configmap, secret, err := s.Generate(context.Background(), in)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if v := configmap.Data["configmap-values.yaml"]; v != tc.expectedConfigmap {
+				t.Fatalf("expected configmap values %q, got %q", tc.expectedConfigmap, v)
+			}
+			if v := string(secret.Data["secret-values.yaml"]); v != tc.expectedSecret {
+				t.Fatalf("expected secret values %q, got %q", tc.expectedSecret, v)
+			}
+		})
+	}
+}
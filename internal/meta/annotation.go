@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"os/user"
+	"time"
 
 	"github.com/giantswarm/apiextensions/v3/pkg/apis/core/v1alpha1"
 	"github.com/giantswarm/microerror"
@@ -21,6 +22,7 @@ var (
 	xObjectHashAnnotation     = project.Name() + ".x-giantswarm.io/object-hash"
 	xPreviousConfigAnnotation = project.Name() + ".x-giantswarm.io/previous-config"
 	xProjectVersionAnnotation = project.Name() + ".x-giantswarm.io/project-version"
+	xRequeuedAtAnnotation     = project.Name() + ".x-giantswarm.io/requeued-at"
 )
 
 type ConfigVersion struct{}
@@ -116,3 +118,9 @@ func (XProjectVersion) Val(unique bool) string {
 
 	return project.Version()
 }
+
+type XRequeuedAt struct{}
+
+func (XRequeuedAt) Key() string { return xRequeuedAtAnnotation }
+
+func (XRequeuedAt) Val(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) }
@@ -26,6 +26,9 @@ type AnnotationType struct {
 	// XProjectVersion is set on generated ConfigMap and Secret to show what
 	// version of config-controller was used to generate them.
 	XProjectVersion
+	// XRequeuedAt is set on Config CRs to trigger their reconciliation,
+	// e.g. when the config repository changes.
+	XRequeuedAt
 }
 
 type LabelType struct {
@@ -1,4 +1,5 @@
-package generate
+// Package opsctl creates clients for installation services using opsctl.
+package opsctl
 
 import (
 	"context"
@@ -38,7 +39,9 @@ func newVaultClient(config vaultClientConfig) (*vaultapi.Client, error) {
 	return vaultClient, nil
 }
 
-func createVaultClientUsingOpsctl(ctx context.Context, gitHubToken, sshUser, installation string) (*vaultapi.Client, error) {
+// NewVaultClient creates a Vault client for the installation using
+// "opsctl create vaultconfig". sshUser is optional.
+func NewVaultClient(ctx context.Context, gitHubToken, sshUser, installation string) (*vaultapi.Client, error) {
 	cmdArgs := []string{"opsctl", "create", "vaultconfig", "-i", installation, "-o", "json"}
 
 	if sshUser != "" {
@@ -73,5 +76,4 @@ func createVaultClientUsingOpsctl(ctx context.Context, gitHubToken, sshUser, ins
 	}
 
 	return vaultClient, nil
-
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/microkit/command"
@@ -12,10 +13,16 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/giantswarm/config-controller/cmd/encrypt"
+	"github.com/giantswarm/config-controller/cmd/explain"
 	"github.com/giantswarm/config-controller/cmd/generate"
 	"github.com/giantswarm/config-controller/cmd/lint"
+	"github.com/giantswarm/config-controller/cmd/rewrap"
 	"github.com/giantswarm/config-controller/flag"
+	"github.com/giantswarm/config-controller/internal/generator"
+	"github.com/giantswarm/config-controller/pkg/github"
 	"github.com/giantswarm/config-controller/pkg/project"
+	"github.com/giantswarm/config-controller/pkg/vaultauth"
 	"github.com/giantswarm/config-controller/server"
 	"github.com/giantswarm/config-controller/service"
 )
@@ -107,6 +114,16 @@ func mainE(ctx context.Context) error {
 
 	// Add sub-commands
 	subcommands := []*cobra.Command{}
+	{
+		c := encrypt.Config{
+			Logger: logger,
+		}
+		cmd, err := encrypt.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		subcommands = append(subcommands, cmd)
+	}
 	{
 		c := generate.Config{
 			Logger: logger,
@@ -117,6 +134,16 @@ func mainE(ctx context.Context) error {
 		}
 		subcommands = append(subcommands, cmd)
 	}
+	{
+		c := explain.Config{
+			Logger: logger,
+		}
+		cmd, err := explain.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		subcommands = append(subcommands, cmd)
+	}
 	{
 		c := lint.Config{
 			Logger: logger,
@@ -127,12 +154,29 @@ func mainE(ctx context.Context) error {
 		}
 		subcommands = append(subcommands, cmd)
 	}
+	{
+		c := rewrap.Config{
+			Logger: logger,
+		}
+		cmd, err := rewrap.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		subcommands = append(subcommands, cmd)
+	}
 
 	newCommand.CobraCommand().AddCommand(subcommands...)
 
 	daemonCommand := newCommand.DaemonCommand().CobraCommand()
 
 	daemonCommand.PersistentFlags().Bool(f.Service.App.Unique, false, "Whether the operator is deployed as a unique app.")
+	daemonCommand.PersistentFlags().String(f.Service.Decryption.AgeKeyFile, "", `Path to the age identities file used when the decryption backend is "age".`)
+	daemonCommand.PersistentFlags().String(f.Service.Decryption.Backend, generator.DecryptionBackendVault, fmt.Sprintf("Backend used to decrypt secret values of the installation. One of %q.", generator.DecryptionBackends))
+	daemonCommand.PersistentFlags().Int(f.Service.Decryption.Cache.MaxEntries, 10000, "Maximum number of decrypted secret values kept in memory. Least recently used values are evicted first.")
+	daemonCommand.PersistentFlags().Duration(f.Service.Decryption.Cache.TTL, 10*time.Minute, "Time after which decrypted secret values kept in memory expire.")
+	daemonCommand.PersistentFlags().Int64(f.Service.GitHub.App.ID, 0, "ID of the GitHub App used to pull repositories from GitHub instead of the token.")
+	daemonCommand.PersistentFlags().Int64(f.Service.GitHub.App.InstallationID, 0, "ID of the GitHub App installation with access to the config repository.")
+	daemonCommand.PersistentFlags().String(f.Service.GitHub.App.PrivateKeyFile, "", "Path to the PEM encoded private key of the GitHub App.")
 	daemonCommand.PersistentFlags().String(f.Service.GitHub.Token, "", "Token used to pull repositories from GitHub")
 	daemonCommand.PersistentFlags().String(f.Service.Installation.Name, "", `Installation codename (e.g. "geckon")`)
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.Address, "http://127.0.0.1:6443", "Address used to connect to Kubernetes. When empty in-cluster config is created.")
@@ -141,8 +185,24 @@ func mainE(ctx context.Context) error {
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.CAFile, "", "Certificate authority file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.CrtFile, "", "Certificate file path to use to authenticate with Kubernetes.")
 	daemonCommand.PersistentFlags().String(f.Service.Kubernetes.TLS.KeyFile, "", "Key file path to use to authenticate with Kubernetes.")
+	daemonCommand.PersistentFlags().String(f.Service.Repository.CacheDir, "", "Directory where the config repository is kept and updated with incremental fetches. When empty the repository is cloned into memory.")
+	daemonCommand.PersistentFlags().Int64(f.Service.Repository.CacheMaxSize, 1<<30, "Maximum size in bytes of commit checkouts and the bare clone kept in the repository cache directory. Least recently used checkouts are removed first. When the limit is still exceeded the bare clone is removed and fetched again, so the limit should be well above the size of the repository. Zero means no limit.")
+	daemonCommand.PersistentFlags().String(f.Service.Repository.Password, "", "Password or token used to pull the config repository over HTTPS when it is not hosted on GitHub.")
+	daemonCommand.PersistentFlags().String(f.Service.Repository.URL, github.DefaultURL, "URL of the config repository. Any git remote is supported.")
+	daemonCommand.PersistentFlags().String(f.Service.Repository.Username, "", "User name used with the repository password.")
+	daemonCommand.PersistentFlags().StringSlice(f.Service.SecretRef.Allowed, []string{}, `Kubernetes Secrets which secretRef values of installation secrets may be read from. Entries are either "<namespace>" or "<namespace>/<name>". References to other Secrets are rejected.`)
 	daemonCommand.PersistentFlags().String(f.Service.Vault.Address, "", "Vault server address")
-	daemonCommand.PersistentFlags().String(f.Service.Vault.Token, "", "Vault server address")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.AppRole.MountPath, "approle", "Mount path of the Vault AppRole auth method.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.AppRole.RoleID, "", `Vault AppRole role ID used when the auth method is "approle".`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.AppRole.SecretID, "", `Vault AppRole secret ID used when the auth method is "approle".`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Kubernetes.JWTFile, "/var/run/secrets/kubernetes.io/serviceaccount/token", "Service account token file used to authenticate with Vault Kubernetes auth method.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Kubernetes.MountPath, "kubernetes", "Mount path of the Vault Kubernetes auth method.")
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Kubernetes.Role, "", `Vault role used when the auth method is "kubernetes".`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Auth.Method, vaultauth.MethodToken, fmt.Sprintf("Vault auth method. One of %q.", vaultauth.Methods))
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Token, "", `Vault token used when the auth method is "token".`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Transit.KeyName, "config", `Vault transit key used to decrypt secret values. Values with "vault:<key name>:v<key version>:" prefix are decrypted with the named key instead.`)
+	daemonCommand.PersistentFlags().String(f.Service.Vault.Transit.Mount, "transit", "Mount path of the Vault transit secrets engine.")
+	daemonCommand.PersistentFlags().String(f.Service.Webhook.Secret, "", "Secret used to verify signatures of GitHub and Gitea push webhooks. When empty the webhook endpoint is disabled.")
 
 	newCommand.CobraCommand().SilenceErrors = true
 	newCommand.CobraCommand().SilenceUsage = true
@@ -0,0 +1,110 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/giantswarm/microerror"
+)
+
+// ageFileHeader is the first line of binary age files.
+const ageFileHeader = "age-encryption.org/v1\n"
+
+type AgeDecrypterConfig struct {
+	// KeyFile is a path to the file with age identities, i.e. lines
+	// starting with "AGE-SECRET-KEY-", as generated by age-keygen.
+	KeyFile string
+}
+
+// AgeDecrypter decrypts values encrypted with age using identities from
+// a local key file. Values are either ASCII armored ("age --armor") or
+// base64 encoded binary age files. Every value is a separate age file;
+// SOPS encrypted files ("ENC[AES256_GCM,...]" values with "sops" metadata)
+// are not supported.
+type AgeDecrypter struct {
+	identities []age.Identity
+	// keysHash is SHA-256 hash of the key file used as the cache scope.
+	keysHash string
+}
+
+var _ Decrypter = &AgeDecrypter{}
+
+func NewAgeDecrypter(config AgeDecrypterConfig) (*AgeDecrypter, error) {
+	if config.KeyFile == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KeyFile must not be empty", config)
+	}
+
+	keys, err := ioutil.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(keys))
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "failed to parse age identities from %#q: %s", config.KeyFile, err)
+	}
+
+	d := &AgeDecrypter{
+		identities: identities,
+		keysHash:   fmt.Sprintf("%x", sha256.Sum256(keys)),
+	}
+
+	return d, nil
+}
+
+// CacheScope returns the scope of CachingDecrypterConfig identifying the
+// age identities of the decrypter without revealing them.
+func (d *AgeDecrypter) CacheScope() string {
+	return "age:" + d.keysHash
+}
+
+func (d *AgeDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	ciphertext = bytes.TrimSpace(ciphertext)
+
+	var src io.Reader
+	if bytes.HasPrefix(ciphertext, []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(ciphertext))
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(string(ciphertext))
+		if err != nil {
+			return nil, microerror.Maskf(executionFailedError, "age ciphertext must be armored or base64 encoded: %s", err)
+		}
+		src = bytes.NewReader(decoded)
+	}
+
+	r, err := age.Decrypt(src, d.identities...)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "failed to decrypt age ciphertext: %s", err)
+	}
+
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintext, nil
+}
+
+// IsAgeCiphertext returns true if the value is an ASCII armored or base64
+// encoded binary age file.
+func IsAgeCiphertext(value string) bool {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, armor.Header) {
+		return true
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(decoded, []byte(ageFileHeader))
+}
@@ -0,0 +1,139 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/giantswarm/microerror"
+)
+
+func TestAgeDecrypter(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	otherIdentity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	testCases := []struct {
+		name       string
+		ciphertext func(t *testing.T) []byte
+
+		expectedPlaintext string
+		errorMatcher      func(error) bool
+	}{
+		{
+			name: "case 0: armored",
+			ciphertext: func(t *testing.T) []byte {
+				return ageEncrypt(t, identity.Recipient(), "secret", true)
+			},
+			expectedPlaintext: "secret",
+		},
+		{
+			name: "case 1: base64 encoded",
+			ciphertext: func(t *testing.T) []byte {
+				return ageEncrypt(t, identity.Recipient(), "secret", false)
+			},
+			expectedPlaintext: "secret",
+		},
+		{
+			name: "case 2: encrypted for other recipient",
+			ciphertext: func(t *testing.T) []byte {
+				return ageEncrypt(t, otherIdentity.Recipient(), "secret", true)
+			},
+			errorMatcher: func(err error) bool { return microerror.Cause(err) == executionFailedError },
+		},
+		{
+			name: "case 3: not encrypted",
+			ciphertext: func(t *testing.T) []byte {
+				return []byte("plain text")
+			},
+			errorMatcher: func(err error) bool { return microerror.Cause(err) == executionFailedError },
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "keys.txt")
+	err = ioutil.WriteFile(keyFile, []byte("# created: 2021-01-01T00:00:00Z\n"+identity.String()+"\n"), 0600)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	decrypter, err := NewAgeDecrypter(AgeDecrypterConfig{KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plaintext, err := decrypter.Decrypt(context.Background(), tc.ciphertext(t))
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if string(plaintext) != tc.expectedPlaintext {
+				t.Fatalf("plaintext = %q, want %q", plaintext, tc.expectedPlaintext)
+			}
+		})
+	}
+}
+
+func ageEncrypt(t *testing.T, recipient age.Recipient, plaintext string, armored bool) []byte {
+	buf := &bytes.Buffer{}
+
+	var dst io.WriteCloser = nopWriteCloser{buf}
+	if armored {
+		dst = armor.NewWriter(buf)
+	}
+
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	_, err = io.WriteString(w, plaintext)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	err = dst.Close()
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	if armored {
+		return buf.Bytes()
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,144 @@
+package decrypt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+type CacheConfig struct {
+	// MaxEntries is the maximum number of cached plaintexts. When the
+	// cache is full, the least recently used plaintext is evicted.
+	MaxEntries int
+	// TTL is the time after which a cached plaintext expires.
+	TTL time.Duration
+}
+
+// Cache keeps decrypted plaintexts in memory. Plaintexts are keyed by
+// SHA-256 hash of the CachingDecrypterConfig.Scope and the ciphertext so
+// ciphertexts are not retained and plaintexts of decrypters using different
+// keys are kept apart. It is safe for concurrent use and may be shared by
+// multiple CachingDecrypters.
+type Cache struct {
+	maxEntries int
+	ttl        time.Duration
+
+	mutex   sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	// lru contains *cacheEntry elements, most recently used first.
+	lru *list.List
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	key       [sha256.Size]byte
+	plaintext []byte
+	expires   time.Time
+}
+
+func NewCache(config CacheConfig) (*Cache, error) {
+	if config.MaxEntries <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.MaxEntries must be greater than 0", config)
+	}
+	if config.TTL <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TTL must be greater than 0", config)
+	}
+
+	c := &Cache{
+		maxEntries: config.MaxEntries,
+		ttl:        config.TTL,
+
+		entries: map[[sha256.Size]byte]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+
+	return c, nil
+}
+
+// Len returns the number of cached plaintexts including expired ones which
+// were not removed yet.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *Cache) get(scope string, ciphertext []byte) ([]byte, bool) {
+	key := cacheKey(scope, ciphertext)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		cacheMissCounter.Inc()
+		return nil, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(e)
+		cacheMissCounter.Inc()
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	cacheHitCounter.Inc()
+
+	return copyBytes(entry.plaintext), true
+}
+
+func (c *Cache) set(scope string, ciphertext, plaintext []byte) {
+	key := cacheKey(scope, ciphertext)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &cacheEntry{
+		key:       key,
+		plaintext: copyBytes(plaintext),
+		expires:   c.now().Add(c.ttl),
+	}
+
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		cacheEvictionCounter.Inc()
+	}
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// cacheKey hashes the scope and the ciphertext. The scope is length
+// prefixed so different pairs never hash the same input.
+func cacheKey(scope string, ciphertext []byte) [sha256.Size]byte {
+	h := sha256.New()
+	_ = binary.Write(h, binary.BigEndian, uint64(len(scope)))
+	_, _ = h.Write([]byte(scope))
+	_, _ = h.Write(ciphertext)
+
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	return key
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
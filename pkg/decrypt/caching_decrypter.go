@@ -0,0 +1,113 @@
+package decrypt
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+type CachingDecrypterConfig struct {
+	Cache     *Cache
+	Decrypter Decrypter
+	// Scope identifies the backend and keys of Decrypter, e.g. the value
+	// of VaultDecrypter.CacheScope or AgeDecrypter.CacheScope. Plaintexts
+	// are cached per scope so decrypters with different keys sharing the
+	// Cache never return each other's plaintexts.
+	Scope string
+}
+
+// CachingDecrypter decorates a Decrypter with a Cache of plaintexts.
+type CachingDecrypter struct {
+	cache     *Cache
+	decrypter Decrypter
+	scope     string
+}
+
+var _ BatchDecrypter = &CachingDecrypter{}
+
+func NewCachingDecrypter(config CachingDecrypterConfig) (*CachingDecrypter, error) {
+	if config.Cache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Cache must not be empty", config)
+	}
+	if config.Decrypter == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Decrypter must not be empty", config)
+	}
+	if config.Scope == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Scope must not be empty", config)
+	}
+
+	d := &CachingDecrypter{
+		cache:     config.Cache,
+		decrypter: config.Decrypter,
+		scope:     config.Scope,
+	}
+
+	return d, nil
+}
+
+func (d *CachingDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	plaintext, ok := d.cache.get(d.scope, ciphertext)
+	if ok {
+		return plaintext, nil
+	}
+
+	plaintext, err := d.decrypter.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	d.cache.set(d.scope, ciphertext, plaintext)
+
+	return plaintext, nil
+}
+
+// DecryptBatch decrypts ciphertexts missing in the cache with a single
+// batch when the decorated Decrypter is a BatchDecrypter and one by one
+// otherwise.
+func (d *CachingDecrypter) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	plaintexts := make([][]byte, len(ciphertexts))
+
+	var missing []int
+	for i, c := range ciphertexts {
+		plaintext, ok := d.cache.get(d.scope, c)
+		if ok {
+			plaintexts[i] = plaintext
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return plaintexts, nil
+	}
+
+	batchDecrypter, ok := d.decrypter.(BatchDecrypter)
+	if !ok {
+		for _, i := range missing {
+			plaintext, err := d.decrypter.Decrypt(ctx, ciphertexts[i])
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			d.cache.set(d.scope, ciphertexts[i], plaintext)
+			plaintexts[i] = plaintext
+		}
+
+		return plaintexts, nil
+	}
+
+	var missingCiphertexts [][]byte
+	for _, i := range missing {
+		missingCiphertexts = append(missingCiphertexts, ciphertexts[i])
+	}
+
+	decrypted, err := batchDecrypter.DecryptBatch(ctx, missingCiphertexts)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for j, i := range missing {
+		d.cache.set(d.scope, ciphertexts[i], decrypted[j])
+		plaintexts[i] = decrypted[j]
+	}
+
+	return plaintexts, nil
+}
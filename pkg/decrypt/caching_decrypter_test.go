@@ -0,0 +1,124 @@
+package decrypt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCachingDecrypter(t *testing.T) {
+	ctx := context.Background()
+
+	cache, err := NewCache(CacheConfig{MaxEntries: 2, TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	underlying := &countingDecrypter{calls: map[string]int{}}
+	d, err := NewCachingDecrypter(CachingDecrypterConfig{Cache: cache, Decrypter: underlying, Scope: "a"})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	decrypt := func(ciphertext string) {
+		t.Helper()
+		plaintext, err := d.Decrypt(ctx, []byte(ciphertext))
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+		if string(plaintext) != "plain-"+ciphertext {
+			t.Fatalf("plaintext = %q, want %q", plaintext, "plain-"+ciphertext)
+		}
+	}
+	expectCalls := func(ciphertext string, expected int) {
+		t.Helper()
+		if c := underlying.calls[ciphertext]; c != expected {
+			t.Fatalf("%q decrypted %d times, want %d", ciphertext, c, expected)
+		}
+	}
+
+	hitsBefore := testutil.ToFloat64(cacheHitCounter)
+	missesBefore := testutil.ToFloat64(cacheMissCounter)
+
+	// Cached after first decryption.
+	decrypt("a")
+	decrypt("a")
+	expectCalls("a", 1)
+
+	if h := testutil.ToFloat64(cacheHitCounter) - hitsBefore; h != 1 {
+		t.Fatalf("hits = %v, want 1", h)
+	}
+	if m := testutil.ToFloat64(cacheMissCounter) - missesBefore; m != 1 {
+		t.Fatalf("misses = %v, want 1", m)
+	}
+
+	// Least recently used "b" is evicted when "c" is added.
+	decrypt("b")
+	decrypt("a")
+	decrypt("c")
+	decrypt("a")
+	decrypt("b")
+	expectCalls("a", 1)
+	expectCalls("b", 2)
+	if l := cache.Len(); l != 2 {
+		t.Fatalf("cache length = %d, want 2", l)
+	}
+
+	// Expired plaintexts are decrypted again.
+	now = now.Add(time.Minute)
+	decrypt("a")
+	expectCalls("a", 2)
+
+	// Batch decrypts only missing ciphertexts.
+	plaintexts, err := d.DecryptBatch(ctx, [][]byte{[]byte("a"), []byte("d")})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if string(plaintexts[0]) != "plain-a" || string(plaintexts[1]) != "plain-d" {
+		t.Fatalf("plaintexts = %q, want [plain-a plain-d]", plaintexts)
+	}
+	expectCalls("a", 2)
+	expectCalls("d", 1)
+
+	// Returned plaintexts can be modified without affecting the cache.
+	plaintexts[0][0] = 'X'
+	decrypt("a")
+
+	// Decrypters with other scopes sharing the cache do not get cached
+	// plaintexts.
+	other := &countingDecrypter{calls: map[string]int{}, prefix: "other-"}
+	otherD, err := NewCachingDecrypter(CachingDecrypterConfig{Cache: cache, Decrypter: other, Scope: "b"})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	plaintext, err := otherD.Decrypt(ctx, []byte("a"))
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if string(plaintext) != "other-a" {
+		t.Fatalf("plaintext = %q, want %q", plaintext, "other-a")
+	}
+	if c := other.calls["a"]; c != 1 {
+		t.Fatalf("%q decrypted %d times, want %d", "a", c, 1)
+	}
+}
+
+type countingDecrypter struct {
+	calls map[string]int
+	// prefix of returned plaintexts. Defaults to "plain-".
+	prefix string
+}
+
+func (d *countingDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	d.calls[string(ciphertext)]++
+	prefix := d.prefix
+	if prefix == "" {
+		prefix = "plain-"
+	}
+	return []byte(prefix + string(ciphertext)), nil
+}
@@ -0,0 +1,83 @@
+package decrypt
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// vaultCiphertextPattern matches Vault ciphertext prefix with optional
+	// key name extension, e.g. "vault:v1:" or "vault:payments:v2:". Key
+	// names must not start with a dot so they can not be "." or ".."
+	// path segments of the transit endpoint.
+	vaultCiphertextPattern = regexp.MustCompile(`^vault:(?:([A-Za-z0-9_-][A-Za-z0-9_.-]*):)?v(\d+):`)
+	// vaultCiphertextTextPattern matches Vault ciphertexts embedded in
+	// a text, e.g. a YAML file or a template.
+	vaultCiphertextTextPattern = regexp.MustCompile(`vault:(?:[A-Za-z0-9_-][A-Za-z0-9_.-]*:)?v\d+:[A-Za-z0-9+/]+=*`)
+)
+
+// IsVaultCiphertext returns true if the value is encrypted with Vault
+// transit secrets engine, i.e. it starts with "vault:v<key version>:" or
+// "vault:<key name>:v<key version>:" prefix.
+func IsVaultCiphertext(value string) bool {
+	return vaultCiphertextPattern.MatchString(value)
+}
+
+// CiphertextKeyVersion returns the transit key version the value is
+// encrypted with. It returns false if the value is not a Vault ciphertext.
+func CiphertextKeyVersion(value string) (int, bool) {
+	m := vaultCiphertextPattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(m[2])
+	if err != nil {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// FindVaultCiphertexts returns all Vault ciphertexts found in the text.
+func FindVaultCiphertexts(text string) []string {
+	return vaultCiphertextTextPattern.FindAllString(text, -1)
+}
+
+// splitVaultCiphertext returns the key name set by the ciphertext prefix
+// extension, or an empty string if there is none, and the ciphertext in
+// the format understood by Vault, i.e. without the key name.
+func splitVaultCiphertext(ciphertext []byte) (string, []byte) {
+	m := vaultCiphertextPattern.FindSubmatchIndex(ciphertext)
+	if m == nil || m[2] < 0 {
+		return "", ciphertext
+	}
+
+	key := string(ciphertext[m[2]:m[3]])
+
+	// Cut "<key name>:" out of "vault:<key name>:v<key version>:...".
+	stripped := make([]byte, 0, len(ciphertext)-(m[3]+1-m[2]))
+	stripped = append(stripped, ciphertext[:m[2]]...)
+	stripped = append(stripped, ciphertext[m[3]+1:]...)
+
+	return key, stripped
+}
+
+// joinVaultCiphertext adds the key name prefix extension to the ciphertext
+// returned by Vault.
+func joinVaultCiphertext(key string, ciphertext []byte) []byte {
+	const prefix = "vault:"
+
+	if !bytes.HasPrefix(ciphertext, []byte(prefix)) {
+		return ciphertext
+	}
+
+	joined := make([]byte, 0, len(ciphertext)+len(key)+1)
+	joined = append(joined, prefix...)
+	joined = append(joined, key...)
+	joined = append(joined, ':')
+	joined = append(joined, ciphertext[len(prefix):]...)
+
+	return joined
+}
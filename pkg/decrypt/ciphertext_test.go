@@ -0,0 +1,85 @@
+package decrypt
+
+import "testing"
+
+func Test_splitVaultCiphertext(t *testing.T) {
+	testCases := []struct {
+		name               string
+		ciphertext         string
+		expectedKey        string
+		expectedCiphertext string
+		expectedVersion    int
+	}{
+		{
+			name:               "case 0: no key name",
+			ciphertext:         "vault:v1:YWJj",
+			expectedKey:        "",
+			expectedCiphertext: "vault:v1:YWJj",
+			expectedVersion:    1,
+		},
+		{
+			name:               "case 1: key name",
+			ciphertext:         "vault:payments:v12:YWJj",
+			expectedKey:        "payments",
+			expectedCiphertext: "vault:v12:YWJj",
+			expectedVersion:    12,
+		},
+		{
+			name:               "case 2: key name with dashes and dots",
+			ciphertext:         "vault:team-a.db_creds:v3:YWJj",
+			expectedKey:        "team-a.db_creds",
+			expectedCiphertext: "vault:v3:YWJj",
+			expectedVersion:    3,
+		},
+		{
+			name:               "case 3: not a ciphertext",
+			ciphertext:         "vault:payments:YWJj",
+			expectedKey:        "",
+			expectedCiphertext: "vault:payments:YWJj",
+			expectedVersion:    0,
+		},
+		{
+			name:               "case 4: dot-only key name",
+			ciphertext:         "vault:..:v1:YWJj",
+			expectedKey:        "",
+			expectedCiphertext: "vault:..:v1:YWJj",
+			expectedVersion:    0,
+		},
+		{
+			name:               "case 5: key name starting with a dot",
+			ciphertext:         "vault:.hidden:v1:YWJj",
+			expectedKey:        "",
+			expectedCiphertext: "vault:.hidden:v1:YWJj",
+			expectedVersion:    0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Log(tc.name)
+
+			key, ciphertext := splitVaultCiphertext([]byte(tc.ciphertext))
+			if key != tc.expectedKey {
+				t.Fatalf("key = %q, want %q", key, tc.expectedKey)
+			}
+			if string(ciphertext) != tc.expectedCiphertext {
+				t.Fatalf("ciphertext = %q, want %q", ciphertext, tc.expectedCiphertext)
+			}
+
+			version, ok := CiphertextKeyVersion(tc.ciphertext)
+			if ok != (tc.expectedVersion > 0) {
+				t.Fatalf("ok = %v, want %v", ok, tc.expectedVersion > 0)
+			}
+			if version != tc.expectedVersion {
+				t.Fatalf("version = %d, want %d", version, tc.expectedVersion)
+			}
+
+			if key != "" {
+				joined := joinVaultCiphertext(key, ciphertext)
+				if string(joined) != tc.ciphertext {
+					t.Fatalf("joined = %q, want %q", joined, tc.ciphertext)
+				}
+			}
+		})
+	}
+}
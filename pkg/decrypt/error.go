@@ -19,3 +19,12 @@ var invalidConfigError = &microerror.Error{
 func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
+
+var unencryptedValueError = &microerror.Error{
+	Kind: "unencryptedValueError",
+}
+
+// IsUnencryptedValue asserts unencryptedValueError.
+func IsUnencryptedValue(err error) bool {
+	return microerror.Cause(err) == unencryptedValueError
+}
@@ -0,0 +1,43 @@
+package decrypt
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	PrometheusNamespace = "config_controller"
+	PrometheusSubsystem = "decryption_cache"
+)
+
+var (
+	cacheHitCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "hits_total",
+			Help:      "Number of ciphertexts found in the decryption cache.",
+		},
+	)
+
+	cacheMissCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "misses_total",
+			Help:      "Number of ciphertexts not found in the decryption cache.",
+		},
+	)
+
+	cacheEvictionCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Subsystem: PrometheusSubsystem,
+			Name:      "evictions_total",
+			Help:      "Number of plaintexts evicted from the decryption cache because it was full.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheHitCounter)
+	prometheus.MustRegister(cacheMissCounter)
+	prometheus.MustRegister(cacheEvictionCounter)
+}
@@ -5,3 +5,15 @@ import "context"
 type Decrypter interface {
 	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
 }
+
+// BatchDecrypter is implemented by decrypters which can decrypt multiple
+// values at once more efficiently than one by one. DecryptBatch returns
+// plaintexts in the order of ciphertexts.
+type BatchDecrypter interface {
+	Decrypter
+	DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error)
+}
+
+type Encrypter interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+}
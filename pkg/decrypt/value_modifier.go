@@ -28,3 +28,44 @@ func (m *valueModifier) Modify(bs []byte) ([]byte, error) {
 
 	return bs, nil
 }
+
+// collectingModifier collects unique values without modifying them.
+type collectingModifier struct {
+	seen   map[string]bool
+	values [][]byte
+}
+
+func newCollectingModifier() *collectingModifier {
+	return &collectingModifier{
+		seen: map[string]bool{},
+	}
+}
+
+func (m *collectingModifier) Modify(bs []byte) ([]byte, error) {
+	if !m.seen[string(bs)] {
+		m.seen[string(bs)] = true
+		m.values = append(m.values, bs)
+	}
+
+	return bs, nil
+}
+
+// lookupModifier replaces values with their precomputed replacements.
+type lookupModifier struct {
+	replacements map[string][]byte
+}
+
+func newLookupModifier(replacements map[string][]byte) *lookupModifier {
+	return &lookupModifier{
+		replacements: replacements,
+	}
+}
+
+func (m *lookupModifier) Modify(bs []byte) ([]byte, error) {
+	r, ok := m.replacements[string(bs)]
+	if !ok {
+		return nil, microerror.Maskf(executionFailedError, "replacement not found")
+	}
+
+	return r, nil
+}
@@ -0,0 +1,116 @@
+package decrypt
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	// batchSize is the maximum number of ciphertexts sent in a single
+	// Vault request.
+	batchSize = 100
+
+	defaultTransitKeyName = "config"
+	defaultTransitMount   = "transit"
+)
+
+// VaultAuthWaiter blocks until the Vault client has a valid token, e.g.
+// while the client re-authenticates.
+type VaultAuthWaiter interface {
+	Wait(ctx context.Context) error
+}
+
+// transitEndpoint returns the endpoint of the transit secrets engine
+// operation, e.g. "/v1/transit/decrypt/config". The key name is escaped so
+// it always stays a single path segment.
+func transitEndpoint(mount, operation, key string) string {
+	return "/v1/" + strings.Trim(mount, "/") + "/" + operation + "/" + url.PathEscape(key)
+}
+
+// batchByKey calls f with chunks of at most batchSize ciphertexts
+// encrypted with the same transit key. Key names are taken from
+// ciphertext prefix extensions and default to defaultKey. Ciphertexts
+// passed to f are stripped of the extension. Results of f are returned in
+// the order of ciphertexts.
+func batchByKey(ciphertexts [][]byte, defaultKey string, f func(key string, ciphertexts [][]byte) ([][]byte, error)) ([][]byte, error) {
+	var keys []string
+	indexes := map[string][]int{}
+	stripped := make([][]byte, len(ciphertexts))
+	for i, c := range ciphertexts {
+		key, s := splitVaultCiphertext(c)
+		if key == "" {
+			key = defaultKey
+		}
+		if _, ok := indexes[key]; !ok {
+			keys = append(keys, key)
+		}
+		indexes[key] = append(indexes[key], i)
+		stripped[i] = s
+	}
+
+	results := make([][]byte, len(ciphertexts))
+	for _, key := range keys {
+		idx := indexes[key]
+		for start := 0; start < len(idx); start += batchSize {
+			end := start + batchSize
+			if end > len(idx) {
+				end = len(idx)
+			}
+
+			var chunk [][]byte
+			for _, i := range idx[start:end] {
+				chunk = append(chunk, stripped[i])
+			}
+
+			out, err := f(key, chunk)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			if len(out) != len(chunk) {
+				return nil, microerror.Maskf(executionFailedError, "expected %d batch results, got %d", len(chunk), len(out))
+			}
+
+			for j, i := range idx[start:end] {
+				results[i] = out[j]
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// vaultRequest sends the request to Vault. authWaiter is optional.
+func vaultRequest(ctx context.Context, vaultClient *vaultapi.Client, authWaiter VaultAuthWaiter, endpoint string, req, resp interface{}) error {
+	if authWaiter != nil {
+		err := authWaiter.Wait(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	httpReq := vaultClient.NewRequest("POST", endpoint)
+	err := httpReq.SetJSONBody(req)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	httpResp, err := vaultClient.RawRequest(httpReq)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if httpResp.StatusCode != 200 {
+		return microerror.Maskf(executionFailedError, "expected status code = 200, got %d", httpResp.StatusCode)
+	}
+
+	err = httpResp.DecodeJSON(resp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
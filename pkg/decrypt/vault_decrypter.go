@@ -3,38 +3,73 @@ package decrypt
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 
 	"github.com/giantswarm/microerror"
 	vaultapi "github.com/hashicorp/vault/api"
 )
 
-const (
-	keyring = "/v1/transit/decrypt/config"
-)
-
 type VaultDecrypterConfig struct {
-	VaultClient *vaultapi.Client
+	// AuthWaiter is optional. When set, requests wait until it reports
+	// a valid token.
+	AuthWaiter VaultAuthWaiter
+	// KeyName is the transit key used to decrypt ciphertexts without key
+	// name prefix extension. Defaults to "config".
+	KeyName string
+	// TransitMount is the mount path of the transit secrets engine.
+	// Defaults to "transit".
+	TransitMount string
+	VaultClient  *vaultapi.Client
 }
 
+// VaultDecrypter decrypts ciphertexts with Vault transit secrets engine.
+// Ciphertexts with "vault:v<key version>:" prefix are decrypted with the
+// configured key. Ciphertexts with "vault:<key name>:v<key version>:"
+// prefix are decrypted with the key of the given name mounted at the same
+// path.
 type VaultDecrypter struct {
-	vaultClient *vaultapi.Client
+	authWaiter   VaultAuthWaiter
+	keyName      string
+	transitMount string
+	vaultClient  *vaultapi.Client
 }
 
-var _ Decrypter = &VaultDecrypter{}
+var _ BatchDecrypter = &VaultDecrypter{}
 
 func NewVaultDecrypter(config VaultDecrypterConfig) (*VaultDecrypter, error) {
 	if config.VaultClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
 	}
 
+	if config.KeyName == "" {
+		config.KeyName = defaultTransitKeyName
+	}
+	if config.TransitMount == "" {
+		config.TransitMount = defaultTransitMount
+	}
+
 	d := &VaultDecrypter{
-		vaultClient: config.VaultClient,
+		authWaiter:   config.AuthWaiter,
+		keyName:      config.KeyName,
+		transitMount: config.TransitMount,
+		vaultClient:  config.VaultClient,
 	}
 
 	return d, nil
 }
 
+// CacheScope returns the scope of CachingDecrypterConfig identifying the
+// Vault server, transit mount and default key of the decrypter.
+func (d *VaultDecrypter) CacheScope() string {
+	return fmt.Sprintf("vault:%s/%s/%s", d.vaultClient.Address(), d.transitMount, d.keyName)
+}
+
 func (d *VaultDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	key, ciphertext := splitVaultCiphertext(ciphertext)
+	if key == "" {
+		key = d.keyName
+	}
+
 	req := struct {
 		Ciphertext string `json:"ciphertext"`
 	}{
@@ -47,7 +82,7 @@ func (d *VaultDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte
 		} `json:"data"`
 	}{}
 
-	err := d.vaultRequest(ctx, keyring, req, &resp)
+	err := vaultRequest(ctx, d.vaultClient, d.authWaiter, transitEndpoint(d.transitMount, "decrypt", key), req, &resp)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -60,26 +95,63 @@ func (d *VaultDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte
 	return decoded, nil
 }
 
-func (d *VaultDecrypter) vaultRequest(ctx context.Context, endpoint string, req, resp interface{}) error {
-	httpReq := d.vaultClient.NewRequest("POST", endpoint)
-	err := httpReq.SetJSONBody(req)
-	if err != nil {
-		return microerror.Mask(err)
+// DecryptBatch decrypts ciphertexts using Vault's batch_input. Ciphertexts
+// are grouped by transit key and sent in chunks of batchSize.
+func (d *VaultDecrypter) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	decryptBatch := func(key string, ciphertexts [][]byte) ([][]byte, error) {
+		return d.decryptBatch(ctx, key, ciphertexts)
 	}
 
-	httpResp, err := d.vaultClient.RawRequest(httpReq)
+	plaintexts, err := batchByKey(ciphertexts, d.keyName, decryptBatch)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, microerror.Mask(err)
+	}
+
+	return plaintexts, nil
+}
+
+func (d *VaultDecrypter) decryptBatch(ctx context.Context, key string, ciphertexts [][]byte) ([][]byte, error) {
+	type batchItem struct {
+		Ciphertext string `json:"ciphertext"`
 	}
 
-	if httpResp.StatusCode != 200 {
-		return microerror.Maskf(executionFailedError, "expected status code = 200, got %d", httpResp.StatusCode)
+	req := struct {
+		BatchInput []batchItem `json:"batch_input"`
+	}{}
+	for _, c := range ciphertexts {
+		req.BatchInput = append(req.BatchInput, batchItem{Ciphertext: string(c)})
 	}
 
-	err = httpResp.DecodeJSON(resp)
+	resp := struct {
+		Data struct {
+			BatchResults []struct {
+				Plaintext string `json:"plaintext"`
+				Error     string `json:"error"`
+			} `json:"batch_results"`
+		} `json:"data"`
+	}{}
+
+	err := vaultRequest(ctx, d.vaultClient, d.authWaiter, transitEndpoint(d.transitMount, "decrypt", key), req, &resp)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, microerror.Mask(err)
+	}
+
+	if len(resp.Data.BatchResults) != len(ciphertexts) {
+		return nil, microerror.Maskf(executionFailedError, "expected %d batch results, got %d", len(ciphertexts), len(resp.Data.BatchResults))
+	}
+
+	var plaintexts [][]byte
+	for i, r := range resp.Data.BatchResults {
+		if r.Error != "" {
+			return nil, microerror.Maskf(executionFailedError, "failed to decrypt batch item %d: %s", i, r.Error)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(r.Plaintext)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		plaintexts = append(plaintexts, decoded)
 	}
 
-	return nil
+	return plaintexts, nil
 }
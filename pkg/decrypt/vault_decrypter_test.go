@@ -0,0 +1,242 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestVaultDecrypter_DecryptBatch(t *testing.T) {
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/transit/decrypt/config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			BatchInput []struct {
+				Ciphertext string `json:"ciphertext"`
+			} `json:"batch_input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batchSizes = append(batchSizes, len(req.BatchInput))
+
+		var results []map[string]string
+		for _, item := range req.BatchInput {
+			results = append(results, map[string]string{
+				"plaintext": base64.StdEncoding.EncodeToString([]byte(strings.TrimPrefix(item.Ciphertext, "vault:v1:"))),
+			})
+		}
+		resp := map[string]interface{}{
+			"data": map[string]interface{}{
+				"batch_results": results,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	d, err := NewVaultDecrypter(VaultDecrypterConfig{VaultClient: vaultClient})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	var ciphertexts [][]byte
+	for i := 0; i < batchSize+1; i++ {
+		ciphertexts = append(ciphertexts, []byte(fmt.Sprintf("vault:v1:value-%d", i)))
+	}
+
+	plaintexts, err := d.DecryptBatch(context.Background(), ciphertexts)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	if len(batchSizes) != 2 || batchSizes[0] != batchSize || batchSizes[1] != 1 {
+		t.Fatalf("batch sizes = %v, want [%d 1]", batchSizes, batchSize)
+	}
+	if len(plaintexts) != len(ciphertexts) {
+		t.Fatalf("len(plaintexts) = %d, want %d", len(plaintexts), len(ciphertexts))
+	}
+	for i, p := range plaintexts {
+		expected := fmt.Sprintf("value-%d", i)
+		if string(p) != expected {
+			t.Fatalf("plaintexts[%d] = %q, want %q", i, p, expected)
+		}
+	}
+}
+
+func TestVaultDecrypter_waitsForAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "new-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		resp := map[string]interface{}{
+			"data": map[string]string{
+				"plaintext": base64.StdEncoding.EncodeToString([]byte("secret")),
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	vaultClient.SetToken("expired-token")
+
+	// Re-authentication sets a new token while the decryption is
+	// waiting.
+	waiter := &testAuthWaiter{ready: make(chan struct{})}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		vaultClient.SetToken("new-token")
+		close(waiter.ready)
+	}()
+
+	d, err := NewVaultDecrypter(VaultDecrypterConfig{AuthWaiter: waiter, VaultClient: vaultClient})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	plaintext, err := d.Decrypt(context.Background(), []byte("vault:v1:c2VjcmV0"))
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if string(plaintext) != "secret" {
+		t.Fatalf("plaintext = %q, want %q", plaintext, "secret")
+	}
+}
+
+type testAuthWaiter struct {
+	ready chan struct{}
+}
+
+func (w *testAuthWaiter) Wait(ctx context.Context) error {
+	<-w.ready
+	return nil
+}
+
+func TestVaultDecrypter_keyRouting(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Plaintexts are "<key name>/<ciphertext sent to Vault>".
+		key := strings.TrimPrefix(r.URL.Path, "/v1/regulated/transit/decrypt/")
+		if r.Method != http.MethodPost || key == r.URL.Path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Ciphertext string `json:"ciphertext"`
+			BatchInput []struct {
+				Ciphertext string `json:"ciphertext"`
+			} `json:"batch_input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, key)
+
+		var resp map[string]interface{}
+		if req.BatchInput == nil {
+			resp = map[string]interface{}{
+				"data": map[string]string{
+					"plaintext": base64.StdEncoding.EncodeToString([]byte(key + "/" + req.Ciphertext)),
+				},
+			}
+		} else {
+			var results []map[string]string
+			for _, item := range req.BatchInput {
+				results = append(results, map[string]string{
+					"plaintext": base64.StdEncoding.EncodeToString([]byte(key + "/" + item.Ciphertext)),
+				})
+			}
+			resp = map[string]interface{}{
+				"data": map[string]interface{}{
+					"batch_results": results,
+				},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	config := VaultDecrypterConfig{
+		KeyName:      "secrets",
+		TransitMount: "/regulated/transit/",
+		VaultClient:  vaultClient,
+	}
+	d, err := NewVaultDecrypter(config)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	plaintext, err := d.Decrypt(context.Background(), []byte("vault:payments:v2:YQ=="))
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if string(plaintext) != "payments/vault:v2:YQ==" {
+		t.Fatalf("plaintext = %q, want %q", plaintext, "payments/vault:v2:YQ==")
+	}
+
+	requests = nil
+	ciphertexts := [][]byte{
+		[]byte("vault:v1:YQ=="),
+		[]byte("vault:payments:v2:Yg=="),
+		[]byte("vault:v3:Yw=="),
+		[]byte("vault:payments:v1:ZA=="),
+	}
+	expected := []string{
+		"secrets/vault:v1:YQ==",
+		"payments/vault:v2:Yg==",
+		"secrets/vault:v3:Yw==",
+		"payments/vault:v1:ZA==",
+	}
+
+	plaintexts, err := d.DecryptBatch(context.Background(), ciphertexts)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	for i, p := range plaintexts {
+		if string(p) != expected[i] {
+			t.Fatalf("plaintexts[%d] = %q, want %q", i, p, expected[i])
+		}
+	}
+	if len(requests) != 2 || requests[0] != "secrets" || requests[1] != "payments" {
+		t.Fatalf("requests = %v, want %v", requests, []string{"secrets", "payments"})
+	}
+}
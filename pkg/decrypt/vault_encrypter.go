@@ -0,0 +1,88 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+type VaultEncrypterConfig struct {
+	// AuthWaiter is optional. When set, requests wait until it reports
+	// a valid token.
+	AuthWaiter VaultAuthWaiter
+	// KeyName is the transit key used to encrypt values. Defaults to
+	// "config".
+	KeyName string
+	// KeyPrefix adds the key name prefix extension to ciphertexts, e.g.
+	// "vault:payments:v1:", so VaultDecrypter decrypts them with KeyName
+	// instead of its own default key.
+	KeyPrefix bool
+	// TransitMount is the mount path of the transit secrets engine.
+	// Defaults to "transit".
+	TransitMount string
+	VaultClient  *vaultapi.Client
+}
+
+// VaultEncrypter encrypts values with the same transit key VaultDecrypter
+// decrypts them with. Ciphertexts have "vault:v<key version>:" prefix, or
+// "vault:<key name>:v<key version>:" prefix with KeyPrefix set.
+type VaultEncrypter struct {
+	authWaiter   VaultAuthWaiter
+	keyName      string
+	keyPrefix    bool
+	transitMount string
+	vaultClient  *vaultapi.Client
+}
+
+var _ Encrypter = &VaultEncrypter{}
+
+func NewVaultEncrypter(config VaultEncrypterConfig) (*VaultEncrypter, error) {
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	if config.KeyName == "" {
+		config.KeyName = defaultTransitKeyName
+	}
+	if config.TransitMount == "" {
+		config.TransitMount = defaultTransitMount
+	}
+
+	e := &VaultEncrypter{
+		authWaiter:   config.AuthWaiter,
+		keyName:      config.KeyName,
+		keyPrefix:    config.KeyPrefix,
+		transitMount: config.TransitMount,
+		vaultClient:  config.VaultClient,
+	}
+
+	return e, nil
+}
+
+func (e *VaultEncrypter) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	req := struct {
+		Plaintext string `json:"plaintext"`
+	}{
+		Plaintext: base64.StdEncoding.EncodeToString(plaintext),
+	}
+
+	resp := struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}{}
+
+	err := vaultRequest(ctx, e.vaultClient, e.authWaiter, transitEndpoint(e.transitMount, "encrypt", e.keyName), req, &resp)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ciphertext := []byte(resp.Data.Ciphertext)
+	if e.keyPrefix {
+		ciphertext = joinVaultCiphertext(e.keyName, ciphertext)
+	}
+
+	return ciphertext, nil
+}
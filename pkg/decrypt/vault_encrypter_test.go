@@ -0,0 +1,60 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestVaultEncrypter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/transit/encrypt/config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Plaintext string `json:"plaintext"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"data": map[string]string{
+				"ciphertext": "vault:v1:" + req.Plaintext,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	e, err := NewVaultEncrypter(VaultEncrypterConfig{VaultClient: vaultClient})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	ciphertext, err := e.Encrypt(context.Background(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	expected := "vault:v1:c2VjcmV0"
+	if string(ciphertext) != expected {
+		t.Fatalf("ciphertext = %q, want %q", ciphertext, expected)
+	}
+}
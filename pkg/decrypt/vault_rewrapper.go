@@ -0,0 +1,167 @@
+package decrypt
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+type VaultRewrapperConfig struct {
+	// AuthWaiter is optional. When set, requests wait until it reports
+	// a valid token.
+	AuthWaiter VaultAuthWaiter
+	// KeyName is the transit key used to rewrap ciphertexts without key
+	// name prefix extension. Defaults to "config".
+	KeyName string
+	// TransitMount is the mount path of the transit secrets engine.
+	// Defaults to "transit".
+	TransitMount string
+	VaultClient  *vaultapi.Client
+}
+
+// VaultRewrapper re-encrypts ciphertexts with the latest version of the
+// transit key VaultDecrypter decrypts them with. Plaintexts never leave
+// Vault. Key name prefix extensions of ciphertexts are preserved.
+type VaultRewrapper struct {
+	authWaiter   VaultAuthWaiter
+	keyName      string
+	transitMount string
+	vaultClient  *vaultapi.Client
+}
+
+func NewVaultRewrapper(config VaultRewrapperConfig) (*VaultRewrapper, error) {
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	if config.KeyName == "" {
+		config.KeyName = defaultTransitKeyName
+	}
+	if config.TransitMount == "" {
+		config.TransitMount = defaultTransitMount
+	}
+
+	r := &VaultRewrapper{
+		authWaiter:   config.AuthWaiter,
+		keyName:      config.KeyName,
+		transitMount: config.TransitMount,
+		vaultClient:  config.VaultClient,
+	}
+
+	return r, nil
+}
+
+// Rewrap rewraps all Vault ciphertexts found in data and returns data with
+// the ciphertexts replaced. Everything else, including formatting,
+// comments and template actions, is left untouched. Ciphertexts already
+// encrypted with the latest key version are kept to avoid needless
+// changes. The second returned value is the number of replaced
+// ciphertexts.
+func (r *VaultRewrapper) Rewrap(ctx context.Context, data []byte) ([]byte, int, error) {
+	var ciphertexts [][]byte
+	seen := map[string]bool{}
+	for _, c := range vaultCiphertextTextPattern.FindAll(data, -1) {
+		if seen[string(c)] {
+			continue
+		}
+		seen[string(c)] = true
+		ciphertexts = append(ciphertexts, c)
+	}
+
+	if len(ciphertexts) == 0 {
+		return data, 0, nil
+	}
+
+	rewrapped, err := r.RewrapBatch(ctx, ciphertexts)
+	if err != nil {
+		return nil, 0, microerror.Mask(err)
+	}
+
+	replacements := map[string][]byte{}
+	for i, c := range ciphertexts {
+		oldVersion, _ := CiphertextKeyVersion(string(c))
+		newVersion, ok := CiphertextKeyVersion(string(rewrapped[i]))
+		if !ok {
+			return nil, 0, microerror.Maskf(executionFailedError, "rewrapped ciphertext %d is not a Vault ciphertext", i)
+		}
+		if newVersion == oldVersion {
+			continue
+		}
+		replacements[string(c)] = rewrapped[i]
+	}
+
+	var count int
+	out := vaultCiphertextTextPattern.ReplaceAllFunc(data, func(c []byte) []byte {
+		replacement, ok := replacements[string(c)]
+		if !ok {
+			return c
+		}
+		count++
+		return replacement
+	})
+
+	return out, count, nil
+}
+
+// RewrapBatch rewraps ciphertexts using Vault's batch_input. Ciphertexts
+// are grouped by transit key and sent in chunks of batchSize.
+func (r *VaultRewrapper) RewrapBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	rewrapBatch := func(key string, ciphertexts [][]byte) ([][]byte, error) {
+		return r.rewrapBatch(ctx, key, ciphertexts)
+	}
+
+	rewrapped, err := batchByKey(ciphertexts, r.keyName, rewrapBatch)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i, c := range ciphertexts {
+		if key, _ := splitVaultCiphertext(c); key != "" {
+			rewrapped[i] = joinVaultCiphertext(key, rewrapped[i])
+		}
+	}
+
+	return rewrapped, nil
+}
+
+func (r *VaultRewrapper) rewrapBatch(ctx context.Context, key string, ciphertexts [][]byte) ([][]byte, error) {
+	type batchItem struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+
+	req := struct {
+		BatchInput []batchItem `json:"batch_input"`
+	}{}
+	for _, c := range ciphertexts {
+		req.BatchInput = append(req.BatchInput, batchItem{Ciphertext: string(c)})
+	}
+
+	resp := struct {
+		Data struct {
+			BatchResults []struct {
+				Ciphertext string `json:"ciphertext"`
+				Error      string `json:"error"`
+			} `json:"batch_results"`
+		} `json:"data"`
+	}{}
+
+	err := vaultRequest(ctx, r.vaultClient, r.authWaiter, transitEndpoint(r.transitMount, "rewrap", key), req, &resp)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if len(resp.Data.BatchResults) != len(ciphertexts) {
+		return nil, microerror.Maskf(executionFailedError, "expected %d batch results, got %d", len(ciphertexts), len(resp.Data.BatchResults))
+	}
+
+	var rewrapped [][]byte
+	for i, item := range resp.Data.BatchResults {
+		if item.Error != "" {
+			return nil, microerror.Maskf(executionFailedError, "failed to rewrap batch item %d: %s", i, item.Error)
+		}
+		rewrapped = append(rewrapped, []byte(item.Ciphertext))
+	}
+
+	return rewrapped, nil
+}
@@ -0,0 +1,109 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestVaultRewrapper_Rewrap(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.URL.Path, "/v1/transit/rewrap/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+
+		var req struct {
+			BatchInput []struct {
+				Ciphertext string `json:"ciphertext"`
+			} `json:"batch_input"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Latest key version is 3.
+		var results []map[string]string
+		for _, item := range req.BatchInput {
+			parts := strings.SplitN(item.Ciphertext, ":", 3)
+			results = append(results, map[string]string{"ciphertext": "vault:v3:" + parts[2] + "x"})
+		}
+
+		resp := map[string]interface{}{
+			"data": map[string]interface{}{
+				"batch_results": results,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	r, err := NewVaultRewrapper(VaultRewrapperConfig{VaultClient: vaultClient})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	input := `# Comments and formatting are preserved.
+key1: vault:v1:YWJj  # trailing comment
+key2: "vault:v2:ZGVm=="
+key3: vault:v3:Z2hp
+{{- if .enabled }}
+key4: vault:v1:YWJj
+{{- end }}
+key5: vault:payments:v1:amts
+plain: value
+`
+	expected := `# Comments and formatting are preserved.
+key1: vault:v3:YWJjx  # trailing comment
+key2: "vault:v3:ZGVm==x"
+key3: vault:v3:Z2hp
+{{- if .enabled }}
+key4: vault:v3:YWJjx
+{{- end }}
+key5: vault:payments:v3:amtsx
+plain: value
+`
+
+	output, count, err := r.Rewrap(context.Background(), []byte(input))
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if string(output) != expected {
+		t.Fatalf("output = %q, want %q", output, expected)
+	}
+	if count != 4 {
+		t.Fatalf("count = %d, want %d", count, 4)
+	}
+	// One request per key.
+	if requests != 2 {
+		t.Fatalf("requests = %d, want %d", requests, 2)
+	}
+
+	output, count, err = r.Rewrap(context.Background(), []byte("plain: value\n"))
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if string(output) != "plain: value\n" || count != 0 {
+		t.Fatalf("output = %q, count = %d, want unchanged", output, count)
+	}
+	if requests != 2 {
+		t.Fatalf("requests = %d, want %d", requests, 2)
+	}
+}
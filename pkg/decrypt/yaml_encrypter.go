@@ -0,0 +1,109 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"gopkg.in/yaml.v3"
+)
+
+// secretRefKey is the key of objects referencing Kubernetes Secrets in
+// installation secret.yaml. They are resolved by the generator and must stay
+// in plaintext.
+const secretRefKey = "secretRef"
+
+type YAMLEncrypterConfig struct {
+	Encrypter Encrypter
+}
+
+// YAMLEncrypter encrypts all plaintext values of a YAML document. Values
+// which are already encrypted and secretRef objects are left untouched.
+// Comments and order of keys are preserved.
+type YAMLEncrypter struct {
+	encrypter Encrypter
+}
+
+func NewYAMLEncrypter(config YAMLEncrypterConfig) (*YAMLEncrypter, error) {
+	if config.Encrypter == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Encrypter must not be empty", config)
+	}
+
+	e := &YAMLEncrypter{
+		encrypter: config.Encrypter,
+	}
+
+	return e, nil
+}
+
+func (e *YAMLEncrypter) Encrypt(ctx context.Context, yamlData []byte) ([]byte, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(yamlData, &root)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Empty document.
+	if root.Kind == 0 {
+		return yamlData, nil
+	}
+
+	err = e.encryptNode(ctx, &root)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(&root)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (e *YAMLEncrypter) encryptNode(ctx context.Context, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			err := e.encryptNode(ctx, n)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	case yaml.MappingNode:
+		// Content contains keys and values interleaved. Only values
+		// are encrypted.
+		for i := 1; i < len(node.Content); i += 2 {
+			if node.Content[i-1].Value == secretRefKey && node.Content[i].Kind == yaml.MappingNode {
+				continue
+			}
+
+			err := e.encryptNode(ctx, node.Content[i])
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" || IsVaultCiphertext(node.Value) {
+			return nil
+		}
+
+		ciphertext, err := e.encrypter.Encrypt(ctx, []byte(node.Value))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		node.Value = string(ciphertext)
+		node.Tag = "!!str"
+		node.Style = 0
+	}
+
+	return nil
+}
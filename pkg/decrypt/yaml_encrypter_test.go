@@ -0,0 +1,96 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestYAMLEncrypter(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedResult string
+	}{
+		{
+			name: "case 0: nested values with comments",
+			input: `# installation secrets
+b:
+  c: secret # inline
+  d:
+  - one
+  - two
+a: vault:v1:already-encrypted
+empty: null
+port: 8080
+key: |
+  multi
+  line
+`,
+			expectedResult: `# installation secrets
+b:
+  c: vault:v1:c2VjcmV0 # inline
+  d:
+  - vault:v1:b25l
+  - vault:v1:dHdv
+a: vault:v1:already-encrypted
+empty: null
+port: vault:v1:ODA4MA==
+key: vault:v1:bXVsdGkKbGluZQo=
+`,
+		},
+		{
+			name:           "case 1: empty document",
+			input:          "",
+			expectedResult: "",
+		},
+		{
+			name: "case 2: secretRef objects",
+			input: `database:
+  user: admin
+  password:
+    secretRef:
+      namespace: giantswarm
+      name: database-credentials
+      key: password
+secretRef: plaintext
+`,
+			expectedResult: `database:
+  user: vault:v1:YWRtaW4=
+  password:
+    secretRef:
+      namespace: giantswarm
+      name: database-credentials
+      key: password
+secretRef: vault:v1:cGxhaW50ZXh0
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := NewYAMLEncrypter(YAMLEncrypterConfig{Encrypter: &testEncrypter{}})
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			result, err := e.Encrypt(context.Background(), []byte(tc.input))
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			if !cmp.Equal(tc.expectedResult, string(result)) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, string(result)))
+			}
+		})
+	}
+}
+
+type testEncrypter struct{}
+
+func (e *testEncrypter) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return []byte("vault:v1:" + base64.StdEncoding.EncodeToString(plaintext)), nil
+}
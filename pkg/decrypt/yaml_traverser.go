@@ -5,14 +5,28 @@ import (
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/valuemodifier"
+	"github.com/giantswarm/valuemodifier/path"
 )
 
 type YAMLTraverserConfig struct {
 	Decrypter Decrypter
+
+	// AllowPlaintext makes values which are not encrypted pass through
+	// untouched. Otherwise Traverse fails with unencryptedValueError when
+	// the document contains such values.
+	AllowPlaintext bool
+	// IsCiphertext tells encrypted values from plaintext ones. Defaults to
+	// IsVaultCiphertext.
+	IsCiphertext func(value string) bool
 }
 
+// YAMLTraverser decrypts encrypted values of YAML documents. Only values
+// recognised by IsCiphertext are passed to the decrypter.
 type YAMLTraverser struct {
 	decrypter Decrypter
+
+	allowPlaintext bool
+	isCiphertext   func(value string) bool
 }
 
 func NewYAMLTraverser(config YAMLTraverserConfig) (*YAMLTraverser, error) {
@@ -20,21 +34,125 @@ func NewYAMLTraverser(config YAMLTraverserConfig) (*YAMLTraverser, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Decrypter must not be empty", config)
 	}
 
+	if config.IsCiphertext == nil {
+		config.IsCiphertext = IsVaultCiphertext
+	}
+
 	t := &YAMLTraverser{
 		decrypter: config.Decrypter,
+
+		allowPlaintext: config.AllowPlaintext,
+		isCiphertext:   config.IsCiphertext,
 	}
 
 	return t, nil
 }
 
 func (t *YAMLTraverser) Traverse(ctx context.Context, yamlData []byte) ([]byte, error) {
+	ciphertextPaths, err := t.ciphertextPaths(yamlData)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Nothing to decrypt. Traversing with no selected paths would
+	// traverse all of them.
+	if len(ciphertextPaths) == 0 {
+		return yamlData, nil
+	}
+
+	batchDecrypter, ok := t.decrypter.(BatchDecrypter)
+	if !ok {
+		decrypted, err := traverse(yamlData, ciphertextPaths, newValueModifier(ctx, t.decrypter))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return decrypted, nil
+	}
+
+	// Collect all ciphertexts first, so they can be decrypted with as few
+	// requests as possible, and then replace them with plaintexts. Values
+	// are written back by the same traversal so the output is identical to
+	// decrypting values one by one.
+	collector := newCollectingModifier()
+	_, err = traverse(yamlData, ciphertextPaths, collector)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	plaintexts := map[string][]byte{}
+	if len(collector.values) > 0 {
+		decrypted, err := batchDecrypter.DecryptBatch(ctx, collector.values)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		for i, v := range collector.values {
+			plaintexts[string(v)] = decrypted[i]
+		}
+	}
+
+	decrypted, err := traverse(yamlData, ciphertextPaths, newLookupModifier(plaintexts))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return decrypted, nil
+}
+
+// ciphertextPaths returns paths of encrypted values. It fails when the
+// document contains values which are not encrypted and they are not
+// allowed. Null values are ignored.
+func (t *YAMLTraverser) ciphertextPaths(yamlData []byte) ([]string, error) {
+	c := path.DefaultConfig()
+	c.InputBytes = yamlData
+
+	pathService, err := path.New(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	paths, err := pathService.All()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var ciphertextPaths []string
+	var plaintextPaths []string
+	for _, p := range paths {
+		v, err := pathService.Get(p)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		switch value := v.(type) {
+		case nil:
+			continue
+		case string:
+			if t.isCiphertext(value) {
+				ciphertextPaths = append(ciphertextPaths, p)
+				continue
+			}
+		}
+		plaintextPaths = append(plaintextPaths, p)
+	}
+
+	if len(plaintextPaths) > 0 && !t.allowPlaintext {
+		return nil, microerror.Maskf(unencryptedValueError, "values at paths %q are not encrypted", plaintextPaths)
+	}
+
+	return ciphertextPaths, nil
+}
+
+// traverse modifies values at the given paths with m.
+func traverse(yamlData []byte, paths []string, m valuemodifier.ValueModifier) ([]byte, error) {
 	var err error
 	var modifier *valuemodifier.Service
 	{
 		c := valuemodifier.Config{
 			ValueModifiers: []valuemodifier.ValueModifier{
-				newValueModifier(ctx, t.decrypter),
+				m,
 			},
+			SelectFields: paths,
 		}
 
 		modifier, err = valuemodifier.New(c)
@@ -43,10 +161,10 @@ func (t *YAMLTraverser) Traverse(ctx context.Context, yamlData []byte) ([]byte,
 		}
 	}
 
-	decrypted, err := modifier.Traverse(yamlData)
+	modified, err := modifier.Traverse(yamlData)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	return decrypted, nil
+	return modified, nil
 }
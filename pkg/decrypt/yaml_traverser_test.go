@@ -20,11 +20,19 @@ var update = flag.Bool("update", false, "update .golden reference test files")
 func TestYAMLTraverser(t *testing.T) {
 	testCases := []struct {
 		name               string
+		decrypter          Decrypter
 		inputFile          string
 		expectedGoldenFile string
 	}{
 		{
 			name:               "case 0: regular secret yaml",
+			decrypter:          &testDecrypter{},
+			inputFile:          "secret.yaml.in",
+			expectedGoldenFile: "secret.yaml.golden",
+		},
+		{
+			name:               "case 1: regular secret yaml decrypted in batch",
+			decrypter:          &testBatchDecrypter{},
 			inputFile:          "secret.yaml.in",
 			expectedGoldenFile: "secret.yaml.golden",
 		},
@@ -59,7 +67,7 @@ func TestYAMLTraverser(t *testing.T) {
 			var traverser *YAMLTraverser
 			{
 				c := YAMLTraverserConfig{
-					Decrypter: &testDecrypter{},
+					Decrypter: tc.decrypter,
 				}
 
 				traverser, err = NewYAMLTraverser(c)
@@ -93,3 +101,143 @@ type testDecrypter struct{}
 func (d *testDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
 	return []byte("decrypted"), nil
 }
+
+type testBatchDecrypter struct {
+	testDecrypter
+}
+
+func (d *testBatchDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return nil, microerror.Maskf(executionFailedError, "Decrypt must not be called when DecryptBatch is implemented")
+}
+
+func (d *testBatchDecrypter) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	var plaintexts [][]byte
+	for range ciphertexts {
+		plaintexts = append(plaintexts, []byte("decrypted"))
+	}
+	return plaintexts, nil
+}
+
+func TestYAMLTraverser_batchOutputIdentical(t *testing.T) {
+	input := []byte(`b:
+  c: vault:v1:c
+  list:
+  - key: vault:v1:1
+  - key: vault:v1:c
+a: vault:v1:a
+number: 12345
+`)
+
+	var outputs []string
+	for _, d := range []Decrypter{&reverseDecrypter{}, &reverseBatchDecrypter{}} {
+		traverser, err := NewYAMLTraverser(YAMLTraverserConfig{Decrypter: d, AllowPlaintext: true})
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+
+		result, err := traverser.Traverse(context.Background(), input)
+		if err != nil {
+			t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+		}
+		outputs = append(outputs, string(result))
+	}
+
+	if !cmp.Equal(outputs[0], outputs[1]) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(outputs[0], outputs[1]))
+	}
+}
+
+func TestYAMLTraverser_plaintext(t *testing.T) {
+	input := []byte(`password: vault:v1:c2VjcmV0
+port: 8080
+username: admin
+empty:
+`)
+
+	testCases := []struct {
+		name           string
+		allowPlaintext bool
+		expectedResult string
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:           "case 0: plaintext values are not allowed",
+			allowPlaintext: false,
+			errorMatcher:   IsUnencryptedValue,
+		},
+		{
+			name:           "case 1: plaintext values pass through untouched",
+			allowPlaintext: true,
+			expectedResult: `empty: null
+password: 0VmcjV2c:1v:tluav
+port: 8080
+username: admin
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Log(tc.name)
+
+			for _, d := range []Decrypter{&reverseDecrypter{}, &reverseBatchDecrypter{}} {
+				c := YAMLTraverserConfig{
+					Decrypter:      d,
+					AllowPlaintext: tc.allowPlaintext,
+				}
+
+				traverser, err := NewYAMLTraverser(c)
+				if err != nil {
+					t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+				}
+
+				result, err := traverser.Traverse(context.Background(), input)
+				switch {
+				case err == nil && tc.errorMatcher == nil:
+					// correct; carry on
+				case err != nil && tc.errorMatcher == nil:
+					t.Fatalf("error == %#v, want nil", err)
+				case err == nil && tc.errorMatcher != nil:
+					t.Fatalf("error == nil, want non-nil")
+				case !tc.errorMatcher(err):
+					t.Fatalf("error == %#v, want matching", err)
+				}
+
+				if tc.errorMatcher != nil {
+					return
+				}
+
+				if !cmp.Equal(tc.expectedResult, string(result)) {
+					t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, string(result)))
+				}
+			}
+		})
+	}
+}
+
+// reverseDecrypter "decrypts" values by reversing them.
+type reverseDecrypter struct{}
+
+func (d *reverseDecrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var reversed []byte
+	for i := len(ciphertext) - 1; i >= 0; i-- {
+		reversed = append(reversed, ciphertext[i])
+	}
+	return reversed, nil
+}
+
+type reverseBatchDecrypter struct {
+	reverseDecrypter
+}
+
+func (d *reverseBatchDecrypter) DecryptBatch(ctx context.Context, ciphertexts [][]byte) ([][]byte, error) {
+	var plaintexts [][]byte
+	for _, c := range ciphertexts {
+		p, err := d.Decrypt(ctx, c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		plaintexts = append(plaintexts, p)
+	}
+	return plaintexts, nil
+}
@@ -0,0 +1,408 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+// mergeDirectiveKey is a special key of a patch map selecting list merge
+// strategies for its sibling keys, e.g.:
+//
+//	ingress:
+//	  $merge:
+//	    hosts: append
+//	    tolerations: merge-by-key:key
+//	  hosts:
+//	  - b.example.com
+//	  tolerations:
+//	  - key: dedicated
+//	    effect: NoExecute
+//
+// Lists without a strategy are patched value by value (see applyPatch).
+// The directive is removed from the patch before it is applied.
+const mergeDirectiveKey = "$merge"
+
+// deleteDirective is a special patch value removing the key from the patched
+// document, e.g.:
+//
+//	ingress:
+//	  annotations: $delete
+//
+// The whole subtree under the key is removed. Keys missing in the patched
+// document are ignored.
+const deleteDirective = "$delete"
+
+// ListMergeStrategy defines how a list in a patch is merged with a list in
+// the patched document.
+type ListMergeStrategy string
+
+const (
+	// ListMergeReplace replaces the whole list with the list from the
+	// patch.
+	ListMergeReplace ListMergeStrategy = "replace"
+	// ListMergeAppend appends items from the patch to the list.
+	ListMergeAppend ListMergeStrategy = "append"
+	// ListMergePrepend prepends items from the patch to the list.
+	ListMergePrepend ListMergeStrategy = "prepend"
+	// ListMergeByKey merges map items from the patch into items of the
+	// list with the same value under the merge key. Items without a match
+	// are appended. It is written as "merge-by-key:<key>" in the
+	// directive.
+	ListMergeByKey ListMergeStrategy = "merge-by-key"
+)
+
+// patchDirectives are directives split from a patch by splitDirectives.
+type patchDirectives struct {
+	deletions [][]string
+	merges    []listMerge
+}
+
+func (d patchDirectives) empty() bool {
+	return len(d.deletions) == 0 && len(d.merges) == 0
+}
+
+type listMerge struct {
+	path     []string
+	strategy ListMergeStrategy
+	key      string
+}
+
+// IsDirectivePath returns true when the path in valuemodifier/path format
+// points to a patch directive rather than a value.
+func IsDirectivePath(path string) bool {
+	for _, e := range strings.Split(path, ".") {
+		if e == mergeDirectiveKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDeleteDirective returns true when the patch value removes its key from
+// the patched document.
+func IsDeleteDirective(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && s == deleteDirective
+}
+
+// splitDirectives removes directives from the patch and returns them
+// separately. The patch is returned unchanged when it contains no directives
+// or is not a valid YAML document. In the latter case applyPatch reports the
+// error.
+func splitDirectives(patch []byte) ([]byte, patchDirectives, error) {
+	var tree interface{}
+	err := yaml.Unmarshal(patch, &tree)
+	if err != nil {
+		return patch, patchDirectives{}, nil
+	}
+
+	var directives patchDirectives
+	tree, err = collectDirectives(tree, nil, &directives)
+	if err != nil {
+		return nil, patchDirectives{}, microerror.Mask(err)
+	}
+
+	if directives.empty() {
+		return patch, patchDirectives{}, nil
+	}
+
+	out, err := marshalTree(tree)
+	if err != nil {
+		return nil, patchDirectives{}, microerror.Mask(err)
+	}
+
+	return out, directives, nil
+}
+
+func collectDirectives(node interface{}, path []string, directives *patchDirectives) (interface{}, error) {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return node, nil
+	}
+
+	if directive, ok := m[mergeDirectiveKey]; ok {
+		strategies, ok := directive.(map[string]interface{})
+		if !ok {
+			return nil, microerror.Maskf(invalidConfigError, "%#q at %#q must be a map of keys to list merge strategies", mergeDirectiveKey, joinPath(path))
+		}
+
+		for k, v := range strategies {
+			s, ok := v.(string)
+			if !ok {
+				return nil, microerror.Maskf(invalidConfigError, "list merge strategy for %#q must be a string", joinPath(append(path, k)))
+			}
+
+			merge, err := parseListMerge(s)
+			if err != nil {
+				return nil, microerror.Maskf(invalidConfigError, "invalid list merge strategy for %#q: %s", joinPath(append(path, k)), err)
+			}
+			merge.path = append(append([]string{}, path...), k)
+
+			directives.merges = append(directives.merges, merge)
+		}
+
+		delete(m, mergeDirectiveKey)
+	}
+
+	for k, v := range m {
+		childPath := append(append([]string{}, path...), k)
+
+		if IsDeleteDirective(v) {
+			directives.deletions = append(directives.deletions, childPath)
+			delete(m, k)
+			continue
+		}
+
+		var lenBefore int
+		if vm, ok := v.(map[string]interface{}); ok {
+			lenBefore = len(vm)
+		}
+
+		child, err := collectDirectives(v, childPath, directives)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		// Remove maps which contained only directives so they do
+		// not overwrite values in the patched document.
+		if cm, ok := child.(map[string]interface{}); ok && len(cm) == 0 && lenBefore > 0 {
+			delete(m, k)
+			continue
+		}
+		m[k] = child
+	}
+
+	return m, nil
+}
+
+func parseListMerge(s string) (listMerge, error) {
+	switch ListMergeStrategy(s) {
+	case ListMergeReplace, ListMergeAppend, ListMergePrepend:
+		return listMerge{strategy: ListMergeStrategy(s)}, nil
+	}
+
+	prefix := string(ListMergeByKey) + ":"
+	if strings.HasPrefix(s, prefix) && len(s) > len(prefix) {
+		return listMerge{strategy: ListMergeByKey, key: strings.TrimPrefix(s, prefix)}, nil
+	}
+
+	return listMerge{}, microerror.Maskf(invalidConfigError, "expected one of %#q, %#q, %#q or %#q, got %#q", ListMergeReplace, ListMergeAppend, ListMergePrepend, prefix+"<key>", s)
+}
+
+// applyDirectives removes paths selected by deletion directives from base and
+// merges lists selected by merge directives from patch into base. The merged
+// lists are removed from the patch so the rest of it can be applied value by
+// value.
+func applyDirectives(base, patch []byte, directives patchDirectives) ([]byte, []byte, error) {
+	var baseTree interface{}
+	err := yaml.Unmarshal(base, &baseTree)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	if baseTree == nil {
+		baseTree = map[string]interface{}{}
+	}
+
+	var patchTree interface{}
+	err = yaml.Unmarshal(patch, &patchTree)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	for _, deletion := range directives.deletions {
+		removeTreeValue(baseTree, deletion)
+	}
+
+	for _, merge := range directives.merges {
+		patchValue, ok := getTreeValue(patchTree, merge.path)
+		if !ok {
+			continue
+		}
+		patchList, ok := patchValue.([]interface{})
+		if !ok {
+			return nil, nil, microerror.Maskf(invalidConfigError, "value at %#q must be a list to use %#q list merge strategy", joinPath(merge.path), merge.strategy)
+		}
+
+		var baseList []interface{}
+		if baseValue, ok := getTreeValue(baseTree, merge.path); ok && baseValue != nil {
+			baseList, ok = baseValue.([]interface{})
+			if !ok {
+				return nil, nil, microerror.Maskf(invalidConfigError, "patched value at %#q must be a list to use %#q list merge strategy", joinPath(merge.path), merge.strategy)
+			}
+		}
+
+		merged, err := mergeLists(baseList, patchList, merge)
+		if err != nil {
+			return nil, nil, microerror.Mask(err)
+		}
+
+		err = setTreeValue(baseTree, merge.path, merged)
+		if err != nil {
+			return nil, nil, microerror.Mask(err)
+		}
+		deleteTreeValue(patchTree, merge.path)
+	}
+
+	base, err = marshalTree(baseTree)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	patch, err = marshalTree(patchTree)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	return base, patch, nil
+}
+
+func mergeLists(base, patch []interface{}, merge listMerge) ([]interface{}, error) {
+	switch merge.strategy {
+	case ListMergeReplace:
+		return patch, nil
+	case ListMergeAppend:
+		return append(append([]interface{}{}, base...), patch...), nil
+	case ListMergePrepend:
+		return append(append([]interface{}{}, patch...), base...), nil
+	}
+
+	// ListMergeByKey
+	merged := append([]interface{}{}, base...)
+	for _, item := range patch {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, microerror.Maskf(invalidConfigError, "items at %#q must be maps to use %#q list merge strategy", joinPath(merge.path), merge.strategy)
+		}
+		key, ok := m[merge.key]
+		if !ok {
+			return nil, microerror.Maskf(invalidConfigError, "items at %#q must have %#q key to use %#q list merge strategy", joinPath(merge.path), merge.key, merge.strategy)
+		}
+
+		found := false
+		for i, baseItem := range merged {
+			bm, ok := baseItem.(map[string]interface{})
+			if !ok || !reflect.DeepEqual(bm[merge.key], key) {
+				continue
+			}
+
+			merged[i] = mergeMaps(bm, m)
+			found = true
+			break
+		}
+		if !found {
+			merged = append(merged, m)
+		}
+	}
+
+	return merged, nil
+}
+
+// mergeMaps returns base deeply merged with patch. Values other than maps are
+// replaced.
+func mergeMaps(base, patch map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range patch {
+		bm, ok1 := merged[k].(map[string]interface{})
+		pm, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			merged[k] = mergeMaps(bm, pm)
+			continue
+		}
+		merged[k] = v
+	}
+
+	return merged
+}
+
+func getTreeValue(tree interface{}, path []string) (interface{}, bool) {
+	current := tree
+	for _, k := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[k]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func setTreeValue(tree interface{}, path []string, value interface{}) error {
+	current, ok := tree.(map[string]interface{})
+	if !ok {
+		return microerror.Maskf(invalidConfigError, "patched document must be a map to set %#q", joinPath(path))
+	}
+
+	for _, k := range path[:len(path)-1] {
+		next, ok := current[k]
+		if !ok || next == nil {
+			next = map[string]interface{}{}
+			current[k] = next
+		}
+		current, ok = next.(map[string]interface{})
+		if !ok {
+			return microerror.Maskf(invalidConfigError, "value at %#q must be a map to set %#q", k, joinPath(path))
+		}
+	}
+	current[path[len(path)-1]] = value
+
+	return nil
+}
+
+// removeTreeValue deletes the value under path. Maps left empty by the
+// deletion are kept.
+func removeTreeValue(tree interface{}, path []string) {
+	parent, ok := getTreeValue(tree, path[:len(path)-1])
+	if !ok {
+		return
+	}
+	if m, ok := parent.(map[string]interface{}); ok {
+		delete(m, path[len(path)-1])
+	}
+}
+
+// deleteTreeValue deletes the value under path and removes maps left empty
+// by the deletion.
+func deleteTreeValue(tree interface{}, path []string) {
+	m, ok := tree.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if len(path) == 1 {
+		delete(m, path[0])
+		return
+	}
+
+	deleteTreeValue(m[path[0]], path[1:])
+	if child, ok := m[path[0]].(map[string]interface{}); ok && len(child) == 0 {
+		delete(m, path[0])
+	}
+}
+
+func marshalTree(tree interface{}) ([]byte, error) {
+	if m, ok := tree.(map[string]interface{}); ok && len(m) == 0 {
+		return []byte{}, nil
+	}
+
+	out, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return out, nil
+}
+
+func joinPath(path []string) string {
+	return strings.Join(path, ".")
+}
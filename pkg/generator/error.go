@@ -4,8 +4,18 @@ import (
 	"github.com/giantswarm/microerror"
 
 	"github.com/giantswarm/config-controller/pkg/github"
+	"github.com/giantswarm/config-controller/pkg/localfs"
 )
 
+var includeCycleError = &microerror.Error{
+	Kind: "includeCycleError",
+}
+
+// IsIncludeCycle asserts includeCycleError.
+func IsIncludeCycle(err error) bool {
+	return microerror.Cause(err) == includeCycleError
+}
+
 var invalidConfigError = &microerror.Error{
 	Kind: "invalidConfigError",
 }
@@ -15,6 +25,15 @@ func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
 
+var invalidSecretRefError = &microerror.Error{
+	Kind: "invalidSecretRefError",
+}
+
+// IsInvalidSecretRef asserts invalidSecretRefError.
+func IsInvalidSecretRef(err error) bool {
+	return microerror.Cause(err) == invalidSecretRefError
+}
+
 var notFoundError = &microerror.Error{
 	Kind: "notFoundError",
 }
@@ -24,6 +43,45 @@ func IsNotFound(err error) bool {
 	if github.IsNotFound(err) {
 		return true
 	}
+	if localfs.IsNotFound(err) {
+		return true
+	}
 
 	return microerror.Cause(err) == notFoundError
 }
+
+var schemaValidationError = &microerror.Error{
+	Kind: "schemaValidationError",
+}
+
+// IsSchemaValidation asserts schemaValidationError.
+func IsSchemaValidation(err error) bool {
+	return microerror.Cause(err) == schemaValidationError
+}
+
+var secretRefNotConfiguredError = &microerror.Error{
+	Kind: "secretRefNotConfiguredError",
+}
+
+// IsSecretRefNotConfigured asserts secretRefNotConfiguredError.
+func IsSecretRefNotConfigured(err error) bool {
+	return microerror.Cause(err) == secretRefNotConfiguredError
+}
+
+var vaultKVNotAllowedError = &microerror.Error{
+	Kind: "vaultKVNotAllowedError",
+}
+
+// IsVaultKVNotAllowed asserts vaultKVNotAllowedError.
+func IsVaultKVNotAllowed(err error) bool {
+	return microerror.Cause(err) == vaultKVNotAllowedError
+}
+
+var vaultKVNotConfiguredError = &microerror.Error{
+	Kind: "vaultKVNotConfiguredError",
+}
+
+// IsVaultKVNotConfigured asserts vaultKVNotConfiguredError.
+func IsVaultKVNotConfigured(err error) bool {
+	return microerror.Cause(err) == vaultKVNotConfiguredError
+}
@@ -0,0 +1,164 @@
+package generator
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	maskedValue = "<masked>"
+)
+
+// Explanation describes how a single generated value was resolved.
+type Explanation struct {
+	// Path of the generated value.
+	Path string
+	// Secret is true when the value is generated in secret-values.
+	Secret bool
+	// Steps are contributions to the value in the order they were
+	// applied.
+	Steps []ExplanationStep
+	// Value is the final generated value in YAML format. It is masked
+	// when Secret is true.
+	Value string
+}
+
+type ExplanationStep struct {
+	Source
+	// Snippet is the contributed value for template data files
+	// (config.yaml, config.yaml.patch, secret.yaml) or the matching lines
+	// for templates, include files and patches. Secret values are masked.
+	Snippet string
+}
+
+// Explain generates configuration for the app and returns the explanation of
+// the value under the given path. The path is in valuemodifier/path format
+// (e.g. "a.b.[0].c"). It returns error matched by IsNotFound when the path is
+// not generated.
+func (g Generator) Explain(ctx context.Context, app, path string) (*Explanation, error) {
+	path = strings.TrimPrefix(path, ".")
+
+	prov := newProvenance()
+	configmap, secret, err := g.generateRawConfig(ctx, app, prov)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var isSecret bool
+	var output string
+	var sources []Source
+	if s, ok := prov.Configmap[path]; ok {
+		output = configmap
+		sources = s
+	} else if s, ok := prov.Secret[path]; ok {
+		isSecret = true
+		output = secret
+		sources = s
+	} else {
+		return nil, microerror.Maskf(notFoundError, "path %#q is not generated for app %#q", path, app)
+	}
+
+	e := &Explanation{
+		Path:   path,
+		Secret: isSecret,
+	}
+
+	for _, source := range sources {
+		snippet, err := g.explainSource(source, isSecret)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		e.Steps = append(e.Steps, ExplanationStep{
+			Source:  source,
+			Snippet: snippet,
+		})
+	}
+
+	if isSecret {
+		e.Value = maskedValue
+	} else {
+		values, err := yamlValues([]byte(output))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		e.Value, err = marshalValue(values[path])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return e, nil
+}
+
+func (g Generator) explainSource(source Source, isSecret bool) (string, error) {
+	data, err := g.fs.ReadFile(source.Filepath)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	isDataFile := !strings.HasSuffix(source.Filepath, ".template") && !strings.HasSuffix(source.Filepath, "values.yaml.patch")
+
+	if isDataFile {
+		if isSecret {
+			return source.Path + ": " + maskedValue, nil
+		}
+
+		values, err := yamlValues(data)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		v, err := marshalValue(values[source.Path])
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return source.Path + ": " + v, nil
+	}
+
+	// Secret patches contain encrypted values directly.
+	if isSecret && source.Layer == LayerAppPatch {
+		return source.Path + ": " + maskedValue, nil
+	}
+
+	// Include rendered as a whole.
+	if source.Path == "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	return strings.Join(findKeyLines(string(data), lastPathKey(source.Path)), "\n"), nil
+}
+
+// findKeyLines returns lines of the template defining the given key.
+func findKeyLines(text, key string) []string {
+	re := regexp.MustCompile(`^\s*(-\s+)?["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if re.MatchString(l) {
+			lines = append(lines, strings.TrimRight(l, " \t"))
+		}
+	}
+
+	return lines
+}
+
+func lastPathKey(path string) string {
+	split := strings.Split(path, ".")
+	return strings.ReplaceAll(split[len(split)-1], `\`, "")
+}
+
+func marshalValue(v interface{}) (string, error) {
+	bs, err := yaml.Marshal(v)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return strings.TrimSpace(string(bs)), nil
+}
@@ -3,10 +3,10 @@ package generator
 import (
 	"bytes"
 	"context"
-	"fmt"
-	"os"
 	"path"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/ghodss/yaml"
@@ -31,6 +31,7 @@ import (
 				azure-operator/
 					configmap-values.yaml.template
 					secret-values.yaml.template
+					values.schema.json (optional)
 		installations/
 			ghost/
 				...
@@ -48,15 +49,30 @@ type Config struct {
 	DecryptTraverser DecryptTraverser
 
 	Installation string
-	Verbose      bool
+	// SecretRefResolver is optional. It resolves secretRef objects of
+	// installation secret.yaml. When empty, installations with such
+	// objects fail to generate.
+	SecretRefResolver SecretRefResolver
+	// Tracer is optional. When set, it receives events of configuration
+	// generation.
+	Tracer Tracer
+	// VaultKVReader is optional. It reads secrets for the vaultKV template
+	// function. When empty, templates using the function fail to render.
+	VaultKVReader VaultKVReader
 }
 
 type Generator struct {
 	fs               Filesystem
 	decryptTraverser DecryptTraverser
 
-	installation string
-	verbose      bool
+	installation      string
+	secretRefResolver SecretRefResolver
+	tracer            Tracer
+	vaultKVReader     VaultKVReader
+
+	// templateData is optional preloaded template data. See
+	// WithTemplateData.
+	templateData *TemplateData
 }
 
 func New(config Config) (*Generator, error) {
@@ -74,8 +90,10 @@ func New(config Config) (*Generator, error) {
 		fs:               config.Fs,
 		decryptTraverser: config.DecryptTraverser,
 
-		installation: config.Installation,
-		verbose:      config.Verbose,
+		installation:      config.Installation,
+		secretRefResolver: config.SecretRefResolver,
+		tracer:            config.Tracer,
+		vaultKVReader:     config.VaultKVReader,
 	}
 
 	return &g, nil
@@ -83,157 +101,397 @@ func New(config Config) (*Generator, error) {
 
 // generateRawConfig creates final configmap values and secret values for helm to
 // use by performing the following operations:
-// 1. Get configmap template data and patch it with installation-specific
-//    overrides (if available)
+// 1. Get configmap template data and patch it with intermediate layer and
+//    installation-specific overrides (if available) (see LoadTemplateData)
 // 2. Get global configmap template for the app and render it with template
 //    data (result of 1.)
-// 3. Get installation-specific configmap patch for the app template (if available)
-// 4. Patch global template (result of 2.) with installation-specific (result
-//    of 3.) app overrides
-// 5. Get installation-specific secret template data and decrypt it
+// 3. Get intermediate layer and installation-specific configmap patches for
+//    the app template (if available)
+// 4. Patch global template (result of 2.) with layer and
+//    installation-specific (result of 3.) app overrides in order
+// 5. Get installation-specific secret template data, decrypt it and resolve
+//    secretRef objects (see LoadTemplateData)
 // 6. Get global secret template for the app (if available) and render it with
-//    installation secret template data (result of 5.)
-// 7. Get installation-specific secret template patch (if available) and
-//    decrypt it
+//    installation secret template data (result of 5.) and secrets read with
+//    the vaultKV function
+// 7. Get intermediate layer and installation-specific secret template patches
+//    (if available), render them the same way and decrypt them
 // 8. Patch secret template (result of 6.) with decrypted patch values (result
-//    of 7.)
-func (g Generator) generateRawConfig(ctx context.Context, app string) (configmap string, secret string, err error) {
-	// 1.
-	configmapContext, err := g.getWithPatchIfExists(
-		ctx,
-		"default/config.yaml",
-		"installations/"+g.installation+"/config.yaml.patch",
-	)
+//    of 7.) in order
+//
+// Intermediate layers are declared in installations/<name>/metadata.yaml. See
+// installationMetadata for details.
+//
+// When prov is not nil, sources of all generated values are recorded in it.
+func (g Generator) generateRawConfig(ctx context.Context, app string, prov *Provenance) (configmap string, secret string, err error) {
+	patchDirs, err := g.patchDirs()
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
-	g.logMessage(ctx, "loaded patched config values")
 
-	// 2.
-	g.logMessage(ctx, "rendering configmap-values")
-	configmapBase, err := g.getRenderedTemplate(
-		ctx,
-		"default/apps/"+app+"/configmap-values.yaml.template",
-		configmapContext,
-	)
-	if err != nil {
-		return "", "", microerror.Mask(err)
+	// 1. and 5.
+	data := g.templateData
+	if data == nil {
+		data, err = g.LoadTemplateData(ctx)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
 	}
-	g.logMessage(ctx, "rendered configmap-values template")
+	configmapContext := data.configmap
+	secretContext := data.secret
 
-	// 3.
-	var configmapPatch string
-	{
-		g.logMessage(ctx, "rendering configmap-values patch (if it exists)")
-		filepath := "installations/" + g.installation + "/apps/" + app + "/configmap-values.yaml.patch"
-		patch, err := g.getRenderedTemplate(ctx, filepath, configmapContext)
-		if IsNotFound(err) {
-			configmapPatch = ""
-		} else if err != nil {
+	configFilepath := "default/config.yaml"
+	var configPatchFilepaths []string
+	for _, dir := range patchDirs {
+		configPatchFilepaths = append(configPatchFilepaths, dir+"/config.yaml.patch")
+	}
+
+	var configmapContextSources map[string][]Source
+	if prov != nil {
+		layers := map[string]Layer{
+			configFilepath: LayerDefault,
+		}
+		for _, filepath := range configPatchFilepaths {
+			layers[filepath] = patchLayer(filepath, LayerInstallation)
+		}
+		configmapContextSources, err = g.dataSources(layers, append([]string{configFilepath}, configPatchFilepaths...)...)
+		if err != nil {
 			return "", "", microerror.Mask(err)
-		} else {
-			configmapPatch = patch
-			g.logMessage(ctx, "rendered configmap-values patch")
 		}
 	}
 
-	// 4.
-	configmap, err = applyPatch(
+	// 2.
+	configmapTemplateFilepath := "default/apps/" + app + "/configmap-values.yaml.template"
+	finishStep := g.startStep(ctx, app, StepConfigmapTemplate, configmapTemplateFilepath)
+	configmap, err = g.getRenderedTemplate(
 		ctx,
-		[]byte(configmapBase),
-		[]byte(configmapPatch),
+		configmapTemplateFilepath,
+		configmapContext,
+		disallowedVaultKV,
 	)
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
-	if configmapPatch != "" {
-		g.logMessage(ctx, "patched configmap-values")
-	}
 
-	// 5.
-	secretContext, err := g.getWithPatchIfExists(
-		ctx,
-		"installations/"+g.installation+"/secret.yaml",
-		"",
-	)
+	// Configmap values are always formatted by applyPatch, even when
+	// there are no patches.
+	configmap, err = applyPatch(ctx, []byte(configmap), []byte(""))
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
-	g.logMessage(ctx, "loaded installation secret")
+	finishStep(configmap, false)
 
-	decryptedBytes, err := g.decryptTraverser.Traverse(ctx, []byte(secretContext))
-	if err != nil {
-		return "", "", microerror.Mask(err)
+	if prov != nil {
+		prov.Configmap, err = g.traceTemplate(ctx, configmapTemplateFilepath, LayerDefault, configmapContext, configmapContextSources)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+	}
+
+	for _, dir := range patchDirs {
+		// 3.
+		var configmapPatch string
+		filepath := dir + "/apps/" + app + "/configmap-values.yaml.patch"
+		{
+			finishStep := g.startStep(ctx, app, StepConfigmapPatch, filepath)
+			patch, err := g.getRenderedTemplate(ctx, filepath, configmapContext, disallowedVaultKV)
+			if IsNotFound(err) {
+				finishStep("", true)
+				continue
+			} else if err != nil {
+				return "", "", microerror.Mask(err)
+			}
+			configmapPatch = patch
+			finishStep(configmapPatch, false)
+
+			if prov != nil {
+				patchSources, err := g.traceTemplate(ctx, filepath, patchLayer(filepath, LayerAppPatch), configmapContext, configmapContextSources)
+				if err != nil {
+					return "", "", microerror.Mask(err)
+				}
+				mergeSources(prov.Configmap, patchSources)
+			}
+		}
+
+		// 4.
+		finishStep := g.startStep(ctx, app, StepConfigmapPatchApply, filepath)
+		configmap, err = applyPatch(
+			ctx,
+			[]byte(configmap),
+			[]byte(configmapPatch),
+		)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+		g.trace(ctx, PatchApplied{Filepath: filepath, Target: "configmap-values"})
+		finishStep(configmap, false)
+	}
+
+	var secretContextSources map[string][]Source
+	if prov != nil {
+		secretFilepath := secretFilepath(g.installation)
+		layers := map[string]Layer{
+			secretFilepath: LayerInstallation,
+		}
+		secretContextSources, err = g.dataSources(layers, secretFilepath)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
 	}
-	secretContext = string(decryptedBytes)
-	g.logMessage(ctx, "decrypted installation secret")
 
 	// 6.
+	secretTemplateFilepath := "default/apps/" + app + "/secret-values.yaml.template"
+	finishStep = g.startStep(ctx, app, StepSecretTemplate, secretTemplateFilepath)
 	secretTemplate, err := g.getWithPatchIfExists(
 		ctx,
-		"default/apps/"+app+"/secret-values.yaml.template",
-		"",
+		secretTemplateFilepath,
 	)
 	if IsNotFound(err) {
-		g.logMessage(ctx, "secret-values template not found, generated configmap")
+		finishStep("", true)
+		if prov != nil {
+			err = prov.prune(configmap, "")
+			if err != nil {
+				return "", "", microerror.Mask(err)
+			}
+		}
 		return configmap, "", nil
 	} else if err != nil {
 		return "", "", microerror.Mask(err)
 	}
-	g.logMessage(ctx, "loaded secret-values template")
 
-	secret, err = g.renderTemplate(ctx, secretTemplate, secretContext)
+	secret, err = g.renderTemplate(ctx, secretTemplate, secretContext, g.readVaultKV(ctx, nil))
 	if err != nil {
 		return "", "", microerror.Mask(err)
 	}
-	g.logMessage(ctx, "rendered secret-values")
+	finishStep(secret, false)
 
-	// 7.
-	var secretPatch string
-	{
-		filepath := "installations/" + g.installation + "/apps/" + app + "/secret-values.yaml.patch"
-		patch, err := g.getRenderedTemplate(ctx, filepath, secretContext)
-		if IsNotFound(err) {
-			secretPatch = ""
-		} else if err != nil {
+	if prov != nil {
+		prov.Secret, err = g.traceTemplate(ctx, secretTemplateFilepath, LayerDefault, secretContext, secretContextSources)
+		if err != nil {
 			return "", "", microerror.Mask(err)
-		} else {
-			g.logMessage(ctx, "loaded secret-values patch")
-			decryptedBytes, err := g.decryptTraverser.Traverse(ctx, []byte(patch))
+		}
+	}
+
+	for _, dir := range patchDirs {
+		// 7.
+		var secretPatch string
+		var secretPatchDirectives patchDirectives
+		filepath := dir + "/apps/" + app + "/secret-values.yaml.patch"
+		{
+			finishStep := g.startStep(ctx, app, StepSecretPatch, filepath)
+			var vaultKVReads int
+			patch, err := g.getRenderedTemplate(ctx, filepath, secretContext, g.readVaultKV(ctx, &vaultKVReads))
+			if IsNotFound(err) {
+				finishStep("", true)
+				continue
+			} else if err != nil {
+				return "", "", microerror.Mask(err)
+			}
+
+			// Directives are not encrypted so they are split
+			// from the patch before decryption.
+			patchBytes, directives, err := splitDirectives([]byte(patch))
+			if err != nil {
+				return "", "", microerror.Mask(err)
+			}
+			secretPatchDirectives = directives
+
+			var vaultKVValues map[string]interface{}
+			if vaultKVReads > 0 {
+				patchBytes, vaultKVValues, err = g.maskVaultKVValues(ctx, filepath, secretContext, patchBytes)
+				if err != nil {
+					return "", "", microerror.Mask(err)
+				}
+			}
+
+			decryptedBytes, err := g.decrypt(ctx, filepath, patchBytes)
 			if err != nil {
 				return "", "", microerror.Mask(err)
 			}
+
+			if len(vaultKVValues) > 0 {
+				decryptedBytes, err = restoreVaultKVValues(decryptedBytes, vaultKVValues)
+				if err != nil {
+					return "", "", microerror.Mask(err)
+				}
+			}
 			secretPatch = string(decryptedBytes)
-			g.logMessage(ctx, "decrypted secret-values patch")
+			finishStep(secretPatch, false)
+
+			if prov != nil {
+				patchSources, err := g.traceTemplate(ctx, filepath, patchLayer(filepath, LayerAppPatch), secretContext, secretContextSources)
+				if err != nil {
+					return "", "", microerror.Mask(err)
+				}
+				mergeSources(prov.Secret, patchSources)
+			}
 		}
+
+		// 8.
+		finishStep := g.startStep(ctx, app, StepSecretPatchApply, filepath)
+		secret, err = applyPatchWithDirectives(
+			ctx,
+			[]byte(secret),
+			[]byte(secretPatch),
+			secretPatchDirectives,
+		)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+		g.trace(ctx, PatchApplied{Filepath: filepath, Target: "secret-values"})
+		finishStep(secret, false)
+	}
+
+	if prov != nil {
+		err = prov.prune(configmap, secret)
+		if err != nil {
+			return "", "", microerror.Mask(err)
+		}
+	}
+
+	return configmap, secret, nil
+}
+
+// TemplateData is configmap and secret template data of an installation
+// shared by all apps.
+type TemplateData struct {
+	configmap string
+	secret    string
+}
+
+// LoadTemplateData loads default/config.yaml patched with intermediate layer
+// and installation config.yaml.patch files and decrypted installation
+// secret.yaml with resolved secretRef objects. See WithTemplateData.
+func (g Generator) LoadTemplateData(ctx context.Context) (*TemplateData, error) {
+	patchDirs, err := g.patchDirs()
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	// 8.
-	if secretPatch == "" {
-		g.logMessage(ctx, "generated configmap and secret")
-		return configmap, secret, nil
+	var configPatchFilepaths []string
+	for _, dir := range patchDirs {
+		configPatchFilepaths = append(configPatchFilepaths, dir+"/config.yaml.patch")
 	}
-	secret, err = applyPatch(
+
+	finishStep := g.startStep(ctx, "", StepConfigData, "default/config.yaml")
+	configmapContext, err := g.getWithPatchIfExists(
 		ctx,
-		[]byte(secret),
-		[]byte(secretPatch),
+		"default/config.yaml",
+		configPatchFilepaths...,
 	)
 	if err != nil {
-		return "", "", microerror.Mask(err)
+		return nil, microerror.Mask(err)
 	}
-	g.logMessage(ctx, "patched secret-values, generated configmap and secret")
+	finishStep(configmapContext, false)
 
-	return configmap, secret, nil
+	finishStep = g.startStep(ctx, "", StepSecretData, secretFilepath(g.installation))
+	secretContext, err := g.getWithPatchIfExists(
+		ctx,
+		secretFilepath(g.installation),
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// secretRef objects are not encrypted so they are masked before
+	// decryption and resolved after it.
+	secretBytes, secretRefs, err := maskSecretRefs([]byte(secretContext))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	decryptedBytes, err := g.decrypt(ctx, secretFilepath(g.installation), secretBytes)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if len(secretRefs) > 0 {
+		decryptedBytes, err = g.resolveSecretRefs(ctx, decryptedBytes, secretRefs)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+	finishStep(string(decryptedBytes), false)
+
+	data := &TemplateData{
+		configmap: configmapContext,
+		secret:    string(decryptedBytes),
+	}
+
+	return data, nil
+}
+
+// WithTemplateData returns a copy of the generator which uses given template
+// data instead of loading it for every generated app. It saves reading and
+// decrypting the same files when generating configuration for many apps.
+func (g Generator) WithTemplateData(data *TemplateData) *Generator {
+	g.templateData = data
+	return &g
+}
+
+// Apps returns names of all apps in default/apps/.
+func (g Generator) Apps() ([]string, error) {
+	dirs, err := g.fs.ReadDir("default/apps/")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var apps []string
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		apps = append(apps, d.Name())
+	}
+
+	return apps, nil
+}
+
+func secretFilepath(installation string) string {
+	return "installations/" + installation + "/secret.yaml"
 }
 
 // GenerateConfig generates ConfigMap and Secret for a given App. The generated
-// CM and Secret metadata are configured with the provided value.
+// CM and Secret metadata are configured with the provided value. When the app
+// has a values schema (see SchemaFilepath), generated values are validated
+// against it and error matched by IsSchemaValidation is returned on failure.
 func (g Generator) GenerateConfig(ctx context.Context, app string, meta metav1.ObjectMeta) (*corev1.ConfigMap, *corev1.Secret, error) {
-	cm, s, err := g.generateRawConfig(ctx, app)
+	cm, s, err := g.generateRawConfig(ctx, app, nil)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	err = g.checkValues(app, cm, s)
 	if err != nil {
 		return nil, nil, microerror.Mask(err)
 	}
 
+	configmap, secret := newConfigObjects(cm, s, meta)
+
+	return configmap, secret, nil
+}
+
+// GenerateConfigWithProvenance works like GenerateConfig but additionally
+// returns sources of every generated value. Finding out the sources requires
+// rendering templates multiple times so it is considerably slower.
+func (g Generator) GenerateConfigWithProvenance(ctx context.Context, app string, meta metav1.ObjectMeta) (*corev1.ConfigMap, *corev1.Secret, *Provenance, error) {
+	prov := newProvenance()
+	cm, s, err := g.generateRawConfig(ctx, app, prov)
+	if err != nil {
+		return nil, nil, nil, microerror.Mask(err)
+	}
+
+	err = g.checkValues(app, cm, s)
+	if err != nil {
+		return nil, nil, nil, microerror.Mask(err)
+	}
+
+	configmap, secret := newConfigObjects(cm, s, meta)
+
+	return configmap, secret, prov, nil
+}
+
+func newConfigObjects(cm, s string, meta metav1.ObjectMeta) (*corev1.ConfigMap, *corev1.Secret) {
 	configmap := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ConfigMap",
@@ -256,53 +514,50 @@ func (g Generator) GenerateConfig(ctx context.Context, app string, meta metav1.O
 		},
 	}
 
-	return configmap, secret, nil
+	return configmap, secret
 }
 
-// getWithPatchIfExists provides contents of filepath overwritten by patch at
-// patchFilepath. File at patchFilepath may be non-existent, resulting in pure
-// file at filepath being returned.
-func (g Generator) getWithPatchIfExists(ctx context.Context, filepath, patchFilepath string) (string, error) {
+// getWithPatchIfExists provides contents of filepath overwritten by patches at
+// patchFilepaths in the given order. Files at patchFilepaths may be
+// non-existent, resulting in pure file at filepath being returned.
+func (g Generator) getWithPatchIfExists(ctx context.Context, filepath string, patchFilepaths ...string) (string, error) {
 	var err error
 
 	var base []byte
 	{
-		base, err = g.fs.ReadFile(filepath)
+		base, err = g.readFile(ctx, filepath)
 		if err != nil {
 			return "", microerror.Mask(err)
 		}
 	}
 
-	// patch is not obligatory
-	if patchFilepath == "" {
-		return string(base), nil
-	}
+	// patches are not obligatory
+	for _, patchFilepath := range patchFilepaths {
+		patch, err := g.readFile(ctx, patchFilepath)
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return "", microerror.Mask(err)
+		}
 
-	var patch []byte
-	{
-		patch, err = g.fs.ReadFile(patchFilepath)
+		result, err := applyPatch(ctx, base, patch)
 		if err != nil {
-			if IsNotFound(err) {
-				return string(base), nil
-			}
 			return "", microerror.Mask(err)
 		}
+		base = []byte(result)
+		g.trace(ctx, PatchApplied{Filepath: patchFilepath, Target: filepath})
 	}
 
-	result, err := applyPatch(ctx, base, patch)
-	if err != nil {
-		return "", microerror.Mask(err)
-	}
-	return result, nil
+	return string(base), nil
 }
 
-func (g Generator) getRenderedTemplate(ctx context.Context, filepath, templateData string) (string, error) {
-	templateBytes, err := g.fs.ReadFile(filepath)
+func (g Generator) getRenderedTemplate(ctx context.Context, filepath, templateData string, vaultKV vaultKVFunc) (string, error) {
+	templateBytes, err := g.readFile(ctx, filepath)
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
 
-	result, err := g.renderTemplate(ctx, string(templateBytes), templateData)
+	result, err := g.renderTemplate(ctx, string(templateBytes), templateData, vaultKV)
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
@@ -310,7 +565,28 @@ func (g Generator) getRenderedTemplate(ctx context.Context, filepath, templateDa
 	return result, nil
 }
 
+// applyPatch sets every leaf value of the patch in base. Keys marked with
+// deletion directives (see deleteDirective) are removed from base and lists
+// selected by merge directives (see mergeDirectiveKey) are merged according
+// to their strategies instead.
 func applyPatch(ctx context.Context, base, patch []byte) (string, error) {
+	patch, directives, err := splitDirectives(patch)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return applyPatchWithDirectives(ctx, base, patch, directives)
+}
+
+func applyPatchWithDirectives(ctx context.Context, base, patch []byte, directives patchDirectives) (string, error) {
+	if !directives.empty() {
+		var err error
+		base, patch, err = applyDirectives(base, patch, directives)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+	}
+
 	var basePathSvc *pathmodifier.Service
 	{
 		c := pathmodifier.DefaultConfig()
@@ -374,15 +650,26 @@ func applyPatch(ctx context.Context, base, patch []byte) (string, error) {
 	return string(outputBytes), nil
 }
 
-func (g Generator) renderTemplate(ctx context.Context, templateText string, templateData string) (string, error) {
+func (g Generator) renderTemplate(ctx context.Context, templateText string, templateData string, vaultKV vaultKVFunc) (string, error) {
+	out, err := g.render(ctx, templateText, templateData, nil, vaultKV)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return out, nil
+}
+
+// render renders the template with given data. When onInclude is not nil, it
+// is called with the filepath and the output of every included file. vaultKV
+// implements the vaultKV template function.
+func (g Generator) render(ctx context.Context, templateText string, templateData string, onInclude func(filepath, output string), vaultKV vaultKVFunc) (string, error) {
 	c := map[string]interface{}{}
 	err := yaml.Unmarshal([]byte(templateData), &c)
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
 
-	funcMap := sprig.TxtFuncMap()
-	funcMap["include"] = g.include
+	funcMap := g.funcMap(ctx, nil, onInclude, vaultKV)
 
 	t, err := template.New("main").Funcs(funcMap).Option("missingkey=error").Parse(templateText)
 	if err != nil {
@@ -399,13 +686,45 @@ func (g Generator) renderTemplate(ctx context.Context, templateText string, temp
 	return out.String(), nil
 }
 
-func (g Generator) include(templateName string, templateData interface{}) (string, error) {
-	contents, err := g.fs.ReadFile(path.Join("include", templateName+".yaml.template"))
+// funcMap returns template functions. Templates rendered by the "include"
+// function can include other files. chain contains names of files included
+// so far and is used to detect include cycles.
+func (g Generator) funcMap(ctx context.Context, chain []string, onInclude func(filepath, output string), vaultKV vaultKVFunc) template.FuncMap {
+	funcMap := sprig.TxtFuncMap()
+	funcMap["include"] = func(templateName string, templateData interface{}) (string, error) {
+		out, err := g.include(ctx, templateName, templateData, chain, onInclude, vaultKV)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		if onInclude != nil {
+			onInclude(includeFilepath(templateName), out)
+		}
+
+		return out, nil
+	}
+	funcMap["vaultKV"] = vaultKV
+
+	return funcMap
+}
+
+func (g Generator) include(ctx context.Context, templateName string, templateData interface{}, chain []string, onInclude func(filepath, output string), vaultKV vaultKVFunc) (string, error) {
+	chain = append(append([]string{}, chain...), templateName)
+	for _, name := range chain[:len(chain)-1] {
+		if name == templateName {
+			var filepaths []string
+			for _, n := range chain {
+				filepaths = append(filepaths, includeFilepath(n))
+			}
+			return "", microerror.Maskf(includeCycleError, "%s", strings.Join(filepaths, " -> "))
+		}
+	}
+
+	contents, err := g.readFile(ctx, includeFilepath(templateName))
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
 
-	t, err := template.New(templateName).Funcs(sprig.TxtFuncMap()).Option("missingkey=error").Parse(string(contents))
+	t, err := template.New(templateName).Funcs(g.funcMap(ctx, chain, onInclude, vaultKV)).Option("missingkey=error").Parse(string(contents))
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
@@ -419,8 +738,30 @@ func (g Generator) include(templateName string, templateData interface{}) (strin
 	return out.String(), nil
 }
 
-func (g Generator) logMessage(ctx context.Context, format string, params ...interface{}) {
-	if g.verbose {
-		fmt.Fprintf(os.Stderr, "generator: "+format+"\n", params...)
+func includeFilepath(templateName string) string {
+	return path.Join("include", templateName+".yaml.template")
+}
+
+// readFile reads the file from the config repository and emits FileRead.
+func (g Generator) readFile(ctx context.Context, filepath string) ([]byte, error) {
+	data, err := g.fs.ReadFile(filepath)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	g.trace(ctx, FileRead{Filepath: filepath})
+
+	return data, nil
+}
+
+// decrypt decrypts secret values of data read from filepath and emits
+// DecryptionPerformed.
+func (g Generator) decrypt(ctx context.Context, filepath string, data []byte) ([]byte, error) {
+	start := time.Now()
+	decrypted, err := g.decryptTraverser.Traverse(ctx, data)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
+	g.trace(ctx, DecryptionPerformed{Filepath: filepath, Duration: time.Since(start)})
+
+	return decrypted, nil
 }
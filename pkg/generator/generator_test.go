@@ -10,6 +10,8 @@ import (
 
 	"github.com/ghodss/yaml"
 	"github.com/giantswarm/microerror"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGenerator_generateRawConfig(t *testing.T) {
@@ -21,7 +23,8 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 		app          string
 		installation string
 
-		decryptTraverser DecryptTraverser
+		decryptTraverser  DecryptTraverser
+		secretRefResolver SecretRefResolver
 	}{
 		{
 			name:     "case 0 - basic config with config.yaml.patch",
@@ -122,6 +125,84 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 			installation:     "puma",
 			decryptTraverser: &noopTraverser{},
 		},
+
+		{
+			name:     "case 11 - patch through intermediate layers",
+			caseFile: "testdata/case11.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+		},
+
+		{
+			name:     "case 12 - merge lists with merge directives",
+			caseFile: "testdata/case12.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+		},
+
+		{
+			name:                 "case 13 - throw error for unknown list merge strategy",
+			caseFile:             "testdata/case13.yaml",
+			expectedErrorMessage: "invalid list merge strategy for `hosts`",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+		},
+
+		{
+			name:     "case 14 - delete values with deletion directives",
+			caseFile: "testdata/case14.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+		},
+
+		{
+			name:     "case 16 - nested includes",
+			caseFile: "testdata/case16.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+		},
+
+		{
+			name:                 "case 17 - throw error for include cycle",
+			caseFile:             "testdata/case17.yaml",
+			expectedErrorMessage: "include/image.yaml.template -> include/registry.yaml.template -> include/image.yaml.template",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &noopTraverser{},
+		},
+
+		{
+			name:     "case 18 - resolve secretRef objects of installation secret",
+			caseFile: "testdata/case18.yaml",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &prefixTraverser{},
+			secretRefResolver: mapSecretRefResolver{
+				"giantswarm/database-credentials#password": "s3cret",
+			},
+		},
+
+		{
+			name:                 "case 19 - throw error for secretRef objects without resolver",
+			caseFile:             "testdata/case18.yaml",
+			expectedErrorMessage: "can not resolve `database.password`",
+
+			app:              "operator",
+			installation:     "puma",
+			decryptTraverser: &prefixTraverser{},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -138,14 +219,15 @@ func TestGenerator_generateRawConfig(t *testing.T) {
 				Fs:               fs,
 				DecryptTraverser: tc.decryptTraverser,
 
-				Installation: tc.installation,
+				Installation:      tc.installation,
+				SecretRefResolver: tc.secretRefResolver,
 			}
 			g, err := New(config)
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err.Error())
 			}
 
-			configmap, secret, err := g.generateRawConfig(context.Background(), tc.app)
+			configmap, secret, err := g.generateRawConfig(context.Background(), tc.app, nil)
 			if tc.expectedErrorMessage == "" {
 				if err != nil {
 					t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
@@ -268,3 +350,403 @@ func (t mapStringTraverser) Traverse(ctx context.Context, encrypted []byte) ([]b
 	}
 	return decrypted, nil
 }
+
+// prefixTraverser "decrypts" values by stripping "enc:" prefix and rejects
+// other non-null values like decrypt.YAMLTraverser of strict installations.
+type prefixTraverser struct{}
+
+func (t prefixTraverser) Traverse(ctx context.Context, encrypted []byte) ([]byte, error) {
+	values, err := yamlValues(encrypted)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	svc, err := newPathService(encrypted)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for p, v := range values {
+		if v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok || !strings.HasPrefix(s, "enc:") {
+			return nil, microerror.Maskf(invalidConfigError, "value at %#q is not encrypted", p)
+		}
+		err = svc.Set(p, strings.TrimPrefix(s, "enc:"))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return svc.OutputBytes()
+}
+
+type mapSecretRefResolver map[string]string
+
+func (r mapSecretRefResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	v, ok := r[ref.String()]
+	if !ok {
+		return "", microerror.Maskf(notFoundError, "%s", ref)
+	}
+	return v, nil
+}
+
+func TestGenerator_GenerateConfigWithProvenance(t *testing.T) {
+	testCases := []struct {
+		name     string
+		caseFile string
+
+		expectedConfigmap map[string][]Source
+		expectedSecret    map[string][]Source
+	}{
+		{
+			name:     "case 0 - include files in templates",
+			caseFile: "testdata/case1.yaml",
+
+			expectedConfigmap: map[string][]Source{
+				"answer": {
+					{Filepath: "default/config.yaml", Layer: LayerDefault, Path: "universalValue"},
+					{Filepath: "default/apps/operator/configmap-values.yaml.template", Layer: LayerDefault, Path: "answer"},
+				},
+				"availableInstances": {
+					{Filepath: "default/apps/operator/configmap-values.yaml.template", Layer: LayerDefault, Path: "availableInstances"},
+					{Filepath: "include/instances.yaml.template", Layer: LayerInclude, Path: ""},
+				},
+				"region": {
+					{Filepath: "installations/puma/config.yaml.patch", Layer: LayerInstallation, Path: "provider.region"},
+					{Filepath: "default/apps/operator/configmap-values.yaml.template", Layer: LayerDefault, Path: "region"},
+				},
+			},
+			expectedSecret: map[string][]Source{
+				"secretAccessKey": {
+					{Filepath: "installations/puma/secret.yaml", Layer: LayerInstallation, Path: "key"},
+					{Filepath: "default/apps/operator/secret-values.yaml.template", Layer: LayerDefault, Path: "secretAccessKey"},
+				},
+			},
+		},
+
+		{
+			name:     "case 1 - patch configmap and secret",
+			caseFile: "testdata/case7.yaml",
+
+			expectedConfigmap: map[string][]Source{
+				"addedKey": {
+					{Filepath: "installations/puma/apps/operator/configmap-values.yaml.patch", Layer: LayerAppPatch, Path: "addedKey"},
+				},
+				"answer": {
+					{Filepath: "default/config.yaml", Layer: LayerDefault, Path: "universalValue"},
+					{Filepath: "default/apps/operator/configmap-values.yaml.template", Layer: LayerDefault, Path: "answer"},
+				},
+				"region": {
+					{Filepath: "installations/puma/config.yaml.patch", Layer: LayerInstallation, Path: "provider.region"},
+					{Filepath: "default/apps/operator/configmap-values.yaml.template", Layer: LayerDefault, Path: "region"},
+					{Filepath: "installations/puma/apps/operator/configmap-values.yaml.patch", Layer: LayerAppPatch, Path: "region"},
+				},
+			},
+			expectedSecret: map[string][]Source{
+				"secretAccessKey": {
+					{Filepath: "installations/puma/secret.yaml", Layer: LayerInstallation, Path: "key"},
+					{Filepath: "default/apps/operator/secret-values.yaml.template", Layer: LayerDefault, Path: "secretAccessKey"},
+					{Filepath: "installations/puma/apps/operator/secret-values.yaml.patch", Layer: LayerAppPatch, Path: "secretAccessKey"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			defer os.RemoveAll(tmpDir)
+
+			fs := newMockFilesystem(tmpDir, tc.caseFile)
+
+			config := Config{
+				Fs:               fs,
+				DecryptTraverser: &noopTraverser{},
+
+				Installation: "puma",
+			}
+			g, err := New(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			configmap, secret, prov, err := g.GenerateConfigWithProvenance(context.Background(), "operator", metav1.ObjectMeta{})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			if configmap.Data["configmap-values.yaml"] != fs.ExpectedConfigmap {
+				t.Fatalf("configmap not expected, got: %s", configmap.Data["configmap-values.yaml"])
+			}
+			if string(secret.Data["secret-values.yaml"]) != fs.ExpectedSecret {
+				t.Fatalf("secret not expected, got: %s", secret.Data["secret-values.yaml"])
+			}
+
+			if !cmp.Equal(prov.Configmap, tc.expectedConfigmap) {
+				t.Fatalf("configmap provenance not expected\n\n%s\n", cmp.Diff(tc.expectedConfigmap, prov.Configmap))
+			}
+			if !cmp.Equal(prov.Secret, tc.expectedSecret) {
+				t.Fatalf("secret provenance not expected\n\n%s\n", cmp.Diff(tc.expectedSecret, prov.Secret))
+			}
+		})
+	}
+}
+
+func TestGenerator_Explain(t *testing.T) {
+	testCases := []struct {
+		name     string
+		caseFile string
+		path     string
+
+		expectedSnippets []string
+		expectedValue    string
+	}{
+		{
+			name:     "case 0 - configmap value patched by installation and app patch",
+			caseFile: "testdata/case7.yaml",
+			path:     "region",
+
+			expectedSnippets: []string{
+				"provider.region: us-east-1",
+				"region: {{ .provider.region }}",
+				"region: eu-west-3",
+			},
+			expectedValue: "eu-west-3",
+		},
+		{
+			name:     "case 1 - masked secret value",
+			caseFile: "testdata/case7.yaml",
+			path:     ".secretAccessKey",
+
+			expectedSnippets: []string{
+				"key: <masked>",
+				"secretAccessKey: {{ .key }}",
+				"secretAccessKey: <masked>",
+			},
+			expectedValue: "<masked>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			defer os.RemoveAll(tmpDir)
+
+			config := Config{
+				Fs:               newMockFilesystem(tmpDir, tc.caseFile),
+				DecryptTraverser: &noopTraverser{},
+
+				Installation: "puma",
+			}
+			g, err := New(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			e, err := g.Explain(context.Background(), "operator", tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			var snippets []string
+			for _, s := range e.Steps {
+				snippets = append(snippets, s.Snippet)
+			}
+
+			if !cmp.Equal(snippets, tc.expectedSnippets) {
+				t.Fatalf("snippets not expected\n\n%s\n", cmp.Diff(tc.expectedSnippets, snippets))
+			}
+			if e.Value != tc.expectedValue {
+				t.Fatalf("value = %q, want %q", e.Value, tc.expectedValue)
+			}
+		})
+	}
+}
+
+func TestGenerator_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		caseFile string
+
+		expectedViolations []SchemaViolation
+	}{
+		{
+			name:     "case 0 - app without values schema",
+			caseFile: "testdata/case1.yaml",
+		},
+		{
+			name:     "case 1 - invalid configmap and secret values",
+			caseFile: "testdata/case15.yaml",
+
+			expectedViolations: []SchemaViolation{
+				{
+					Path:    "$.database.password",
+					Message: "password is required",
+				},
+				{
+					Path:           "$.database.port",
+					Secret:         true,
+					ValueDependent: true,
+					Message:        "Invalid type. Expected: integer, given: string",
+				},
+				{
+					Path:    "$.ingress.hosts[1]",
+					Message: "Invalid type. Expected: string, given: integer",
+				},
+				{
+					Path:           "$.password",
+					Secret:         true,
+					ValueDependent: true,
+					Message:        "String length must be greater than or equal to 10",
+				},
+				{
+					Path:           "$.replicas",
+					ValueDependent: true,
+					Message:        "Invalid type. Expected: integer, given: string",
+				},
+				{
+					Path:    "$.token",
+					Secret:  true,
+					Message: "Invalid type. Expected: object, given: string",
+				},
+				{
+					Path:    `$["tenant.id"]`,
+					Message: "tenant.id is required",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			defer os.RemoveAll(tmpDir)
+
+			config := Config{
+				Fs:               newMockFilesystem(tmpDir, tc.caseFile),
+				DecryptTraverser: &noopTraverser{},
+
+				Installation: "puma",
+			}
+			g, err := New(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			violations, err := g.Validate(context.Background(), "operator")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			if !cmp.Equal(violations, tc.expectedViolations) {
+				t.Fatalf("violations not expected, got:\n%s", cmp.Diff(tc.expectedViolations, violations))
+			}
+
+			_, _, err = g.GenerateConfig(context.Background(), "operator", metav1.ObjectMeta{})
+			if len(tc.expectedViolations) == 0 && err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+			if len(tc.expectedViolations) > 0 && !IsSchemaValidation(err) {
+				t.Fatalf("expected schema validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerator_Tracer(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tracer := &recordingTracer{}
+	config := Config{
+		Fs:               newMockFilesystem(tmpDir, "testdata/case7.yaml"),
+		DecryptTraverser: &noopTraverser{},
+
+		Installation: "puma",
+		Tracer:       tracer,
+	}
+	g, err := New(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, _, err = g.GenerateConfig(context.Background(), "operator", metav1.ObjectMeta{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	configmapPatch := "installations/puma/apps/operator/configmap-values.yaml.patch"
+	secretPatch := "installations/puma/apps/operator/secret-values.yaml.patch"
+	expectedEvents := []Event{
+		StepStarted{Step: StepConfigData, Filepath: "default/config.yaml"},
+		FileRead{Filepath: "default/config.yaml"},
+		FileRead{Filepath: "installations/puma/config.yaml.patch"},
+		PatchApplied{Filepath: "installations/puma/config.yaml.patch", Target: "default/config.yaml"},
+		StepFinished{Step: StepConfigData, Filepath: "default/config.yaml"},
+		StepStarted{Step: StepSecretData, Filepath: "installations/puma/secret.yaml"},
+		FileRead{Filepath: "installations/puma/secret.yaml"},
+		DecryptionPerformed{Filepath: "installations/puma/secret.yaml"},
+		StepFinished{Step: StepSecretData, Filepath: "installations/puma/secret.yaml"},
+		StepStarted{App: "operator", Step: StepConfigmapTemplate, Filepath: "default/apps/operator/configmap-values.yaml.template"},
+		FileRead{Filepath: "default/apps/operator/configmap-values.yaml.template"},
+		StepFinished{App: "operator", Step: StepConfigmapTemplate, Filepath: "default/apps/operator/configmap-values.yaml.template"},
+		StepStarted{App: "operator", Step: StepConfigmapPatch, Filepath: configmapPatch},
+		FileRead{Filepath: configmapPatch},
+		StepFinished{App: "operator", Step: StepConfigmapPatch, Filepath: configmapPatch},
+		StepStarted{App: "operator", Step: StepConfigmapPatchApply, Filepath: configmapPatch},
+		PatchApplied{Filepath: configmapPatch, Target: "configmap-values"},
+		StepFinished{App: "operator", Step: StepConfigmapPatchApply, Filepath: configmapPatch},
+		StepStarted{App: "operator", Step: StepSecretTemplate, Filepath: "default/apps/operator/secret-values.yaml.template"},
+		FileRead{Filepath: "default/apps/operator/secret-values.yaml.template"},
+		StepFinished{App: "operator", Step: StepSecretTemplate, Filepath: "default/apps/operator/secret-values.yaml.template"},
+		StepStarted{App: "operator", Step: StepSecretPatch, Filepath: secretPatch},
+		FileRead{Filepath: secretPatch},
+		DecryptionPerformed{Filepath: secretPatch},
+		StepFinished{App: "operator", Step: StepSecretPatch, Filepath: secretPatch},
+		StepStarted{App: "operator", Step: StepSecretPatchApply, Filepath: secretPatch},
+		PatchApplied{Filepath: secretPatch, Target: "secret-values"},
+		StepFinished{App: "operator", Step: StepSecretPatchApply, Filepath: secretPatch},
+	}
+	if !cmp.Equal(tracer.events, expectedEvents) {
+		t.Fatalf("events not expected, got:\n%s", cmp.Diff(expectedEvents, tracer.events))
+	}
+
+	expectedOutput := "secretAccessKey: SuperSpecialKeyForOperatorOnPuma123!#\n"
+	if tracer.lastOutput != expectedOutput {
+		t.Fatalf("expected last step output %q, got %q", expectedOutput, tracer.lastOutput)
+	}
+}
+
+// recordingTracer records events with durations and step outputs cleared.
+type recordingTracer struct {
+	events     []Event
+	lastOutput string
+}
+
+func (t *recordingTracer) Trace(ctx context.Context, event Event) {
+	switch e := event.(type) {
+	case StepFinished:
+		t.lastOutput = e.Output
+		e.Duration = 0
+		e.Output = ""
+		event = e
+	case DecryptionPerformed:
+		e.Duration = 0
+		event = e
+	}
+	t.events = append(t.events, event)
+}
@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"path"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// InstallationLayers returns intermediate layer directories declared in the
+// installation metadata file in the order they are applied. The metadata file
+// is optional.
+func InstallationLayers(fs Filesystem, installation string) ([]string, error) {
+	m, err := readInstallationMetadata(fs, installation)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var layers []string
+	for _, l := range m.Layers {
+		layer := path.Clean(strings.Trim(l, "/"))
+
+		if layer == "." || layer == "default" || layer == "include" || strings.HasPrefix(layer, "..") || strings.HasPrefix(layer, "installations/") || layer == "installations" {
+			return nil, microerror.Maskf(invalidConfigError, "layer %#q in %#q must be a directory outside of %#q, %#q and %#q", l, metadataFilepath(installation), "default", "include", "installations")
+		}
+
+		layers = append(layers, layer)
+	}
+
+	return layers, nil
+}
+
+// patchDirs returns directories containing patches for the installation in the
+// order they are applied: intermediate layers followed by the installation
+// directory.
+func (g Generator) patchDirs() ([]string, error) {
+	layers, err := InstallationLayers(g.fs, g.installation)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return append(layers, "installations/"+g.installation), nil
+}
+
+// patchLayer returns LayerIntermediate for files in intermediate layer
+// directories and installationLayer for files in the installation directory.
+func patchLayer(filepath string, installationLayer Layer) Layer {
+	if strings.HasPrefix(filepath, "installations/") {
+		return installationLayer
+	}
+
+	return LayerIntermediate
+}
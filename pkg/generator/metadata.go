@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+// SecretPolicy decides whether secret values of an installation may be
+// stored in plaintext.
+type SecretPolicy string
+
+const (
+	// SecretPolicyStrict requires all secret values to be encrypted. It is
+	// the default.
+	SecretPolicyStrict SecretPolicy = "strict"
+	// SecretPolicyPermissive allows plaintext secret values, e.g.
+	// usernames or ports. They are used untouched.
+	SecretPolicyPermissive SecretPolicy = "permissive"
+)
+
+// installationMetadata is the content of installations/<name>/metadata.yaml.
+//
+// Layers is an ordered list of directories with patches applied between
+// default/ and installations/<name>/, e.g.:
+//
+//	layers:
+//	- providers/aws
+//	- regions/eu-west-1
+//	- stages/prod
+//
+// Every layer directory may contain config.yaml.patch and
+// apps/<app>/{configmap,secret}-values.yaml.patch files. They follow the same
+// rules as their installation counterparts.
+//
+// SecretPolicy is either "strict" or "permissive", see SecretPolicy.
+type installationMetadata struct {
+	Layers       []string     `json:"layers,omitempty"`
+	SecretPolicy SecretPolicy `json:"secretPolicy,omitempty"`
+}
+
+func metadataFilepath(installation string) string {
+	return "installations/" + installation + "/metadata.yaml"
+}
+
+// readInstallationMetadata reads the installation metadata file. The file
+// is optional.
+func readInstallationMetadata(fs Filesystem, installation string) (installationMetadata, error) {
+	filepath := metadataFilepath(installation)
+
+	data, err := fs.ReadFile(filepath)
+	if IsNotFound(err) {
+		return installationMetadata{}, nil
+	} else if err != nil {
+		return installationMetadata{}, microerror.Mask(err)
+	}
+
+	var m installationMetadata
+	err = yaml.Unmarshal(data, &m)
+	if err != nil {
+		return installationMetadata{}, microerror.Maskf(invalidConfigError, "failed to parse %#q with error %#q", filepath, err)
+	}
+
+	return m, nil
+}
+
+// InstallationSecretPolicy returns the secret policy declared in the
+// installation metadata file. It defaults to SecretPolicyStrict.
+func InstallationSecretPolicy(fs Filesystem, installation string) (SecretPolicy, error) {
+	m, err := readInstallationMetadata(fs, installation)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	switch m.SecretPolicy {
+	case "":
+		return SecretPolicyStrict, nil
+	case SecretPolicyStrict, SecretPolicyPermissive:
+		return m.SecretPolicy, nil
+	default:
+		return "", microerror.Maskf(invalidConfigError, "secretPolicy in %#q must be one of %q, got %#q", metadataFilepath(installation), []SecretPolicy{SecretPolicyStrict, SecretPolicyPermissive}, m.SecretPolicy)
+	}
+}
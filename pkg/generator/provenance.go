@@ -0,0 +1,358 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+	pathmodifier "github.com/giantswarm/valuemodifier/path"
+)
+
+// Layer is a level of the config repository a generated value may come from.
+type Layer string
+
+const (
+	// LayerDefault covers default/config.yaml and app templates in
+	// default/apps/.
+	LayerDefault Layer = "default"
+	// LayerInstallation covers installation-wide files, i.e.
+	// installations/<name>/config.yaml.patch and
+	// installations/<name>/secret.yaml.
+	LayerInstallation Layer = "installation"
+	// LayerAppPatch covers installation-specific app patches, i.e.
+	// installations/<name>/apps/<app>/*-values.yaml.patch.
+	LayerAppPatch Layer = "app-patch"
+	// LayerInclude covers include files in include/.
+	LayerInclude Layer = "include"
+	// LayerIntermediate covers patches in intermediate layer directories
+	// declared in installations/<name>/metadata.yaml, e.g.
+	// providers/aws/config.yaml.patch.
+	LayerIntermediate Layer = "intermediate"
+)
+
+// Source describes a single contribution to a generated value.
+type Source struct {
+	// Filepath of the contributing file in the config repository.
+	Filepath string
+	// Layer of the contributing file.
+	Layer Layer
+	// Path of the contributing value inside the file. For config.yaml,
+	// config.yaml.patch and secret.yaml it is the path of the template
+	// data the generated value was rendered from.
+	Path string
+}
+
+// Provenance records where generated values come from. Keys are paths in
+// valuemodifier/path format (e.g. "a.b.[0].c"). Sources of each path are
+// ordered the same way generateRawConfig applies them, so the last source is
+// the one which determined the final value.
+type Provenance struct {
+	Configmap map[string][]Source
+	Secret    map[string][]Source
+}
+
+func newProvenance() *Provenance {
+	return &Provenance{
+		Configmap: map[string][]Source{},
+		Secret:    map[string][]Source{},
+	}
+}
+
+// dataSources returns sources of all template data paths found in given files.
+// Files are expected to be ordered the way they are patched.
+func (g Generator) dataSources(layers map[string]Layer, filepaths ...string) (map[string][]Source, error) {
+	sources := map[string][]Source{}
+	for _, filepath := range filepaths {
+		if filepath == "" {
+			continue
+		}
+
+		data, err := g.fs.ReadFile(filepath)
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		values, err := yamlValues(data)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for p, v := range values {
+			if IsDirectivePath(p) || IsDeleteDirective(v) {
+				continue
+			}
+			// Fields of secretRef objects are the source of the
+			// resolved value.
+			if refPath, _, ok := secretRefPath(p); ok {
+				p = refPath
+				if n := len(sources[p]); n > 0 && sources[p][n-1].Filepath == filepath {
+					continue
+				}
+			}
+			sources[p] = append(sources[p], Source{
+				Filepath: filepath,
+				Layer:    layers[filepath],
+				Path:     p,
+			})
+		}
+	}
+
+	return sources, nil
+}
+
+// traceTemplate renders the template at filepath with templateData and finds
+// out which template data paths each of the output paths depends on. This is
+// done by rendering the template again for every template data path with its
+// value altered and comparing the results. Include files are attributed to
+// output paths ending with a path rendered by the include file with the same
+// value.
+func (g Generator) traceTemplate(ctx context.Context, filepath string, layer Layer, templateData string, dataSources map[string][]Source) (map[string][]Source, error) {
+	templateBytes, err := g.fs.ReadFile(filepath)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	templateText := string(templateBytes)
+
+	// Template data is passed through valuemodifier/path so the baseline
+	// and altered renders differ only by the altered value.
+	var dataPaths []string
+	var dataValues map[string]interface{}
+	var baselineData []byte
+	{
+		svc, err := newPathService([]byte(templateData))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		dataPaths, err = svc.All()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		dataValues = map[string]interface{}{}
+		for _, p := range dataPaths {
+			dataValues[p], err = svc.Get(p)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		baselineData, err = svc.OutputBytes()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var includes []renderedInclude
+	onInclude := func(filepath, output string) {
+		includes = append(includes, renderedInclude{filepath: filepath, output: output})
+	}
+
+	out, err := g.render(ctx, templateText, string(baselineData), onInclude, placeholderVaultKV)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	outValues, err := yamlValues([]byte(out))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	dependencies := map[string][]string{}
+	for _, dataPath := range dataPaths {
+		svc, err := newPathService(baselineData)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = svc.Set(dataPath, alterValue(dataValues[dataPath]))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		alteredData, err := svc.OutputBytes()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		alteredOut, err := g.render(ctx, templateText, string(alteredData), nil, placeholderVaultKV)
+		if err != nil {
+			// The template can not handle the altered value (e.g.
+			// it is converted to a number). There is no way to
+			// tell which output paths depend on it.
+			continue
+		}
+
+		alteredValues, err := yamlValues([]byte(alteredOut))
+		if err != nil {
+			continue
+		}
+
+		for p, v := range outValues {
+			alteredV, ok := alteredValues[p]
+			if !ok || !reflect.DeepEqual(v, alteredV) {
+				dependencies[p] = append(dependencies[p], dataPath)
+			}
+		}
+	}
+
+	var includeValues []map[string]interface{}
+	for _, include := range includes {
+		var whole interface{}
+		err := yaml.Unmarshal([]byte(include.output), &whole)
+		if err != nil {
+			// Include output may be a YAML fragment which can not
+			// be parsed on its own.
+			includeValues = append(includeValues, map[string]interface{}{})
+			continue
+		}
+
+		values := map[string]interface{}{}
+		if _, ok := whole.(map[string]interface{}); ok {
+			values, err = yamlValues([]byte(include.output))
+			if err != nil {
+				values = map[string]interface{}{}
+			}
+		} else {
+			// Non-map output (e.g. a list) is matched as a whole.
+			values[""] = whole
+		}
+		includeValues = append(includeValues, values)
+	}
+
+	result := map[string][]Source{}
+	for p, v := range outValues {
+		if IsDirectivePath(p) || IsDeleteDirective(v) {
+			continue
+		}
+
+		var sources []Source
+		for _, dataPath := range dependencies[p] {
+			sources = append(sources, dataSources[dataPath]...)
+		}
+
+		sources = append(sources, Source{
+			Filepath: filepath,
+			Layer:    layer,
+			Path:     p,
+		})
+
+		for i, include := range includes {
+			for includePath, includeV := range includeValues[i] {
+				if includePath != "" && p != includePath && !strings.HasSuffix(p, "."+includePath) {
+					continue
+				}
+				if !reflect.DeepEqual(v, includeV) {
+					continue
+				}
+
+				sources = append(sources, Source{
+					Filepath: include.filepath,
+					Layer:    LayerInclude,
+					Path:     includePath,
+				})
+			}
+		}
+
+		result[p] = sources
+	}
+
+	return result, nil
+}
+
+// prune removes paths which are not generated, e.g. removed by deletion
+// directives.
+func (p *Provenance) prune(configmap, secret string) error {
+	for _, x := range []struct {
+		sources map[string][]Source
+		output  string
+	}{
+		{sources: p.Configmap, output: configmap},
+		{sources: p.Secret, output: secret},
+	} {
+		values, err := yamlValues([]byte(x.output))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		for path := range x.sources {
+			if _, ok := values[path]; !ok {
+				delete(x.sources, path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeSources appends sources of the patch paths to the base sources.
+func mergeSources(base, patch map[string][]Source) {
+	for p, sources := range patch {
+		base[p] = append(base[p], sources...)
+	}
+}
+
+type renderedInclude struct {
+	filepath string
+	output   string
+}
+
+// alterValue returns a value which is different from v and has the same type
+// if possible.
+func alterValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		return x + "-altered"
+	case bool:
+		return !x
+	case float64:
+		return x + 1
+	case []interface{}:
+		return append(append([]interface{}{}, x...), "altered")
+	default:
+		return "altered"
+	}
+}
+
+func newPathService(data []byte) (*pathmodifier.Service, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}")
+	}
+
+	c := pathmodifier.DefaultConfig()
+	c.InputBytes = data
+	svc, err := pathmodifier.New(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return svc, nil
+}
+
+// yamlValues returns all leaf paths of the YAML document with their values.
+func yamlValues(data []byte) (map[string]interface{}, error) {
+	svc, err := newPathService(data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	paths, err := svc.All()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	values := map[string]interface{}{}
+	for _, p := range paths {
+		values[p], err = svc.Get(p)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	// contextDelimiter separates gojsonschema context elements. It can not
+	// appear in YAML keys so keys containing dots are handled correctly.
+	contextDelimiter = "\x00"
+	contextRoot      = "(root)"
+)
+
+var jsonPathIdentifierPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// SchemaViolation is a failure of validation of generated values against the
+// app's values schema.
+type SchemaViolation struct {
+	// Path is a JSONPath of the invalid value, e.g. "$.ingress.hosts[0]".
+	Path string
+	// Secret is true when the invalid value is generated in
+	// secret-values.
+	Secret bool
+	// ValueDependent is true when the violation depends on the content of
+	// a string value, e.g. its format or length, and not on the structure
+	// of the values. Such violations of secret values can not be checked
+	// before the values are decrypted.
+	ValueDependent bool
+	// Message describes the failure.
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// SchemaFilepath returns path of the optional JSON Schema file for values of
+// the app.
+func SchemaFilepath(app string) string {
+	return "default/apps/" + app + "/values.schema.json"
+}
+
+// Validate generates configuration for the app and validates it against the
+// app's values schema. It returns nil when the app has no values schema.
+func (g Generator) Validate(ctx context.Context, app string) ([]SchemaViolation, error) {
+	configmap, secret, err := g.generateRawConfig(ctx, app, nil)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	violations, err := g.validateValues(app, configmap, secret)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return violations, nil
+}
+
+// checkValues returns error matched by IsSchemaValidation when generated
+// values do not match the app's values schema.
+func (g Generator) checkValues(app, configmap, secret string) error {
+	violations, err := g.validateValues(app, configmap, secret)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if len(violations) > 0 {
+		var messages []string
+		for _, v := range violations {
+			messages = append(messages, v.String())
+		}
+		return microerror.Maskf(schemaValidationError, "generated values of app %#q do not match %#q: %s", app, SchemaFilepath(app), strings.Join(messages, "; "))
+	}
+
+	return nil
+}
+
+// validateValues validates configmap and secret values merged the same way
+// they are merged when the app is installed, i.e. secret values take
+// precedence.
+func (g Generator) validateValues(app, configmap, secret string) ([]SchemaViolation, error) {
+	filepath := SchemaFilepath(app)
+
+	schema, err := g.fs.ReadFile(filepath)
+	if IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	configmapValues, err := unmarshalValues(configmap)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	secretValues, err := unmarshalValues(secret)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	values := mergeMaps(configmapValues, secretValues)
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewBytesLoader(schema),
+		gojsonschema.NewGoLoader(values),
+	)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "failed to validate values against %#q with error %#q", filepath, err)
+	}
+
+	var violations []SchemaViolation
+	for _, e := range result.Errors() {
+		elements := strings.Split(e.Context().String(contextDelimiter), contextDelimiter)
+		if len(elements) > 0 && elements[0] == contextRoot {
+			elements = elements[1:]
+		}
+		if e.Type() == "required" {
+			if property, ok := e.Details()["property"].(string); ok {
+				elements = append(elements, property)
+			}
+		}
+
+		_, inConfigmap := getValue(configmapValues, elements)
+		_, inSecret := getValue(secretValues, elements)
+
+		violations = append(violations, SchemaViolation{
+			Path:           jsonPath(values, elements),
+			Secret:         inSecret && !inConfigmap,
+			ValueDependent: isValueDependent(e),
+			Message:        e.Description(),
+		})
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Path != violations[j].Path {
+			return violations[i].Path < violations[j].Path
+		}
+		return violations[i].Message < violations[j].Message
+	})
+
+	return violations, nil
+}
+
+// isValueDependent returns true when the error is caused by the content of a
+// string value rather than by the structure of the values. Strings of the
+// wrong type are treated as value dependent only when a scalar is expected,
+// e.g. "8080" for an integer.
+func isValueDependent(e gojsonschema.ResultError) bool {
+	if _, ok := e.Value().(string); !ok {
+		return false
+	}
+
+	switch e.Type() {
+	case "const", "enum", "format", "pattern", "string_gte", "string_lte":
+		return true
+	case "invalid_type":
+		expected, _ := e.Details()["expected"].(string)
+		return !strings.Contains(expected, "object") && !strings.Contains(expected, "array")
+	}
+
+	return false
+}
+
+func unmarshalValues(data string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(data), &values)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return values, nil
+}
+
+// getValue returns value under path elements. Elements of lists are
+// addressed by indexes.
+func getValue(tree interface{}, elements []string) (interface{}, bool) {
+	current := tree
+	for _, e := range elements {
+		switch x := current.(type) {
+		case map[string]interface{}:
+			v, ok := x[e]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(e)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			current = x[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// jsonPath formats path elements as JSONPath. Elements are treated as list
+// indexes only when they point into a list in values.
+func jsonPath(values interface{}, elements []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+
+	current := values
+	for _, e := range elements {
+		list, isList := current.([]interface{})
+		if isList {
+			if _, err := strconv.Atoi(e); err == nil {
+				b.WriteString("[" + e + "]")
+				current, _ = getValue(list, []string{e})
+				continue
+			}
+		}
+
+		if jsonPathIdentifierPattern.MatchString(e) {
+			b.WriteString("." + e)
+		} else {
+			b.WriteString("[" + strconv.Quote(e) + "]")
+		}
+		current, _ = getValue(current, []string{e})
+	}
+
+	return b.String()
+}
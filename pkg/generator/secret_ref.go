@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// secretRefKey is the key of reference objects in installation secret.yaml.
+// Values of such objects are read from Kubernetes Secrets instead of the
+// config repository, e.g.:
+//
+//	database:
+//	  password:
+//	    secretRef:
+//	      namespace: giantswarm
+//	      name: database-credentials
+//	      key: password
+const secretRefKey = "secretRef"
+
+// SecretRef points to the value under Key of the Kubernetes Secret.
+type SecretRef struct {
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (r SecretRef) String() string {
+	return fmt.Sprintf("%s/%s#%s", r.Namespace, r.Name, r.Key)
+}
+
+// PlaceholderSecretRefResolver returns placeholders instead of reading
+// Kubernetes Secrets. It is meant for tools which never output secret
+// values or have no access to the cluster.
+type PlaceholderSecretRefResolver struct{}
+
+func (PlaceholderSecretRefResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	return fmt.Sprintf("secretRef(%q)", ref.String()), nil
+}
+
+// SecretRefPath returns the path of the secretRef object in installation
+// secret.yaml the path belongs to. ok is false for paths of other values.
+func SecretRefPath(p string) (refPath string, ok bool) {
+	refPath, _, ok = secretRefPath(p)
+	return refPath, ok
+}
+
+// secretRefPath returns the path of the reference object the path of one of
+// its fields belongs to, e.g. "a.b" for "a.b.secretRef.name".
+func secretRefPath(p string) (refPath string, field string, ok bool) {
+	i := strings.LastIndex(p, "."+secretRefKey+".")
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return p[:i], p[i+len(secretRefKey)+2:], true
+}
+
+// maskSecretRefs finds reference objects in data and sets them to null. They
+// are not encrypted and would be rejected by the decrypt traverser of
+// installations with the strict secret policy. The references are returned
+// by their paths so they can be resolved with resolveSecretRefs after
+// decryption.
+func maskSecretRefs(data []byte) ([]byte, map[string]SecretRef, error) {
+	values, err := yamlValues(data)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	refs := map[string]SecretRef{}
+	for p, v := range values {
+		refPath, field, ok := secretRefPath(p)
+		if !ok {
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, nil, microerror.Maskf(invalidSecretRefError, "value of %#q must be a string", p)
+		}
+
+		ref := refs[refPath]
+		switch field {
+		case "namespace":
+			ref.Namespace = s
+		case "name":
+			ref.Name = s
+		case "key":
+			ref.Key = s
+		default:
+			return nil, nil, microerror.Maskf(invalidSecretRefError, "unknown field %#q", p)
+		}
+		refs[refPath] = ref
+	}
+
+	if len(refs) == 0 {
+		return data, nil, nil
+	}
+
+	for p := range values {
+		for refPath := range refs {
+			if strings.HasPrefix(p, refPath+".") && !strings.HasPrefix(p, refPath+"."+secretRefKey+".") {
+				return nil, nil, microerror.Maskf(invalidSecretRefError, "%#q must contain only %#q but it contains %#q", refPath, secretRefKey, p)
+			}
+		}
+	}
+
+	svc, err := newPathService(data)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	for refPath, ref := range refs {
+		if ref.Namespace == "" || ref.Name == "" || ref.Key == "" {
+			return nil, nil, microerror.Maskf(invalidSecretRefError, "%#q must have namespace, name and key set", refPath)
+		}
+
+		err = svc.Set(refPath, nil)
+		if err != nil {
+			return nil, nil, microerror.Mask(err)
+		}
+	}
+
+	masked, err := svc.OutputBytes()
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	return masked, refs, nil
+}
+
+// resolveSecretRefs sets values of references removed by maskSecretRefs
+// in the decrypted data.
+func (g Generator) resolveSecretRefs(ctx context.Context, data []byte, refs map[string]SecretRef) ([]byte, error) {
+	svc, err := newPathService(data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Resolve in a stable order so errors are reproducible.
+	var refPaths []string
+	for p := range refs {
+		refPaths = append(refPaths, p)
+	}
+	sort.Strings(refPaths)
+
+	for _, p := range refPaths {
+		if g.secretRefResolver == nil {
+			return nil, microerror.Maskf(secretRefNotConfiguredError, "can not resolve %#q", p)
+		}
+
+		value, err := g.secretRefResolver.Resolve(ctx, refs[p])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = svc.Set(p, value)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	resolved, err := svc.OutputBytes()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return resolved, nil
+}
@@ -13,3 +13,15 @@ type Filesystem interface {
 type DecryptTraverser interface {
 	Traverse(context.Context, []byte) ([]byte, error)
 }
+
+// VaultKVReader reads values of Vault KV secrets for the vaultKV template
+// function.
+type VaultKVReader interface {
+	Read(ctx context.Context, path, key string) (string, error)
+}
+
+// SecretRefResolver reads values referenced by secretRef objects of
+// installation secret.yaml.
+type SecretRefResolver interface {
+	Resolve(ctx context.Context, ref SecretRef) (string, error)
+}
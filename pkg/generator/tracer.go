@@ -0,0 +1,131 @@
+package generator
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer receives events emitted during configuration generation.
+// Implementations must be safe for concurrent use.
+type Tracer interface {
+	Trace(ctx context.Context, event Event)
+}
+
+// Event is one of StepStarted, StepFinished, FileRead, PatchApplied and
+// DecryptionPerformed.
+type Event interface {
+	isEvent()
+}
+
+// Step is a configuration generation step. See generateRawConfig for
+// details.
+type Step int
+
+const (
+	StepConfigData          Step = 1
+	StepConfigmapTemplate   Step = 2
+	StepConfigmapPatch      Step = 3
+	StepConfigmapPatchApply Step = 4
+	StepSecretData          Step = 5
+	StepSecretTemplate      Step = 6
+	StepSecretPatch         Step = 7
+	StepSecretPatchApply    Step = 8
+)
+
+var stepDescriptions = map[Step]string{
+	StepConfigData:          "patch config values",
+	StepConfigmapTemplate:   "render configmap-values template",
+	StepConfigmapPatch:      "render configmap-values patch",
+	StepConfigmapPatchApply: "apply configmap-values patch",
+	StepSecretData:          "decrypt and resolve installation secret",
+	StepSecretTemplate:      "render secret-values template",
+	StepSecretPatch:         "render and decrypt secret-values patch",
+	StepSecretPatchApply:    "apply secret-values patch",
+}
+
+func (s Step) String() string {
+	d, ok := stepDescriptions[s]
+	if !ok {
+		return "unknown step"
+	}
+	return d
+}
+
+// StepStarted is emitted when a step starts. App is empty for steps
+// StepConfigData and StepSecretData which are shared by all apps of the
+// installation.
+type StepStarted struct {
+	App  string
+	Step Step
+	// Filepath of the processed patch for steps applied once per patch.
+	Filepath string
+}
+
+// StepFinished is emitted when a step finishes successfully.
+type StepFinished struct {
+	App      string
+	Step     Step
+	Filepath string
+	Duration time.Duration
+	// Output is the intermediate YAML produced by the step. Outputs of
+	// steps StepSecretData, StepSecretTemplate, StepSecretPatch and
+	// StepSecretPatchApply contain decrypted secret values.
+	Output string
+	// Skipped is true when the patch processed by the step does not
+	// exist.
+	Skipped bool
+}
+
+// FileRead is emitted when a file is read from the config repository.
+type FileRead struct {
+	Filepath string
+}
+
+// PatchApplied is emitted when a patch is applied.
+type PatchApplied struct {
+	// Filepath of the patch.
+	Filepath string
+	// Target is the patched file or the generated values file.
+	Target string
+}
+
+// DecryptionPerformed is emitted when secret values of a file are
+// decrypted.
+type DecryptionPerformed struct {
+	Filepath string
+	Duration time.Duration
+}
+
+func (StepStarted) isEvent()         {}
+func (StepFinished) isEvent()        {}
+func (FileRead) isEvent()            {}
+func (PatchApplied) isEvent()        {}
+func (DecryptionPerformed) isEvent() {}
+
+func (g Generator) trace(ctx context.Context, event Event) {
+	if g.tracer != nil {
+		g.tracer.Trace(ctx, event)
+	}
+}
+
+// startStep emits StepStarted and returns a function emitting StepFinished
+// with the step duration.
+func (g Generator) startStep(ctx context.Context, app string, step Step, filepath string) func(output string, skipped bool) {
+	g.trace(ctx, StepStarted{
+		App:      app,
+		Step:     step,
+		Filepath: filepath,
+	})
+
+	start := time.Now()
+	return func(output string, skipped bool) {
+		g.trace(ctx, StepFinished{
+			App:      app,
+			Step:     step,
+			Filepath: filepath,
+			Duration: time.Since(start),
+			Output:   output,
+			Skipped:  skipped,
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package tracer provides generator.Tracer implementations.
+package tracer
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/config-controller/pkg/generator"
+)
+
+type LoggerConfig struct {
+	Logger micrologger.Logger
+}
+
+// Logger writes generation events to micrologger with debug level. It never
+// logs step outputs as they may contain decrypted secret values.
+type Logger struct {
+	logger micrologger.Logger
+}
+
+func NewLogger(config LoggerConfig) (*Logger, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	l := &Logger{
+		logger: config.Logger,
+	}
+
+	return l, nil
+}
+
+func (l *Logger) Trace(ctx context.Context, event generator.Event) {
+	switch e := event.(type) {
+	case generator.StepStarted:
+		l.logger.Debugf(ctx, "%s", describeStep(e.App, e.Step, e.Filepath))
+	case generator.StepFinished:
+		if e.Skipped {
+			l.logger.Debugf(ctx, "skipped %s, file not found", describeStep(e.App, e.Step, e.Filepath))
+		} else {
+			l.logger.Debugf(ctx, "finished %s in %s", describeStep(e.App, e.Step, e.Filepath), e.Duration)
+		}
+	case generator.FileRead:
+		l.logger.Debugf(ctx, "read %#q", e.Filepath)
+	case generator.PatchApplied:
+		l.logger.Debugf(ctx, "patched %#q with %#q", e.Target, e.Filepath)
+	case generator.DecryptionPerformed:
+		l.logger.Debugf(ctx, "decrypted %#q in %s", e.Filepath, e.Duration)
+	}
+}
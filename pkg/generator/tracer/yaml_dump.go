@@ -0,0 +1,75 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/pkg/generator"
+)
+
+type YAMLDumpConfig struct {
+	Writer io.Writer
+}
+
+// YAMLDump writes intermediate YAML produced by each generation step as
+// a separate YAML document. Documents of secret steps contain decrypted
+// secret values.
+type YAMLDump struct {
+	writer io.Writer
+
+	mutex sync.Mutex
+}
+
+func NewYAMLDump(config YAMLDumpConfig) (*YAMLDump, error) {
+	if config.Writer == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Writer must not be empty", config)
+	}
+
+	d := &YAMLDump{
+		writer: config.Writer,
+	}
+
+	return d, nil
+}
+
+func (d *YAMLDump) Trace(ctx context.Context, event generator.Event) {
+	e, ok := event.(generator.StepFinished)
+	if !ok || e.Skipped {
+		return
+	}
+
+	output := e.Output
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Errors are ignored as tracing must not affect generation.
+	_, _ = fmt.Fprintf(d.writer, "--- # %s [%s]\n%s", describeStep(e.App, e.Step, e.Filepath), e.Duration, output)
+}
+
+// describeStep returns e.g. "step 3: render configmap-values patch (app
+// "kvm-operator", file "installations/puma/apps/kvm-operator/configmap-values.yaml.patch")".
+func describeStep(app string, step generator.Step, filepath string) string {
+	var details []string
+	if app != "" {
+		details = append(details, fmt.Sprintf("app %q", app))
+	}
+	if filepath != "" {
+		details = append(details, fmt.Sprintf("file %q", filepath))
+	}
+
+	s := fmt.Sprintf("step %d: %s", step, step)
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return s
+}
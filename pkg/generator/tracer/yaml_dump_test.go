@@ -0,0 +1,41 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/giantswarm/config-controller/pkg/generator"
+)
+
+func TestYAMLDump_Trace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d, err := NewYAMLDump(YAMLDumpConfig{Writer: buf})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx := context.Background()
+	events := []generator.Event{
+		generator.StepStarted{Step: generator.StepConfigData, Filepath: "default/config.yaml"},
+		generator.FileRead{Filepath: "default/config.yaml"},
+		generator.StepFinished{Step: generator.StepConfigData, Filepath: "default/config.yaml", Duration: time.Millisecond, Output: "region: eu-west-1\n"},
+		generator.StepFinished{App: "operator", Step: generator.StepConfigmapPatch, Filepath: "installations/puma/apps/operator/configmap-values.yaml.patch", Skipped: true},
+		generator.StepFinished{App: "operator", Step: generator.StepConfigmapTemplate, Duration: 2 * time.Millisecond, Output: "region: eu-west-1"},
+	}
+	for _, e := range events {
+		d.Trace(ctx, e)
+	}
+
+	expected := `--- # step 1: patch config values (file "default/config.yaml") [1ms]
+region: eu-west-1
+--- # step 2: render configmap-values template (app "operator") [2ms]
+region: eu-west-1
+`
+	if buf.String() != expected {
+		t.Fatalf("output not expected, got:\n%s", cmp.Diff(expected, buf.String()))
+	}
+}
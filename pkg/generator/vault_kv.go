@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/giantswarm/microerror"
+)
+
+// vaultKVFunc implements the vaultKV template function. It returns the value
+// of the key of the Vault KV secret under path, e.g.:
+//
+//	password: {{ vaultKV "secret/data/my-app" "password" | quote }}
+//
+// The function is available only in secret-values templates and patches.
+type vaultKVFunc func(path, key string) (string, error)
+
+// PlaceholderVaultKVReader returns placeholders instead of reading secrets
+// from Vault. It is meant for tools which never output secret values.
+type PlaceholderVaultKVReader struct{}
+
+func (PlaceholderVaultKVReader) Read(ctx context.Context, path, key string) (string, error) {
+	return vaultKVPlaceholder(path, key), nil
+}
+
+func vaultKVPlaceholder(path, key string) string {
+	return fmt.Sprintf("vaultKV(%q, %q)", path, key)
+}
+
+// readVaultKV returns vaultKVFunc reading secrets with the configured
+// VaultKVReader. reads is incremented with every read.
+func (g Generator) readVaultKV(ctx context.Context, reads *int) vaultKVFunc {
+	return func(path, key string) (string, error) {
+		if g.vaultKVReader == nil {
+			return "", microerror.Maskf(vaultKVNotConfiguredError, "can not read key %#q of %#q", key, path)
+		}
+
+		value, err := g.vaultKVReader.Read(ctx, path, key)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		if reads != nil {
+			*reads++
+		}
+
+		return value, nil
+	}
+}
+
+// disallowedVaultKV is used when rendering configmap-values templates and
+// patches.
+func disallowedVaultKV(path, key string) (string, error) {
+	return "", microerror.Maskf(vaultKVNotAllowedError, "vaultKV can be used only in secret-values templates and patches")
+}
+
+// placeholderVaultKV is used when rendering templates only to find out
+// where their values come from.
+func placeholderVaultKV(path, key string) (string, error) {
+	return vaultKVPlaceholder(path, key), nil
+}
+
+// maskVaultKVValues removes values read with vaultKV from the rendered
+// secret patch. They are plaintext and would be rejected by the decrypt
+// traverser of installations with the strict secret policy. The patch
+// template at filepath is rendered again with placeholders instead of
+// secrets, and the values differing between both renders are set to null
+// in the returned patch. Removed values are returned by their paths so they
+// can be put back with restoreVaultKVValues after decryption.
+func (g Generator) maskVaultKVValues(ctx context.Context, filepath, templateData string, patch []byte) ([]byte, map[string]interface{}, error) {
+	templateBytes, err := g.fs.ReadFile(filepath)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	placeholderPatch, err := g.render(ctx, string(templateBytes), templateData, nil, placeholderVaultKV)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	placeholderPatchBytes, _, err := splitDirectives([]byte(placeholderPatch))
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	values, err := yamlValues(patch)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	placeholderValues, err := yamlValues(placeholderPatchBytes)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	svc, err := newPathService(patch)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	vaultKVValues := map[string]interface{}{}
+	for p, v := range values {
+		placeholderV, ok := placeholderValues[p]
+		if ok && reflect.DeepEqual(v, placeholderV) {
+			continue
+		}
+
+		vaultKVValues[p] = v
+		err = svc.Set(p, nil)
+		if err != nil {
+			return nil, nil, microerror.Mask(err)
+		}
+	}
+
+	masked, err := svc.OutputBytes()
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	return masked, vaultKVValues, nil
+}
+
+// restoreVaultKVValues sets values removed by maskVaultKVValues in the
+// decrypted secret patch.
+func restoreVaultKVValues(patch []byte, vaultKVValues map[string]interface{}) ([]byte, error) {
+	svc, err := newPathService(patch)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for p, v := range vaultKVValues {
+		err = svc.Set(p, v)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	restored, err := svc.OutputBytes()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return restored, nil
+}
@@ -2,40 +2,123 @@ package github
 
 import (
 	"context"
-	"fmt"
 	"regexp"
-	"sort"
-	"strconv"
+	"strings"
 
 	"github.com/giantswarm/microerror"
 
 	"github.com/giantswarm/config-controller/pkg/github/internal/gitrepo"
 	"github.com/giantswarm/config-controller/pkg/github/internal/graphql"
+	"github.com/giantswarm/config-controller/pkg/github/internal/token"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 )
 
+var gitHubURLPattern = regexp.MustCompile(`^(?:https://(?:[^@/]+@)?github\.com/|ssh://git@github\.com/|git@github\.com:)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+const (
+	// DefaultURL is the URL of the config repository used when
+	// Config.URL is empty.
+	DefaultURL = "https://github.com/giantswarm/config.git"
+)
+
+// AppConfig identifies an installation of a GitHub App.
+type AppConfig struct {
+	// ID is the GitHub App ID.
+	ID int64
+	// InstallationID is the ID of the app installation with access to the
+	// config repository.
+	InstallationID int64
+	// PrivateKey is the PEM encoded private key of the app.
+	PrivateKey []byte
+}
+
 type Config struct {
+	// App is optional. When set, installation access tokens of the GitHub
+	// App are minted, refreshed before they expire and used like Token.
+	// It is mutually exclusive with Token and can be set only when URL is
+	// a GitHub repository.
+	App *AppConfig
+	// Token is optional. When set, it authenticates HTTPS requests to
+	// github.com and GitHub GraphQL API calls. It is never sent to other
+	// hosts.
 	Token string
+	// Username and Password are optional. When Password is set, they
+	// authenticate HTTPS requests to repositories not hosted on GitHub.
+	Username string
+	Password string
+	// URL of the config repository. Any git remote is supported, e.g.
+	// "https://gitlab.example.com/team/config.git",
+	// "ssh://git@gitea.example.com/team/config.git" or
+	// "git://localhost/config". Defaults to DefaultURL.
+	URL string
+
+	// CacheDir is optional. When set, the repository is kept on disk as a
+	// bare clone updated with incremental fetches, and files are checked
+	// out once per commit. When empty, the repository is cloned into
+	// memory on every read.
+	CacheDir string
+	// CacheMaxSize is the maximum total size in bytes of checkouts and the
+	// bare clone kept in CacheDir. Zero means no limit.
+	CacheMaxSize int64
 }
 
+// GitHub reads the config repository from a git remote. Tags of
+// repositories hosted on GitHub are listed with GitHub GraphQL API when
+// Config.Token or Config.App is set. Tags of other repositories are listed with the git
+// protocol.
 type GitHub struct {
 	graphQLClient *graphql.Client
 	repo          *gitrepo.Repo
+
+	url string
+	// owner and name are set only for repositories hosted on GitHub.
+	owner string
+	name  string
 }
 
 func New(config Config) (*GitHub, error) {
-	if config.Token == "" {
-		return nil, microerror.Maskf(invalidConfigError, "%T.Token must not be empty", config)
+	if config.URL == "" {
+		config.URL = DefaultURL
+	}
+
+	if config.App != nil && config.Token != "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.App and %T.Token must not be set together", config, config)
 	}
 
 	var err error
 
+	owner, name, isGitHub := parseGitHubURL(config.URL)
+
+	if config.App != nil && !isGitHub {
+		return nil, microerror.Maskf(invalidConfigError, "%T.App must be empty when %T.URL %#q is not a GitHub repository", config, config, config.URL)
+	}
+
+	// tokenSource is shared by GraphQL and git clients so both use the
+	// same, possibly rotating, credentials. It is created only for GitHub
+	// repositories so GitHub credentials are never sent to other hosts.
+	var tokenSource token.Source
+	if config.App != nil {
+		c := token.AppConfig{
+			ID:             config.App.ID,
+			InstallationID: config.App.InstallationID,
+			PrivateKey:     config.App.PrivateKey,
+		}
+
+		tokenSource, err = token.NewApp(c)
+		if token.IsInvalidConfig(err) {
+			return nil, microerror.Maskf(invalidConfigError, "%T.App is invalid: %s", config, err)
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else if isGitHub && config.Token != "" {
+		tokenSource = token.Static(config.Token)
+	}
+
 	var graphQLClient *graphql.Client
-	{
+	if tokenSource != nil {
 		c := graphql.Config{
-			Headers: map[string]string{
-				"Authorization": "bearer " + config.Token,
-			},
-			URL: "https://api.github.com/graphql",
+			TokenSource: tokenSource,
+			URL:         "https://api.github.com/graphql",
 		}
 		graphQLClient, err = graphql.New(c)
 		if err != nil {
@@ -46,11 +129,18 @@ func New(config Config) (*GitHub, error) {
 	var repo *gitrepo.Repo
 	{
 		c := gitrepo.Config{
-			GitHubToken: config.Token,
+			TokenSource: tokenSource,
+			Username:    config.Username,
+			Password:    config.Password,
+
+			CacheDir:     config.CacheDir,
+			CacheMaxSize: config.CacheMaxSize,
 		}
 
 		repo, err = gitrepo.New(c)
-		if err != nil {
+		if gitrepo.IsInvalidConfig(err) {
+			return nil, microerror.Maskf(invalidConfigError, "%s", err)
+		} else if err != nil {
 			return nil, microerror.Mask(err)
 		}
 	}
@@ -58,29 +148,51 @@ func New(config Config) (*GitHub, error) {
 	g := &GitHub{
 		graphQLClient: graphQLClient,
 		repo:          repo,
+
+		url:   config.URL,
+		owner: owner,
+		name:  name,
 	}
 
 	return g, nil
 }
 
-func (g *GitHub) GetLatestTag(ctx context.Context, owner, name, major string) (string, error) {
-	tags, err := g.getTags(ctx, owner, name, major)
+// IsGitHubURL returns true when the repository URL points to a repository
+// hosted on GitHub.
+func IsGitHubURL(url string) bool {
+	_, _, ok := parseGitHubURL(url)
+	return ok
+}
+
+// URL returns the URL of the config repository.
+func (g *GitHub) URL() string {
+	return g.url
+}
+
+// GetLatestTag returns the highest version tag of the repository
+// satisfying the constraint of the spec.
+func (g *GitHub) GetLatestTag(ctx context.Context, spec *versionspec.Spec) (string, error) {
+	if spec.IsBranch() {
+		return "", microerror.Maskf(executionFailedError, "configuration version %#q is a branch", spec)
+	}
+
+	tags, err := g.getTags(ctx, spec.TagFilter())
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
 
-	latest := getLatestTag(tags)
-
-	if latest == "" {
-		return "", microerror.Maskf(executionFailedError, "did not find tag for `%s/%s` for major %#q", owner, name, major)
+	latest, err := spec.Latest(tags)
+	if versionspec.IsNotFound(err) {
+		return "", microerror.Maskf(notFoundError, "did not find tag for %#q satisfying %#q", g.url, spec)
+	} else if err != nil {
+		return "", microerror.Mask(err)
 	}
 
 	return latest, nil
 }
 
-func (g *GitHub) GetFilesByTag(ctx context.Context, owner, name, tag string) (Store, error) {
-	url := "https://github.com/" + owner + "/" + name + ".git"
-	store, err := g.repo.ShallowCloneTag(ctx, url, tag)
+func (g *GitHub) GetFilesByTag(ctx context.Context, tag string) (Store, error) {
+	store, err := g.repo.ShallowCloneTag(ctx, g.url, tag)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -88,9 +200,8 @@ func (g *GitHub) GetFilesByTag(ctx context.Context, owner, name, tag string) (St
 	return store, nil
 }
 
-func (g *GitHub) GetFilesByBranch(ctx context.Context, owner, name, branch string) (Store, error) {
-	url := "https://github.com/" + owner + "/" + name + ".git"
-	store, err := g.repo.ShallowCloneBranch(ctx, url, branch)
+func (g *GitHub) GetFilesByBranch(ctx context.Context, branch string) (Store, error) {
+	store, err := g.repo.ShallowCloneBranch(ctx, g.url, branch)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -98,9 +209,37 @@ func (g *GitHub) GetFilesByBranch(ctx context.Context, owner, name, branch strin
 	return store, nil
 }
 
-// getTags returns a list of tags for the given owner/name. Only tags containing
+// getTags returns a list of tags of the repository. Only tags containing
 // filter string are returned. When filter is empty all tags are returned.
-func (g *GitHub) getTags(ctx context.Context, owner, name, filter string) ([]string, error) {
+func (g *GitHub) getTags(ctx context.Context, filter string) ([]string, error) {
+	if g.graphQLClient != nil {
+		tags, err := g.getGitHubTags(ctx, g.owner, g.name, filter)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return tags, nil
+	}
+
+	all, err := g.repo.ListTags(ctx, g.url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var tags []string
+	for _, t := range all {
+		if strings.Contains(t, filter) {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags, nil
+}
+
+// getGitHubTags returns a list of tags for the given owner/name with GitHub
+// GraphQL API. Only tags containing filter string are returned. When filter
+// is empty all tags are returned.
+func (g *GitHub) getGitHubTags(ctx context.Context, owner, name, filter string) ([]string, error) {
 	const query = `
 		query($owner:String!, $name:String!, $filter:String!, $after:String) {
 		  repository(name: $name, owner: $owner) {
@@ -170,58 +309,15 @@ func (g *GitHub) getTags(ctx context.Context, owner, name, filter string) ([]str
 	return tags, nil
 }
 
-func getLatestTag(tags []string) string {
-	re := regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)$`)
-
-	type MajorMinorPatch [3]int
-
-	var versions []MajorMinorPatch
-	for _, t := range tags {
-		subs := re.FindStringSubmatch(t)
-		if len(subs) == 4 {
-			major, err := strconv.Atoi(subs[1])
-			if err != nil {
-				panic(microerror.Pretty(microerror.Mask(err), true))
-			}
-			minor, err := strconv.Atoi(subs[2])
-			if err != nil {
-				panic(microerror.Pretty(microerror.Mask(err), true))
-			}
-			patch, err := strconv.Atoi(subs[3])
-			if err != nil {
-				panic(microerror.Pretty(microerror.Mask(err), true))
-			}
-
-			versions = append(versions, MajorMinorPatch{major, minor, patch})
-		}
-	}
-
-	lessFunc := func(i, j int) bool {
-		x := versions[i]
-		y := versions[j]
-		switch {
-		case x[0] < y[0]:
-			return true
-		case x[0] > y[0]:
-			return false
-		case x[1] < y[1]:
-			return true
-		case x[1] > y[1]:
-			return false
-		case x[2] < y[2]:
-			return true
-		case x[2] > y[2]:
-			return false
-		}
-		return false
-	}
-
-	sort.Slice(versions, lessFunc)
-
-	if len(versions) == 0 {
-		return ""
+// parseGitHubURL returns owner and name of the repository when the URL
+// points to a repository hosted on GitHub, e.g.
+// "https://github.com/giantswarm/config.git" or
+// "git@github.com:giantswarm/config.git".
+func parseGitHubURL(url string) (owner, name string, ok bool) {
+	subs := gitHubURLPattern.FindStringSubmatch(url)
+	if len(subs) != 3 {
+		return "", "", false
 	}
 
-	v := versions[len(versions)-1]
-	return fmt.Sprintf("v%d.%d.%d", v[0], v[1], v[2])
+	return subs[1], subs[2], true
 }
@@ -1,76 +1,127 @@
 package github
 
 import (
-	"reflect"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/giantswarm/config-controller/pkg/versionspec"
 )
 
-func Test_getLatestTag(t *testing.T) {
+func Test_parseGitHubURL(t *testing.T) {
 	testCases := []struct {
-		name        string
-		inputTags   []string
-		expectedTag string
+		name          string
+		url           string
+		expectedOwner string
+		expectedName  string
+		expectedOK    bool
 	}{
 		{
-			name:        "case 0: nil",
-			inputTags:   nil,
-			expectedTag: "",
+			name:          "case 0: default",
+			url:           DefaultURL,
+			expectedOwner: "giantswarm",
+			expectedName:  "config",
+			expectedOK:    true,
 		},
 		{
-			name: "case 1: empty",
-			inputTags: []string{
-				"",
-				"non-version-tag",
-			},
-			expectedTag: "",
+			name:          "case 1: HTTPS without .git suffix",
+			url:           "https://github.com/acme/config",
+			expectedOwner: "acme",
+			expectedName:  "config",
+			expectedOK:    true,
 		},
 		{
-			name: "case 2: ignore pre-releases",
-			inputTags: []string{
-				"v1.0.0-pre-release",
-			},
-			expectedTag: "",
+			name:          "case 2: SCP-like SSH",
+			url:           "git@github.com:acme/config.git",
+			expectedOwner: "acme",
+			expectedName:  "config",
+			expectedOK:    true,
+		},
+		{
+			name: "case 3: GitLab",
+			url:  "https://gitlab.example.com/acme/config.git",
 		},
 		{
-			name: "case 3: ignore pre-releases",
-			inputTags: []string{
-				"v2.0.0-pre-release",
-				"v1.0.0",
+			name: "case 4: git daemon",
+			url:  "git://localhost/config",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			owner, name, ok := parseGitHubURL(tc.url)
+
+			if owner != tc.expectedOwner || name != tc.expectedName || ok != tc.expectedOK {
+				t.Fatalf("parseGitHubURL(%q) = %q, %q, %t, want %q, %q, %t", tc.url, owner, name, ok, tc.expectedOwner, tc.expectedName, tc.expectedOK)
+			}
+		})
+	}
+}
+
+func TestNew_app(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	app := &AppConfig{
+		ID:             123,
+		InstallationID: 456,
+		PrivateKey:     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}
+
+	testCases := []struct {
+		name                string
+		config              Config
+		expectGraphQLClient bool
+		errorMatcher        func(error) bool
+	}{
+		{
+			name: "case 0: GitHub repository",
+			config: Config{
+				App: app,
+				URL: "https://github.com/acme/config.git",
 			},
-			expectedTag: "v1.0.0",
+			expectGraphQLClient: true,
 		},
 		{
-			name: "case 4: major",
-			inputTags: []string{
-				"v2.0.0",
-				"v1.2.3",
-				"non-version-tag",
+			name: "case 1: not a GitHub repository",
+			config: Config{
+				App: app,
+				URL: "https://gitlab.example.com/acme/config.git",
 			},
-			expectedTag: "v2.0.0",
+			errorMatcher: IsInvalidConfig,
 		},
 		{
-			name: "case 5: minor",
-			inputTags: []string{
-				"v2.5.9",
-				"non-version-tag",
-				"v2.10.3",
-				"v2.2.1",
+			name: "case 2: token and app",
+			config: Config{
+				App:   app,
+				Token: "token",
+				URL:   "https://github.com/acme/config.git",
 			},
-			expectedTag: "v2.10.3",
+			errorMatcher: IsInvalidConfig,
 		},
 		{
-			name: "case 6: patch",
-			inputTags: []string{
-				"non-version-tag",
-				"v2.10.300",
-				"v1.5.500",
-				"v2.10.370",
-				"v2.10.372",
-				"v2.10.301",
-				"v2.10.360",
+			name: "case 3: token not used for other repositories",
+			config: Config{
+				Token: "token",
+				URL:   "https://gitlab.example.com/acme/config.git",
 			},
-			expectedTag: "v2.10.372",
+			expectGraphQLClient: false,
 		},
 	}
 
@@ -78,11 +129,193 @@ func Test_getLatestTag(t *testing.T) {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			t.Log(tc.name)
 
-			tag := getLatestTag(tc.inputTags)
+			g, err := New(tc.config)
 
-			if !reflect.DeepEqual(tag, tc.expectedTag) {
-				t.Fatalf("tag = %q, want %q", tag, tc.expectedTag)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if (g.graphQLClient != nil) != tc.expectGraphQLClient {
+				t.Fatalf("graphQLClient = %v, want set = %t", g.graphQLClient, tc.expectGraphQLClient)
 			}
 		})
 	}
 }
+
+// TestGitHub_gitRemote reads a repository served over the git protocol
+// from a local directory.
+func TestGitHub_gitRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo := newTestRepository(t, dir, "v1.0.0", "v1.1.0", "v2.0.0")
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	g, err := New(Config{URL: "file://" + dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx := context.Background()
+
+	spec, err := versionspec.Parse("1.x.x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tag, err := g.GetLatestTag(ctx, spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if tag != "v1.1.0" {
+		t.Fatalf("tag = %q, want %q", tag, "v1.1.0")
+	}
+
+	store, err := g.GetFilesByTag(ctx, tag)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	assertVersionFile(t, store, "v1.1.0")
+
+	store, err = g.GetFilesByBranch(ctx, head.Name().Short())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	assertVersionFile(t, store, "v2.0.0")
+}
+
+// TestGitHub_cacheDir reads a repository through the on-disk cache and
+// checks that new commits are fetched and checked out per commit.
+func TestGitHub_cacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	remoteDir := filepath.Join(dir, "remote")
+	cacheDir := filepath.Join(dir, "cache")
+
+	repo := newTestRepository(t, remoteDir, "v1.0.0")
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	branch := head.Name().Short()
+
+	g, err := New(Config{URL: "file://" + remoteDir, CacheDir: cacheDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx := context.Background()
+
+	store, err := g.GetFilesByTag(ctx, "v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	assertVersionFile(t, store, "v1.0.0")
+
+	// The branch points to the same commit so the checkout is reused.
+	store, err = g.GetFilesByBranch(ctx, branch)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	assertVersionFile(t, store, "v1.0.0")
+
+	commitTestVersion(t, repo, remoteDir, "v1.1.0")
+
+	store, err = g.GetFilesByBranch(ctx, branch)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	assertVersionFile(t, store, "v1.1.0")
+
+	// Stores of earlier commits stay readable.
+	store, err = g.GetFilesByTag(ctx, "v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	assertVersionFile(t, store, "v1.0.0")
+
+	checkouts, err := filepath.Glob(filepath.Join(cacheDir, "*", "checkouts", "*"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(checkouts) != 2 {
+		t.Fatalf("len(checkouts) = %d, want %d: %v", len(checkouts), 2, checkouts)
+	}
+}
+
+// newTestRepository initializes a git repository in dir with a commit
+// for every tag. Every commit sets content of the "version" file to its tag.
+func newTestRepository(t *testing.T, dir string, tags ...string) *git.Repository {
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, tag := range tags {
+		hash := commitTestVersion(t, repo, dir, tag)
+
+		_, err = repo.CreateTag(tag, hash, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	return repo
+}
+
+func commitTestVersion(t *testing.T, repo *git.Repository, dir, version string) plumbing.Hash {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "version"), []byte(version), 0644) // nolint:gosec
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = worktree.Add("version")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hash, err := worktree.Commit(version, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return hash
+}
+
+func assertVersionFile(t *testing.T, store Store, expected string) {
+	data, err := store.ReadFile("version")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+	if string(data) != expected {
+		t.Fatalf("version = %q, want %q", data, expected)
+	}
+}
@@ -0,0 +1,487 @@
+package gitrepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+const (
+	// checkoutMinAge protects recently used checkouts from garbage
+	// collection. Stores returned for them may still be read by callers.
+	checkoutMinAge = 10 * time.Minute
+
+	bareRepositoryDir = "repository.git"
+	checkoutsDir      = "checkouts"
+	tmpCheckoutPrefix = ".tmp-"
+)
+
+var (
+	// cacheDirMutexes serialize changes of cache directories of
+	// repositories by all Repos in the process. Each repository has its
+	// own mutex so a slow remote does not block others.
+	cacheDirMutexes      = map[string]*sync.Mutex{}
+	cacheDirMutexesMutex sync.Mutex
+)
+
+// cachedCheckout updates the bare repository of the url in the cache
+// directory with an incremental fetch of the ref and returns a Store for the
+// files of the commit the ref points to. Files of every commit are checked
+// out only once and reused until they are garbage collected.
+func (r *Repo) cachedCheckout(ctx context.Context, url string, ref plumbing.ReferenceName) (*Store, error) {
+	auth, err := r.auth(ctx, url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// The ref is resolved on the remote before the cache directory is
+	// locked. Objects are fetched only when they are missing, so the lock
+	// is held across network calls only when the repository changed.
+	remoteHash, err := lsRemote(url, ref, auth)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	dir := filepath.Join(r.cacheDir, urlDirName(url))
+
+	mutex := cacheDirMutex(dir)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	repo, err := openBareRepository(filepath.Join(dir, bareRepositoryDir), url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	hash, err := resolveCommit(repo, remoteHash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		hash, err = fetch(ctx, repo, ref, auth)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	checkouts := filepath.Join(dir, checkoutsDir)
+	path := filepath.Join(checkouts, hash.String())
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		err = writeCheckout(repo, hash, checkouts, path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Modification time of the checkout directory tracks its last use
+	// for garbage collection.
+	now := time.Now()
+	err = os.Chtimes(path, now, now)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = collectGarbage(dir, r.cacheMaxSize, now)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	store := &Store{
+		fs: osfs.New(path),
+	}
+
+	return store, nil
+}
+
+// cacheDirMutex returns the mutex of the cache directory of a repository.
+func cacheDirMutex(dir string) *sync.Mutex {
+	cacheDirMutexesMutex.Lock()
+	defer cacheDirMutexesMutex.Unlock()
+
+	m, ok := cacheDirMutexes[dir]
+	if !ok {
+		m = &sync.Mutex{}
+		cacheDirMutexes[dir] = m
+	}
+
+	return m
+}
+
+// lsRemote returns the hash the ref points to in the remote repository,
+// like "git ls-remote". Only refs are transferred.
+func lsRemote(url string, ref plumbing.ReferenceName, auth transport.AuthMethod) (plumbing.Hash, error) {
+	refs, err := listRefs(url, auth)
+	if err != nil {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	for _, r := range refs {
+		if r.Name() == ref {
+			return r.Hash(), nil
+		}
+	}
+
+	return plumbing.ZeroHash, microerror.Maskf(notFoundError, "reference %#q does not exist in %#q", ref, url)
+}
+
+// resolveCommit returns the commit hash points to. Annotated tags are
+// peeled to their commits. plumbing.ErrObjectNotFound is returned when the
+// object was not fetched yet.
+func resolveCommit(repo *git.Repository, hash plumbing.Hash) (plumbing.Hash, error) {
+	obj, err := repo.Object(plumbing.AnyObject, hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	switch o := obj.(type) {
+	case *object.Commit:
+		return o.Hash, nil
+	case *object.Tag:
+		commit, err := o.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, microerror.Mask(err)
+		}
+		return commit.Hash, nil
+	default:
+		return plumbing.ZeroHash, microerror.Maskf(executionFailedError, "object %#q is %s, want commit or tag", hash, obj.Type())
+	}
+}
+
+// fetch updates the bare repository with an incremental fetch of the ref and
+// returns the commit it points to.
+func fetch(ctx context.Context, repo *git.Repository, ref plumbing.ReferenceName, auth transport.AuthMethod) (plumbing.Hash, error) {
+	// Branches are stored as remote-tracking references like in regular
+	// clones. Tags are stored as they are.
+	localRef := ref
+	if ref.IsBranch() {
+		localRef = plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref.Short())
+	}
+
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		Auth:       auth,
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs: []config.RefSpec{
+			config.RefSpec(fmt.Sprintf("+%s:%s", ref, localRef)),
+		},
+		Tags: git.NoTags,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		// Fall through.
+	} else if err != nil {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	// ResolveRevision peels annotated tags to their commits.
+	hash, err := repo.ResolveRevision(plumbing.Revision(localRef))
+	if err != nil {
+		return plumbing.ZeroHash, microerror.Mask(err)
+	}
+
+	return *hash, nil
+}
+
+// openBareRepository opens the bare repository at path or initializes it
+// with the url as its origin remote when it does not exist yet.
+func openBareRepository(path, url string) (*git.Repository, error) {
+	repo, err := git.PlainOpen(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(path, true)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		_, err = repo.CreateRemote(&config.RemoteConfig{
+			Name: git.DefaultRemoteName,
+			URLs: []string{url},
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return repo, nil
+}
+
+// writeCheckout writes files of the commit to path. Files are written to a
+// temporary directory first and moved to path once complete so a partially
+// written checkout is never used.
+func writeCheckout(repo *git.Repository, hash plumbing.Hash, checkouts, path string) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.MkdirAll(checkouts, 0755) // nolint:gosec
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	tmp, err := ioutil.TempDir(checkouts, tmpCheckoutPrefix)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	files, err := commit.Files()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = files.ForEach(func(f *object.File) error {
+		return writeFile(tmp, f)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = checkSymlinks(tmp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func writeFile(dir string, f *object.File) error {
+	path := filepath.Join(dir, filepath.FromSlash(f.Name))
+
+	err := os.MkdirAll(filepath.Dir(path), 0755) // nolint:gosec
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if f.Mode == filemode.Symlink {
+		target, err := f.Contents()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = os.Symlink(target, path)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// checkSymlinks makes sure symlinks of the checkout in dir can not be used
+// to read files outside of it, e.g. "config.yaml -> /var/run/secrets/...",
+// like with in-memory clones. It fails for symlinks resolving outside of
+// dir. Dangling symlinks are removed so they can not point to files created
+// later.
+func checkSymlinks(dir string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		resolved, err := filepath.EvalSymlinks(path)
+		if os.IsNotExist(err) {
+			err = os.Remove(path)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			name, _ := filepath.Rel(dir, path)
+			return microerror.Maskf(executionFailedError, "symlink %#q points outside of the repository", filepath.ToSlash(name))
+		}
+
+		return nil
+	})
+}
+
+// collectGarbage removes least recently used checkouts of the repository
+// cache directory dir until the total size of checkouts and the bare
+// repository does not exceed maxSize. Checkouts used within checkoutMinAge
+// before now are kept even when the limit is exceeded. The bare repository
+// is never pruned by fetches and keeps objects of deleted branches and tags,
+// so it is removed when the limit is still exceeded. It is initialized again
+// with only the refs fetched afterwards. Leftovers of interrupted checkouts
+// are always removed. maxSize of zero disables the limit.
+func collectGarbage(dir string, maxSize int64, now time.Time) error {
+	checkouts := filepath.Join(dir, checkoutsDir)
+	infos, err := ioutil.ReadDir(checkouts)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	type checkout struct {
+		path    string
+		modTime time.Time
+		size    int64
+	}
+
+	var all []checkout
+	var total int64
+	for _, info := range infos {
+		path := filepath.Join(checkouts, info.Name())
+
+		// Temporary directories are written only while the mutex of
+		// the cache directory is held so they are left by interrupted
+		// processes.
+		if strings.HasPrefix(info.Name(), tmpCheckoutPrefix) {
+			err = os.RemoveAll(path)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			continue
+		}
+		if !info.IsDir() || maxSize <= 0 {
+			continue
+		}
+
+		size, err := dirSize(path)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		all = append(all, checkout{
+			path:    path,
+			modTime: info.ModTime(),
+			size:    size,
+		})
+		total += size
+	}
+
+	if maxSize <= 0 {
+		return nil
+	}
+
+	repository := filepath.Join(dir, bareRepositoryDir)
+	repositorySize, err := dirSize(repository)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	total += repositorySize
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].modTime.Before(all[j].modTime)
+	})
+
+	for _, c := range all {
+		if total <= maxSize {
+			break
+		}
+		if now.Sub(c.modTime) < checkoutMinAge {
+			continue
+		}
+
+		err = os.RemoveAll(c.path)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		total -= c.size
+	}
+
+	if total > maxSize && repositorySize > 0 {
+		err = os.RemoveAll(repository)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// dirSize returns the total size of regular files in dir. It is zero when
+// dir does not exist.
+func dirSize(dir string) (int64, error) {
+	_, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	var size int64
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return size, nil
+}
+
+// urlDirName returns the name of the cache subdirectory of the repository
+// url so repositories with different URLs never share objects.
+func urlDirName(url string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(url)))[:16]
+}
@@ -0,0 +1,299 @@
+package gitrepo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_collectGarbage(t *testing.T) {
+	now := time.Now()
+
+	type checkout struct {
+		name string
+		age  time.Duration
+		size int
+	}
+
+	testCases := []struct {
+		name           string
+		checkouts      []checkout
+		repositorySize int
+		maxSize        int64
+
+		expected           []string
+		expectedRepository bool
+	}{
+		{
+			name: "case 0: limit not exceeded",
+			checkouts: []checkout{
+				{name: "a", age: time.Hour, size: 10},
+				{name: "b", age: 2 * time.Hour, size: 10},
+			},
+			maxSize:  20,
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "case 1: least recently used removed first",
+			checkouts: []checkout{
+				{name: "a", age: time.Hour, size: 10},
+				{name: "b", age: 3 * time.Hour, size: 10},
+				{name: "c", age: 2 * time.Hour, size: 10},
+			},
+			maxSize:  15,
+			expected: []string{"a"},
+		},
+		{
+			name: "case 2: recently used kept over limit",
+			checkouts: []checkout{
+				{name: "a", age: time.Minute, size: 10},
+				{name: "b", age: 2 * time.Minute, size: 10},
+				{name: "c", age: time.Hour, size: 10},
+			},
+			maxSize:  5,
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "case 3: no limit",
+			checkouts: []checkout{
+				{name: "a", age: time.Hour, size: 10},
+				{name: "b", age: 2 * time.Hour, size: 10},
+			},
+			maxSize:  0,
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "case 4: interrupted checkout removed",
+			checkouts: []checkout{
+				{name: "a", age: time.Hour, size: 10},
+				{name: tmpCheckoutPrefix + "123", age: time.Hour, size: 10},
+			},
+			maxSize:  0,
+			expected: []string{"a"},
+		},
+		{
+			name: "case 5: bare repository counted",
+			checkouts: []checkout{
+				{name: "a", age: time.Hour, size: 10},
+				{name: "b", age: 2 * time.Hour, size: 10},
+			},
+			repositorySize:     10,
+			maxSize:            25,
+			expected:           []string{"a"},
+			expectedRepository: true,
+		},
+		{
+			name: "case 6: bare repository removed over limit",
+			checkouts: []checkout{
+				{name: "a", age: time.Minute, size: 10},
+			},
+			repositorySize: 10,
+			maxSize:        15,
+			expected:       []string{"a"},
+		},
+		{
+			name: "case 7: bare repository kept without limit",
+			checkouts: []checkout{
+				{name: "a", age: time.Hour, size: 10},
+			},
+			repositorySize:     10,
+			maxSize:            0,
+			expected:           []string{"a"},
+			expectedRepository: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			dir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			if tc.repositorySize > 0 {
+				path := filepath.Join(dir, bareRepositoryDir, "objects", "pack")
+				err = os.MkdirAll(path, 0755)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				err = ioutil.WriteFile(filepath.Join(path, "pack"), make([]byte, tc.repositorySize), 0644) // nolint:gosec
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			checkouts := filepath.Join(dir, checkoutsDir)
+			for _, c := range tc.checkouts {
+				path := filepath.Join(checkouts, c.name)
+				err = os.MkdirAll(path, 0755)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				err = ioutil.WriteFile(filepath.Join(path, "file"), make([]byte, c.size), 0644) // nolint:gosec
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				modTime := now.Add(-c.age)
+				err = os.Chtimes(path, modTime, modTime)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			err = collectGarbage(dir, tc.maxSize, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			infos, err := ioutil.ReadDir(checkouts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name())
+			}
+			sort.Strings(names)
+
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Fatalf("checkouts = %v, want %v", names, tc.expected)
+			}
+
+			_, err = os.Stat(filepath.Join(dir, bareRepositoryDir))
+			if exists := err == nil; exists != tc.expectedRepository {
+				t.Fatalf("repository exists = %t, want %t", exists, tc.expectedRepository)
+			}
+		})
+	}
+}
+
+func Test_checkSymlinks(t *testing.T) {
+	testCases := []struct {
+		name         string
+		links        map[string]string
+		expected     []string
+		errorMatcher func(error) bool
+	}{
+		{
+			name: "case 0: links inside the checkout kept",
+			links: map[string]string{
+				"a/link":   "../file",
+				"dir-link": "a",
+			},
+			expected: []string{"a/link", "dir-link", "file"},
+		},
+		{
+			name: "case 1: absolute link outside the checkout",
+			links: map[string]string{
+				"link": "/etc/hostname",
+			},
+			errorMatcher: func(err error) bool { return err != nil },
+		},
+		{
+			name: "case 2: relative link outside the checkout",
+			links: map[string]string{
+				"a/link": "../../outside",
+			},
+			errorMatcher: func(err error) bool { return err != nil },
+		},
+		{
+			name: "case 3: link outside through a directory link",
+			links: map[string]string{
+				"dir-link": ".",
+				"link":     "dir-link/../outside",
+			},
+			errorMatcher: func(err error) bool { return err != nil },
+		},
+		{
+			name: "case 4: dangling link removed",
+			links: map[string]string{
+				"link": "missing",
+			},
+			expected: []string{"file"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			parent, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer os.RemoveAll(parent)
+
+			// outside is a sibling of the checkout which must not be
+			// readable through its links.
+			err = ioutil.WriteFile(filepath.Join(parent, "outside"), []byte("secret"), 0644) // nolint:gosec
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			dir := filepath.Join(parent, "checkout")
+			err = os.MkdirAll(filepath.Join(dir, "a"), 0755)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			err = ioutil.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644) // nolint:gosec
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for name, target := range tc.links {
+				err = os.Symlink(target, filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			err = checkSymlinks(dir)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			var names []string
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					return nil
+				}
+				name, err := filepath.Rel(dir, path)
+				if err != nil {
+					return err
+				}
+				names = append(names, filepath.ToSlash(name))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			sort.Strings(names)
+
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Fatalf("files = %v, want %v", names, tc.expected)
+			}
+		})
+	}
+}
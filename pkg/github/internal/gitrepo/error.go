@@ -11,6 +11,15 @@ var executionFailedError = &microerror.Error{
 	Kind: "executionFailedError",
 }
 
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
 var notFoundError = &microerror.Error{
 	Kind: "notFoundError",
 }
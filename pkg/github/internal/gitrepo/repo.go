@@ -2,27 +2,72 @@ package gitrepo
 
 import (
 	"context"
+	"strings"
 
 	"github.com/giantswarm/microerror"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/giantswarm/config-controller/pkg/github/internal/token"
 )
 
 type Config struct {
-	GitHubToken string
+	// TokenSource is optional. When set, its token is used to
+	// authenticate HTTPS requests to github.com. It is asked for a token
+	// on every request so rotating credentials are picked up. The token is
+	// never sent to other hosts.
+	TokenSource token.Source
+	// Username and Password are optional. When Password is set, they
+	// authenticate HTTPS requests to repositories not hosted on
+	// github.com.
+	//
+	// Credentials are never sent over plain HTTP. Other protocols use
+	// their default authentication, e.g. SSH agent.
+	Username string
+	Password string
+
+	// CacheDir is optional. When set, repositories are kept as bare
+	// clones in the directory and updated with incremental fetches. Files
+	// of every commit are checked out once into a subdirectory named
+	// after the commit SHA. When empty, every clone is made into memory.
+	CacheDir string
+	// CacheMaxSize is the maximum total size in bytes of checkouts and
+	// bare clones kept in CacheDir. Least recently used checkouts are
+	// removed when it is exceeded. A bare clone is removed and fetched
+	// again when the limit is still exceeded, so the limit should be well
+	// above the size of the repository. Zero means no limit.
+	CacheMaxSize int64
 }
 
 type Repo struct {
-	gitHubToken string
+	tokenSource token.Source
+	username    string
+	password    string
+
+	cacheDir     string
+	cacheMaxSize int64
 }
 
 func New(config Config) (*Repo, error) {
+	if config.CacheMaxSize < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CacheMaxSize must not be negative", config)
+	}
+	if config.Password != "" && config.Username == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Username must not be empty when %T.Password is set", config, config)
+	}
+
 	r := &Repo{
-		gitHubToken: config.GitHubToken,
+		tokenSource: config.TokenSource,
+		username:    config.Username,
+		password:    config.Password,
+
+		cacheDir:     config.CacheDir,
+		cacheMaxSize: config.CacheMaxSize,
 	}
 
 	return r, nil
@@ -36,17 +81,26 @@ func (r *Repo) ShallowCloneBranch(ctx context.Context, url, branch string) (*Sto
 	return r.ShallowClone(ctx, url, plumbing.NewBranchReferenceName(branch))
 }
 
+// ShallowClone returns files of the ref. When Config.CacheDir is set, the
+// cached checkout of the commit the ref points to is returned instead of a
+// fresh clone.
 func (r *Repo) ShallowClone(ctx context.Context, url string, ref plumbing.ReferenceName) (*Store, error) {
-	var auth transport.AuthMethod
-	if r.gitHubToken != "" {
-		auth = &http.BasicAuth{
-			Username: "can-be-anything-but-not-empty",
-			Password: r.gitHubToken,
+	if r.cacheDir != "" {
+		store, err := r.cachedCheckout(ctx, url, ref)
+		if err != nil {
+			return nil, microerror.Mask(err)
 		}
+
+		return store, nil
+	}
+
+	auth, err := r.auth(ctx, url)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
 	fs := memfs.New()
-	_, err := git.CloneContext(ctx, memory.NewStorage(), fs, &git.CloneOptions{
+	_, err = git.CloneContext(ctx, memory.NewStorage(), fs, &git.CloneOptions{
 		Auth:          auth,
 		URL:           url,
 		ReferenceName: ref,
@@ -63,3 +117,92 @@ func (r *Repo) ShallowClone(ctx context.Context, url string, ref plumbing.Refere
 
 	return store, err
 }
+
+// ListTags returns names of all tags of the remote repository. Only refs
+// are fetched, like with "git ls-remote --tags".
+func (r *Repo) ListTags(ctx context.Context, url string) ([]string, error) {
+	auth, err := r.auth(ctx, url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	refs, err := listRefs(url, auth)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var tags []string
+	for _, ref := range refs {
+		if ref.Name().IsTag() {
+			tags = append(tags, ref.Name().Short())
+		}
+	}
+
+	return tags, nil
+}
+
+// listRefs returns refs of the remote repository without fetching any
+// objects.
+func listRefs(url string, auth transport.AuthMethod) ([]*plumbing.Reference, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	refs, err := remote.List(&git.ListOptions{
+		Auth: auth,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return refs, nil
+}
+
+// auth returns credentials for the remote. The GitHub token is used only
+// for github.com and the password only for other hosts, so credentials are
+// not leaked to remotes they were not configured for. Nothing is sent over
+// plain HTTP.
+func (r *Repo) auth(ctx context.Context, url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if endpoint.Protocol != "https" {
+		return nil, nil
+	}
+
+	if isGitHubHost(endpoint.Host) {
+		if r.tokenSource == nil {
+			return nil, nil
+		}
+
+		t, err := r.tokenSource.Token(ctx)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		// GitHub requires this user name for GitHub App installation
+		// tokens and accepts it for personal access tokens too.
+		auth := &http.BasicAuth{
+			Username: "x-access-token",
+			Password: t,
+		}
+		return auth, nil
+	}
+
+	if r.password == "" {
+		return nil, nil
+	}
+
+	auth := &http.BasicAuth{
+		Username: r.username,
+		Password: r.password,
+	}
+	return auth, nil
+}
+
+func isGitHubHost(host string) bool {
+	return strings.EqualFold(host, "github.com")
+}
@@ -0,0 +1,136 @@
+package gitrepo
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/giantswarm/config-controller/pkg/github/internal/token"
+)
+
+func TestRepo_auth(t *testing.T) {
+	testCases := []struct {
+		name         string
+		url          string
+		tokenSource  token.Source
+		username     string
+		password     string
+		expectedAuth transport.AuthMethod
+		// expectedTokenCalls is the number of times the token source is
+		// asked for a token.
+		expectedTokenCalls int
+	}{
+		{
+			name:        "case 0: GitHub token sent to github.com",
+			url:         "https://github.com/giantswarm/config.git",
+			tokenSource: token.Static("gh-token"),
+			username:    "user",
+			password:    "password",
+			expectedAuth: &http.BasicAuth{
+				Username: "x-access-token",
+				Password: "gh-token",
+			},
+			expectedTokenCalls: 1,
+		},
+		{
+			name:         "case 1: GitHub token not sent over plain HTTP",
+			url:          "http://github.com/giantswarm/config.git",
+			tokenSource:  token.Static("gh-token"),
+			expectedAuth: nil,
+		},
+		{
+			name:         "case 2: GitHub token not sent to other hosts",
+			url:          "https://gitlab.example.com/team/config.git",
+			tokenSource:  token.Static("gh-token"),
+			expectedAuth: nil,
+		},
+		{
+			name:        "case 3: password sent to other hosts",
+			url:         "https://gitlab.example.com/team/config.git",
+			tokenSource: token.Static("gh-token"),
+			username:    "user",
+			password:    "password",
+			expectedAuth: &http.BasicAuth{
+				Username: "user",
+				Password: "password",
+			},
+		},
+		{
+			name:         "case 4: password not sent to github.com",
+			url:          "https://github.com/giantswarm/config.git",
+			username:     "user",
+			password:     "password",
+			expectedAuth: nil,
+		},
+		{
+			name:         "case 5: password not sent over plain HTTP",
+			url:          "http://gitea.example.com/team/config.git",
+			username:     "user",
+			password:     "password",
+			expectedAuth: nil,
+		},
+		{
+			name:         "case 6: SSH uses default authentication",
+			url:          "ssh://git@github.com/giantswarm/config.git",
+			tokenSource:  token.Static("gh-token"),
+			expectedAuth: nil,
+		},
+		{
+			name:         "case 7: GitHub host matched exactly",
+			url:          "https://github.com.example.com/giantswarm/config.git",
+			tokenSource:  token.Static("gh-token"),
+			expectedAuth: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			c := Config{
+				Username: tc.username,
+				Password: tc.password,
+			}
+
+			var tokenSource *countingSource
+			if tc.tokenSource != nil {
+				tokenSource = &countingSource{source: tc.tokenSource}
+				c.TokenSource = tokenSource
+			}
+
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+
+			auth, err := r.auth(context.Background(), tc.url)
+			if err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+
+			if !reflect.DeepEqual(auth, tc.expectedAuth) {
+				t.Fatalf("auth = %#v, want %#v", auth, tc.expectedAuth)
+			}
+			if tokenSource != nil && tokenSource.calls != tc.expectedTokenCalls {
+				t.Fatalf("token calls = %d, want %d", tokenSource.calls, tc.expectedTokenCalls)
+			}
+		})
+	}
+}
+
+// countingSource counts calls of the token source so tests can assert that
+// rotating sources, e.g. GitHub App installation tokens, are not even asked
+// for a token for other hosts.
+type countingSource struct {
+	source token.Source
+	calls  int
+}
+
+func (s *countingSource) Token(ctx context.Context) (string, error) {
+	s.calls++
+	return s.source.Token(ctx)
+}
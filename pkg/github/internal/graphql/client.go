@@ -8,16 +8,22 @@ import (
 	"net/http"
 
 	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/config-controller/pkg/github/internal/token"
 )
 
 type Config struct {
 	Headers map[string]string
-	URL     string
+	// TokenSource is optional. When set, its token is sent in the
+	// Authorization header of every request.
+	TokenSource token.Source
+	URL         string
 }
 
 type Client struct {
-	headers map[string]string
-	url     string
+	headers     map[string]string
+	tokenSource token.Source
+	url         string
 }
 
 func New(config Config) (*Client, error) {
@@ -26,8 +32,9 @@ func New(config Config) (*Client, error) {
 	}
 
 	c := &Client{
-		headers: config.Headers,
-		url:     config.URL,
+		headers:     config.Headers,
+		tokenSource: config.TokenSource,
+		url:         config.URL,
 	}
 
 	return c, nil
@@ -59,6 +66,13 @@ func (c *Client) Do(ctx context.Context, req Request, v interface{}) error {
 	for k, v := range c.headers {
 		httpReq.Header.Add(k, v)
 	}
+	if c.tokenSource != nil {
+		t, err := c.tokenSource.Token(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		httpReq.Header.Set("Authorization", "bearer "+t)
+	}
 
 	httpResp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
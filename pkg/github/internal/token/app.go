@@ -0,0 +1,219 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// DefaultAPIURL is the GitHub REST API URL used when
+	// AppConfig.APIURL is empty.
+	DefaultAPIURL = "https://api.github.com"
+
+	// jwtLifetime is below the maximum of 10 minutes accepted by GitHub.
+	jwtLifetime = 9 * time.Minute
+	// jwtClockSkew backdates JWTs to tolerate clock drift.
+	jwtClockSkew = time.Minute
+	// refreshBefore is how long before expiration installation tokens
+	// are refreshed. They are valid for an hour.
+	refreshBefore = 5 * time.Minute
+)
+
+type AppConfig struct {
+	// APIURL is the GitHub REST API URL. Defaults to DefaultAPIURL.
+	APIURL string
+	// HTTPClient is optional. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// ID is the GitHub App ID.
+	ID int64
+	// InstallationID is the ID of the app installation the tokens are
+	// minted for.
+	InstallationID int64
+	// PrivateKey is the PEM encoded private key of the app.
+	PrivateKey []byte
+}
+
+// App mints installation access tokens of a GitHub App. Tokens are cached
+// and refreshed before they expire.
+type App struct {
+	apiURL     string
+	httpClient *http.Client
+
+	id             int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+
+	mutex     sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewApp(config AppConfig) (*App, error) {
+	if config.APIURL == "" {
+		config.APIURL = DefaultAPIURL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	if config.ID == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ID must not be empty", config)
+	}
+	if config.InstallationID == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.InstallationID must not be empty", config)
+	}
+	if len(config.PrivateKey) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PrivateKey must not be empty", config)
+	}
+
+	privateKey, err := parsePrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	a := &App{
+		apiURL:     strings.TrimSuffix(config.APIURL, "/"),
+		httpClient: config.HTTPClient,
+
+		id:             config.ID,
+		installationID: config.InstallationID,
+		privateKey:     privateKey,
+	}
+
+	return a, nil
+}
+
+// Token returns a cached installation access token or mints a new one when
+// the cached token is about to expire.
+func (a *App) Token(ctx context.Context) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.token != "" && time.Until(a.expiresAt) > refreshBefore {
+		return a.token, nil
+	}
+
+	token, expiresAt, err := a.createInstallationToken(ctx)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	a.token = token
+	a.expiresAt = expiresAt
+
+	return token, nil
+}
+
+func (a *App) createInstallationToken(ctx context.Context) (string, time.Time, error) {
+	jwt, err := a.signJWT(time.Now())
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.apiURL, a.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		bytes, _ := ioutil.ReadAll(resp.Body)
+		return "", time.Time{}, microerror.Maskf(executionFailedError, "expected status code = %d but got %d, response body = %#q", http.StatusCreated, resp.StatusCode, bytes)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", time.Time{}, microerror.Mask(err)
+	}
+	if body.Token == "" {
+		return "", time.Time{}, microerror.Maskf(executionFailedError, "installation access token is empty")
+	}
+
+	return body.Token, body.ExpiresAt, nil
+}
+
+// signJWT returns a JWT authenticating as the app, signed with RS256 as
+// required by GitHub.
+func (a *App) signJWT(now time.Time) (string, error) {
+	header := map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	}
+	claims := map[string]interface{}{
+		"iat": now.Add(-jwtClockSkew).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(a.id, 10),
+	}
+
+	var parts []string
+	for _, v := range []interface{}{header, claims} {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(bs))
+	}
+
+	signingInput := strings.Join(parts, ".")
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey parses PKCS #1 keys generated by GitHub and PKCS #8 keys.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, microerror.Maskf(invalidConfigError, "private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "private key is neither PKCS #1 nor PKCS #8 RSA key")
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, microerror.Maskf(invalidConfigError, "private key is %T, want RSA key", key)
+	}
+
+	return rsaKey, nil
+}
@@ -0,0 +1,187 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApp_Token(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		privateKey    []byte
+		expiresIn     time.Duration
+		statusCode    int
+		expectedMints int
+		errorMatcher  func(error) bool
+	}{
+		{
+			name:          "case 0: PKCS #1 key, token cached",
+			privateKey:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}),
+			expiresIn:     time.Hour,
+			statusCode:    http.StatusCreated,
+			expectedMints: 1,
+		},
+		{
+			name:          "case 1: PKCS #8 key, token cached",
+			privateKey:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+			expiresIn:     time.Hour,
+			statusCode:    http.StatusCreated,
+			expectedMints: 1,
+		},
+		{
+			name:          "case 2: token about to expire refreshed",
+			privateKey:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+			expiresIn:     time.Minute,
+			statusCode:    http.StatusCreated,
+			expectedMints: 2,
+		},
+		{
+			name:         "case 3: API error",
+			privateKey:   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+			statusCode:   http.StatusUnauthorized,
+			errorMatcher: func(err error) bool { return err != nil },
+		},
+		{
+			name:         "case 4: invalid key",
+			privateKey:   []byte("not a key"),
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var mints int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/app/installations/456/access_tokens" {
+					http.NotFound(w, r)
+					return
+				}
+				err := verifyJWT(&privateKey.PublicKey, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+
+				mints++
+				w.WriteHeader(tc.statusCode)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"token":      fmt.Sprintf("token-%d", mints),
+					"expires_at": time.Now().Add(tc.expiresIn),
+				})
+			}))
+			defer server.Close()
+
+			var app *App
+			{
+				c := AppConfig{
+					APIURL:     server.URL,
+					HTTPClient: server.Client(),
+
+					ID:             123,
+					InstallationID: 456,
+					PrivateKey:     tc.privateKey,
+				}
+
+				app, err = NewApp(c)
+			}
+
+			var token string
+			if err == nil {
+				for j := 0; j < 2; j++ {
+					token, err = app.Token(context.Background())
+					if err != nil {
+						break
+					}
+				}
+			}
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if mints != tc.expectedMints {
+				t.Fatalf("mints = %d, want %d", mints, tc.expectedMints)
+			}
+			expectedToken := fmt.Sprintf("token-%d", tc.expectedMints)
+			if token != expectedToken {
+				t.Fatalf("token = %#q, want %#q", token, expectedToken)
+			}
+		})
+	}
+}
+
+func verifyJWT(publicKey *rsa.PublicKey, jwt string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("JWT has %d parts, want 3", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return err
+	}
+	if claims.Iss != "123" {
+		return fmt.Errorf("iss = %#q, want %#q", claims.Iss, "123")
+	}
+	now := time.Now().Unix()
+	if claims.Iat > now || claims.Exp <= now || claims.Exp-claims.Iat > 600 {
+		return fmt.Errorf("invalid iat = %d, exp = %d", claims.Iat, claims.Exp)
+	}
+
+	return nil
+}
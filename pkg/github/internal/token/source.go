@@ -0,0 +1,18 @@
+// Package token provides credentials shared by GitHub GraphQL API calls and
+// git operations.
+package token
+
+import "context"
+
+// Source returns a token valid at the time of the call. Implementations
+// must be safe for concurrent use.
+type Source interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Static always returns the same token, e.g. a personal access token.
+type Static string
+
+func (s Static) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
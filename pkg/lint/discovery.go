@@ -2,15 +2,21 @@ package lint
 
 import (
 	"fmt"
+	"os"
 	"sort"
 
 	"github.com/giantswarm/microerror"
 
 	"github.com/giantswarm/config-controller/pkg/generator"
-	"github.com/giantswarm/config-controller/pkg/github"
 )
 
 type discovery struct {
+	fs generator.Filesystem
+
+	// MinKeyVersion is the minimum Vault transit key version secret values
+	// must be encrypted with. Zero disables the check.
+	MinKeyVersion int
+
 	Config        *configFile
 	ConfigPatches []*configFile
 	Secrets       []*configFile
@@ -31,36 +37,75 @@ type discovery struct {
 	SecretTemplatesPerApp                map[string]*templateFile
 	TemplatePatchesPerInstallation       map[string][]*templateFile
 	SecretTemplatePatchesPerInstallation map[string][]*templateFile
+	SecretPolicyPerInstallation          map[string]generator.SecretPolicy
+
+	// SchemasPerApp contains values.schema.json filepaths of apps which
+	// have one.
+	SchemasPerApp map[string]string
+
+	// LayersPerInstallation contains intermediate layer directories
+	// declared by installations in the order they are applied.
+	LayersPerInstallation         map[string][]string
+	ConfigPatchesPerLayer         map[string]*configFile
+	TemplatePatchesPerLayer       map[string][]*templateFile
+	SecretTemplatePatchesPerLayer map[string][]*templateFile
 }
 
-func (d discovery) getAppTemplatePatch(installation, app string) (*templateFile, bool) {
-	templatePatches, ok := d.TemplatePatchesPerInstallation[installation]
-	if !ok {
-		return nil, false
+// getConfigPatches returns config.yaml.patch files applied for the
+// installation ordered by priority, i.e. the installation patch first and
+// then intermediate layer patches in reverse order.
+func (d discovery) getConfigPatches(installation string) []*configFile {
+	var patches []*configFile
+	if patch, ok := d.ConfigPatchesPerInstallation[installation]; ok {
+		patches = append(patches, patch)
 	}
-	for _, patch := range templatePatches {
-		if patch.app == app {
-			return patch, true
+
+	layers := d.LayersPerInstallation[installation]
+	for i := len(layers) - 1; i >= 0; i-- {
+		if patch, ok := d.ConfigPatchesPerLayer[layers[i]]; ok {
+			patches = append(patches, patch)
 		}
 	}
-	return nil, false
+
+	return patches
 }
 
-func (d discovery) getAppSecretTemplatePatch(installation, app string) (*templateFile, bool) {
-	templatePatches, ok := d.SecretTemplatePatchesPerInstallation[installation]
-	if !ok {
-		return nil, false
-	}
-	for _, patch := range templatePatches {
+// getAppTemplatePatches returns configmap-values.yaml.patch files applied for
+// the installation and app ordered by priority.
+func (d discovery) getAppTemplatePatches(installation, app string) []*templateFile {
+	return d.getAppPatches(installation, app, d.TemplatePatchesPerInstallation, d.TemplatePatchesPerLayer)
+}
+
+// getAppSecretTemplatePatches returns secret-values.yaml.patch files applied
+// for the installation and app ordered by priority.
+func (d discovery) getAppSecretTemplatePatches(installation, app string) []*templateFile {
+	return d.getAppPatches(installation, app, d.SecretTemplatePatchesPerInstallation, d.SecretTemplatePatchesPerLayer)
+}
+
+func (d discovery) getAppPatches(installation, app string, perInstallation, perLayer map[string][]*templateFile) []*templateFile {
+	var patches []*templateFile
+	for _, patch := range perInstallation[installation] {
 		if patch.app == app {
-			return patch, true
+			patches = append(patches, patch)
 		}
 	}
-	return nil, false
+
+	layers := d.LayersPerInstallation[installation]
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, patch := range perLayer[layers[i]] {
+			if patch.app == app {
+				patches = append(patches, patch)
+			}
+		}
+	}
+
+	return patches
 }
 
 func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 	d := &discovery{
+		fs: fs,
+
 		ConfigPatches: []*configFile{},
 		Secrets:       []*configFile{},
 
@@ -79,6 +124,14 @@ func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 		SecretTemplatesPerApp:                map[string]*templateFile{},
 		TemplatePatchesPerInstallation:       map[string][]*templateFile{},
 		SecretTemplatePatchesPerInstallation: map[string][]*templateFile{},
+		SecretPolicyPerInstallation:          map[string]generator.SecretPolicy{},
+
+		SchemasPerApp: map[string]string{},
+
+		LayersPerInstallation:         map[string][]string{},
+		ConfigPatchesPerLayer:         map[string]*configFile{},
+		TemplatePatchesPerLayer:       map[string][]*templateFile{},
+		SecretTemplatePatchesPerLayer: map[string][]*templateFile{},
 	}
 
 	// collect config.yaml
@@ -131,6 +184,12 @@ func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 		}
 		d.Secrets = append(d.Secrets, secret)
 		d.SecretsPerInstallation[inst.Name()] = secret
+
+		policy, err := generator.InstallationSecretPolicy(fs, inst.Name())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		d.SecretPolicyPerInstallation[inst.Name()] = policy
 	}
 
 	// collect default/apps/*/{configmap,secret}-values.yaml.template files
@@ -166,6 +225,16 @@ func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 		}
 		d.SecretTemplates = append(d.SecretTemplates, secret)
 		d.SecretTemplatesPerApp[app.Name()] = secret
+
+		filepath = generator.SchemaFilepath(app.Name())
+		_, err = fs.ReadFile(filepath)
+		if err == nil {
+			d.SchemasPerApp[app.Name()] = filepath
+		} else if generator.IsNotFound(err) {
+			// fallthrough
+		} else {
+			return nil, microerror.Mask(err)
+		}
 	}
 
 	// collect installations/*/apps/*/{configmap,secret}-values.yaml.patch files
@@ -174,8 +243,6 @@ func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 			continue
 		}
 		d.AppsPerInstallation[inst.Name()] = []string{}
-		d.TemplatePatchesPerInstallation[inst.Name()] = []*templateFile{}
-		d.SecretTemplatePatchesPerInstallation[inst.Name()] = []*templateFile{}
 		appDirs, err := fs.ReadDir("default/apps/")
 		if err != nil {
 			return nil, microerror.Mask(err)
@@ -186,42 +253,59 @@ func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 			}
 			uniqueApps[app.Name()] = true
 			d.AppsPerInstallation[inst.Name()] = append(d.AppsPerInstallation[inst.Name()], app.Name())
+		}
+
+		templatePatches, secretPatches, err := d.collectAppPatches(fs, "installations/"+inst.Name(), appDirs)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		d.TemplatePatchesPerInstallation[inst.Name()] = templatePatches
+		d.SecretTemplatePatchesPerInstallation[inst.Name()] = secretPatches
+	}
+
+	// collect config.yaml.patch and apps/*/{configmap,secret}-values.yaml.patch
+	// files of intermediate layers declared by installations
+	for _, inst := range installationDirs {
+		if !inst.IsDir() {
+			continue
+		}
+		layers, err := generator.InstallationLayers(fs, inst.Name())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		d.LayersPerInstallation[inst.Name()] = layers
+
+		for _, layer := range layers {
+			if _, ok := d.TemplatePatchesPerLayer[layer]; ok {
+				continue // already collected
+			}
 
-			filepath := fmt.Sprintf("installations/%s/apps/%s/configmap-values.yaml.patch", inst.Name(), app.Name())
+			filepath := fmt.Sprintf("%s/config.yaml.patch", layer)
 			body, err := fs.ReadFile(filepath)
 			if err == nil {
-				templatePatch, err := newTemplateFile(filepath, body)
+				patch, err := newConfigFile(filepath, body)
 				if err != nil {
 					return nil, microerror.Mask(err)
 				}
-				d.TemplatePatches = append(d.TemplatePatches, templatePatch)
-				d.TemplatePatchesPerInstallation[inst.Name()] = append(
-					d.TemplatePatchesPerInstallation[inst.Name()],
-					templatePatch,
-				)
-			} else if github.IsNotFound(err) {
+				patch.layer = layer
+				d.ConfigPatches = append(d.ConfigPatches, patch)
+				d.ConfigPatchesPerLayer[layer] = patch
+			} else if generator.IsNotFound(err) {
 				// fallthrough
 			} else {
 				return nil, microerror.Mask(err)
 			}
 
-			filepath = fmt.Sprintf("installations/%s/apps/%s/secret-values.yaml.patch", inst.Name(), app.Name())
-			body, err = fs.ReadFile(filepath)
-			if err == nil {
-				secretPatch, err := newTemplateFile(filepath, body)
-				if err != nil {
-					return nil, microerror.Mask(err)
-				}
-				d.SecretTemplatePatches = append(d.SecretTemplatePatches, secretPatch)
-				d.SecretTemplatePatchesPerInstallation[inst.Name()] = append(
-					d.SecretTemplatePatchesPerInstallation[inst.Name()],
-					secretPatch,
-				)
-			} else if github.IsNotFound(err) {
-				// fallthrough
-			} else {
+			appDirs, err := fs.ReadDir("default/apps/")
+			if err != nil {
 				return nil, microerror.Mask(err)
 			}
+			templatePatches, secretPatches, err := d.collectAppPatches(fs, layer, appDirs)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			d.TemplatePatchesPerLayer[layer] = templatePatches
+			d.SecretTemplatePatchesPerLayer[layer] = secretPatches
 		}
 	}
 
@@ -262,35 +346,85 @@ func newDiscovery(fs generator.Filesystem) (*discovery, error) {
 	return d, nil
 }
 
+// collectAppPatches reads {configmap,secret}-values.yaml.patch files of all
+// apps in the given directory. Patches are also appended to d.TemplatePatches
+// and d.SecretTemplatePatches.
+func (d *discovery) collectAppPatches(fs generator.Filesystem, dir string, appDirs []os.FileInfo) (templatePatches, secretPatches []*templateFile, err error) {
+	templatePatches = []*templateFile{}
+	secretPatches = []*templateFile{}
+
+	for _, app := range appDirs {
+		if !app.IsDir() {
+			continue
+		}
+
+		filepath := fmt.Sprintf("%s/apps/%s/configmap-values.yaml.patch", dir, app.Name())
+		body, err := fs.ReadFile(filepath)
+		if err == nil {
+			templatePatch, err := newTemplateFile(filepath, body)
+			if err != nil {
+				return nil, nil, microerror.Mask(err)
+			}
+			d.TemplatePatches = append(d.TemplatePatches, templatePatch)
+			templatePatches = append(templatePatches, templatePatch)
+		} else if generator.IsNotFound(err) {
+			// fallthrough
+		} else {
+			return nil, nil, microerror.Mask(err)
+		}
+
+		filepath = fmt.Sprintf("%s/apps/%s/secret-values.yaml.patch", dir, app.Name())
+		body, err = fs.ReadFile(filepath)
+		if err == nil {
+			secretPatch, err := newTemplateFile(filepath, body)
+			if err != nil {
+				return nil, nil, microerror.Mask(err)
+			}
+			d.SecretTemplatePatches = append(d.SecretTemplatePatches, secretPatch)
+			secretPatches = append(secretPatches, secretPatch)
+		} else if generator.IsNotFound(err) {
+			// fallthrough
+		} else {
+			return nil, nil, microerror.Mask(err)
+		}
+	}
+
+	return templatePatches, secretPatches, nil
+}
+
 // populateconfigValues fills UsedBy and overshadowedBy fields in all configValue
 // structs in d.Config and d.ConfigPatches. This allows linter to find unused
 // values easier.
 func (d *discovery) populateconfigValues() error {
 	// 1. Mark all overshadowed configValues in config.yaml
-	for _, configPatch := range d.ConfigPatches {
-		for path := range configPatch.paths {
-			if original, ok := d.Config.paths[path]; ok {
-				original.overshadowedBy = append(original.overshadowedBy, configPatch)
+	for _, installation := range d.Installations {
+		for path, original := range d.Config.paths {
+			overshadowed := false
+			for _, configPatch := range d.getConfigPatches(installation) {
+				if _, ok := configPatch.paths[path]; ok {
+					original.overshadowedBy = appendUniqueConfigFile(original.overshadowedBy, configPatch)
+					overshadowed = true
+				}
+			}
+			if overshadowed {
+				original.overshadowedInstallations = append(original.overshadowedInstallations, installation)
 			}
 		}
 	}
 	// 2. Check templates for all apps x installations, then set UsedBy fields
 	// in Config, ConfigPatches
 	for _, installation := range d.Installations {
-		configPatch, ok := d.ConfigPatchesPerInstallation[installation]
-		if !ok {
-			configPatch = nil
-		}
+		configPatches := d.getConfigPatches(installation)
 
 		for _, app := range d.Apps {
-			// mark all fields used by app template's patch
-			if templatePatch, ok := d.getAppTemplatePatch(installation, app); ok {
-				populatePathsWithUsedBy(templatePatch, d.Config, configPatch)
+			// mark all fields used by app template's patches
+			for _, templatePatch := range d.getAppTemplatePatches(installation, app) {
+				populatePathsWithUsedBy(templatePatch, d.Config, configPatches)
 			}
 
 			// mark all fields used by the app's default template
 			if defaultTemplate, ok := d.TemplatesPerApp[app]; ok {
-				populatePathsWithUsedBy(defaultTemplate, d.Config, configPatch)
+				populatePathsWithUsedBy(defaultTemplate, d.Config, configPatches)
 			}
 		}
 	}
@@ -303,25 +437,38 @@ func (d *discovery) populateconfigValues() error {
 		}
 		for _, app := range d.Apps {
 			defaultTemplate := d.SecretTemplatesPerApp[app]
-			templatePatch, _ := d.getAppSecretTemplatePatch(installation, app)
+			templatePatches := d.getAppSecretTemplatePatches(installation, app)
 
-			populateSecretPathsWithUsedBy(secret, defaultTemplate, templatePatch)
+			populateSecretPathsWithUsedBy(secret, defaultTemplate, templatePatches)
 		}
 	}
 
 	return nil
 }
 
-func populatePathsWithUsedBy(source *templateFile, config, configPatch *configFile) {
+// populatePathsWithUsedBy marks values used by the source template. Config
+// patches are expected to be ordered by priority.
+func populatePathsWithUsedBy(source *templateFile, config *configFile, configPatches []*configFile) {
 	for path, templatePath := range source.values {
-		if configPatch != nil {
+		found := false
+		for _, configPatch := range configPatches {
 			configValue, configValueOk := configPatch.paths[path]
 			if configValueOk {
 				// config patch exists and contains the path
 				configValue.usedBy = appendUniqueUsedBy(configValue.usedBy, source)
-				continue
+				found = true
+				break
+			}
+			if configPatch.deletes(path) {
+				// value is removed from config by the patch; values
+				// below in the hierarchy are not used
+				found = true
+				break
 			}
 		}
+		if found {
+			continue
+		}
 
 		configValue, configValueOk := config.paths[path]
 		if configValueOk {
@@ -335,8 +482,8 @@ func populatePathsWithUsedBy(source *templateFile, config, configPatch *configFi
 	}
 }
 
-func populateSecretPathsWithUsedBy(installationSecret *configFile, defaultTemplate, templatePatch *templateFile) {
-	if templatePatch != nil {
+func populateSecretPathsWithUsedBy(installationSecret *configFile, defaultTemplate *templateFile, templatePatches []*templateFile) {
+	for _, templatePatch := range templatePatches {
 		for path, value := range templatePatch.values {
 			configValue, configValueOk := installationSecret.paths[path]
 			if configValueOk {
@@ -347,9 +494,16 @@ func populateSecretPathsWithUsedBy(installationSecret *configFile, defaultTempla
 		}
 	}
 
-	if defaultTemplate != nil && templatePatch != nil {
+	if defaultTemplate != nil && len(templatePatches) > 0 {
 		for path, value := range defaultTemplate.values {
-			if _, ok := templatePatch.paths[path]; ok {
+			overridden := false
+			for _, templatePatch := range templatePatches {
+				if _, ok := templatePatch.paths[path]; ok {
+					overridden = true
+					break
+				}
+			}
+			if overridden {
 				// already checked; value is overriden by patch in this case
 				continue
 			}
@@ -372,3 +526,12 @@ func appendUniqueUsedBy(list []*templateFile, t *templateFile) []*templateFile {
 	}
 	return append(list, t)
 }
+
+func appendUniqueConfigFile(list []*configFile, c *configFile) []*configFile {
+	for _, v := range list {
+		if v == c {
+			return list
+		}
+	}
+	return append(list, c)
+}
@@ -14,6 +14,8 @@ import (
 
 	"github.com/giantswarm/microerror"
 	pathmodifier "github.com/giantswarm/valuemodifier/path"
+
+	"github.com/giantswarm/config-controller/pkg/generator"
 )
 
 var (
@@ -30,16 +32,38 @@ func init() {
 type configFile struct {
 	filepath     string
 	installation string // optional
+	layer        string // optional, set for intermediate layer patches
 	paths        map[string]*configValue
+	// deletedPaths contains paths removed by deletion directives
+	deletedPaths map[string]bool
 	pathmodifier *pathmodifier.Service
 }
 
+// deletes returns true if the path or any of its parents is removed by
+// a deletion directive in the file.
+func (c *configFile) deletes(path string) bool {
+	for p := path; p != ""; {
+		if c.deletedPaths[p] {
+			return true
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return false
+}
+
 type configValue struct {
 	value interface{}
 	// files using this value
 	usedBy []*templateFile
 	// value is overshadowed by some files
 	overshadowedBy []*configFile
+	// installations for which the value is overshadowed by any of their
+	// config patches
+	overshadowedInstallations []string
 }
 
 // templateFile contains a representation of values and paths in a template.
@@ -84,6 +108,7 @@ func newConfigFile(filepath string, body []byte) (*configFile, error) {
 	// extract paths with valuemodifier path service
 	var pathmodifierSvc *pathmodifier.Service
 	allPaths := map[string]*configValue{}
+	deletedPaths := map[string]bool{}
 	{
 		c := pathmodifier.Config{
 			InputBytes: body,
@@ -101,10 +126,28 @@ func newConfigFile(filepath string, body []byte) (*configFile, error) {
 		}
 
 		for _, path := range paths {
+			if generator.IsDirectivePath(path) {
+				continue
+			}
+
 			value, err := svc.Get(path)
 			if err != nil {
 				return nil, microerror.Maskf(executionFailedError, "error getting %q value for %q: %s", filepath, path, err)
 			}
+			if generator.IsDeleteDirective(value) {
+				deletedPaths[NormalPath(path)] = true
+				continue
+			}
+			// secretRef objects of installation secrets are resolved
+			// to a single value. Its value is unknown, so it is not
+			// checked for encryption.
+			if refPath, ok := generator.SecretRefPath(path); ok && strings.HasSuffix(filepath, "/secret.yaml") {
+				allPaths[NormalPath(refPath)] = &configValue{
+					usedBy:         []*templateFile{},
+					overshadowedBy: []*configFile{},
+				}
+				continue
+			}
 
 			v := configValue{
 				value:          value,
@@ -118,6 +161,7 @@ func newConfigFile(filepath string, body []byte) (*configFile, error) {
 	vf := &configFile{
 		filepath:     filepath,
 		paths:        allPaths,
+		deletedPaths: deletedPaths,
 		pathmodifier: pathmodifierSvc,
 	}
 
@@ -230,6 +274,16 @@ func newTemplateFile(filepath string, body []byte) (*templateFile, error) {
 		}
 
 		for _, p := range pathList {
+			if generator.IsDirectivePath(p) {
+				continue
+			}
+			v, err := svc.Get(p)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			if generator.IsDeleteDirective(v) {
+				continue
+			}
 			paths[p] = true
 		}
 	}
@@ -241,9 +295,12 @@ func newTemplateFile(filepath string, body []byte) (*templateFile, error) {
 		elements := strings.Split(filepath, "/")
 		if strings.HasPrefix(filepath, "installations") {
 			tf.installation = elements[1]
-			tf.app = elements[3]
-		} else if strings.HasPrefix(filepath, "default") {
-			tf.app = elements[2]
+		}
+		for i := 0; i < len(elements)-2; i++ {
+			if elements[i] == "apps" {
+				tf.app = elements[i+1]
+				break
+			}
 		}
 		// else it's an include file and has neither app nor installation
 	}
@@ -293,5 +350,9 @@ func dummyFuncMap() template.FuncMap {
 			return ""
 		}
 	}
+	// generator functions
+	dummy["vaultKV"] = func(path, key interface{}) string {
+		return ""
+	}
 	return dummy
 }
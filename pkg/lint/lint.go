@@ -11,6 +11,7 @@ import (
 
 	"github.com/giantswarm/microerror"
 
+	"github.com/giantswarm/config-controller/pkg/decrypt"
 	"github.com/giantswarm/config-controller/pkg/generator"
 )
 
@@ -18,6 +19,10 @@ const (
 	overshadowErrorThreshold float64 = 0.75
 )
 
+var (
+	yamlKeyPattern = regexp.MustCompile(`^\s*(?:-\s+)?([^\s:#{]+):`)
+)
+
 type linterFunc func(d *discovery) (messages LinterMessages)
 
 var allLinterFunctions = []linterFunc{
@@ -31,7 +36,9 @@ var allLinterFunctions = []linterFunc{
 	lintUndefinedSecrettemplateValues,
 	lintUndefinedSecretTemplatePatchValues,
 	lintUnencryptedSecretValues,
+	lintSecretKeyVersions,
 	lintIncludeFiles,
+	lintValuesSchema,
 }
 
 type Config struct {
@@ -40,6 +47,9 @@ type Config struct {
 	OnlyErrors       bool
 	MaxMessages      int
 	SkipFieldsRegexp []string
+	// MinKeyVersion is the minimum Vault transit key version secret values
+	// must be encrypted with. Zero disables the check.
+	MinKeyVersion int
 }
 
 type Linter struct {
@@ -55,6 +65,7 @@ func New(c Config) (*Linter, error) {
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
+	discovery.MinKeyVersion = c.MinKeyVersion
 
 	var skipREs []*regexp.Regexp
 	{
@@ -127,15 +138,15 @@ func lintovershadowedconfigValues(d *discovery) (messages LinterMessages) {
 		return // avoid division by 0
 	}
 	for path, configValue := range d.Config.paths {
-		if len(configValue.overshadowedBy) == len(d.Installations) {
+		if len(configValue.overshadowedInstallations) == len(d.Installations) {
 			messages = append(
 				messages,
 				newError(d.Config.filepath, path, "is overshadowed by all config.yaml.patch files"),
 			)
-		} else if float64(len(configValue.overshadowedBy)/len(d.Installations)) >= overshadowErrorThreshold {
+		} else if float64(len(configValue.overshadowedInstallations)/len(d.Installations)) >= overshadowErrorThreshold {
 			msg := newMessage(
 				d.Config.filepath, path, "is overshadowed by %d/%d patches",
-				len(configValue.overshadowedBy), len(d.Installations),
+				len(configValue.overshadowedInstallations), len(d.Installations),
 			).WithDescription("consider removing it from %s", d.Config.filepath)
 			messages = append(messages, msg)
 		}
@@ -145,7 +156,10 @@ func lintovershadowedconfigValues(d *discovery) (messages LinterMessages) {
 
 func lintUnusedConfigPatchValues(d *discovery) (messages LinterMessages) {
 	for _, configPatch := range d.ConfigPatches {
-		if len(d.AppsPerInstallation[configPatch.installation]) == 0 {
+		if configPatch.layer != "" && len(d.Apps) == 0 {
+			continue // nothing can use the value
+		}
+		if configPatch.layer == "" && len(d.AppsPerInstallation[configPatch.installation]) == 0 {
 			continue // avoid division by 0
 		}
 		for path, configValue := range configPatch.paths {
@@ -282,21 +296,83 @@ func lintUnencryptedSecretValues(d *discovery) (messages LinterMessages) {
 	if len(d.Installations) == 0 {
 		return // what's the point, nothing is defined
 	}
+	for _, secretFile := range d.Secrets {
+		if d.SecretPolicyPerInstallation[secretFile.installation] == generator.SecretPolicyPermissive {
+			continue // plaintext values are allowed
+		}
+		for path, configValue := range secretFile.paths {
+			if configValue.value == nil {
+				continue
+			}
+			stringValue, ok := (configValue.value).(string)
+			if ok && (decrypt.IsVaultCiphertext(stringValue) || decrypt.IsAgeCiphertext(stringValue)) {
+				continue
+			}
+			messages = append(
+				messages,
+				newError(secretFile.filepath, path, "is not encrypted").
+					WithDescription("valid secret values are encrypted with installation Vault's token and start with \"vault:v<key version>:\" prefix, use \"config-controller encrypt\" to encrypt them or set \"secretPolicy: permissive\" in installations/%s/metadata.yaml to allow plaintext values", secretFile.installation),
+			)
+		}
+	}
+	return messages
+}
+
+func lintSecretKeyVersions(d *discovery) (messages LinterMessages) {
+	if d.MinKeyVersion <= 0 {
+		return // check is disabled
+	}
+
+	description := fmt.Sprintf("use \"config-controller rewrap\" to rewrap ciphertexts with the latest key version, which must be at least %d", d.MinKeyVersion)
+
 	for _, secretFile := range d.Secrets {
 		for path, configValue := range secretFile.paths {
 			stringValue, ok := (configValue.value).(string)
 			if !ok {
 				continue
 			}
-			if !strings.HasPrefix(stringValue, "vault:v1:") {
+			version, ok := decrypt.CiphertextKeyVersion(stringValue)
+			if !ok || version >= d.MinKeyVersion {
+				continue
+			}
+			messages = append(
+				messages,
+				newError(secretFile.filepath, path, "is encrypted with key version %d lower than minimum %d", version, d.MinKeyVersion).
+					WithDescription(description),
+			)
+		}
+	}
+
+	// Secret template patches are templates and may not be valid YAML
+	// before rendering so ciphertexts are looked up line by line. Messages
+	// point to the line and the key defined in it.
+	for _, secretPatch := range d.SecretTemplatePatches {
+		body, err := d.fs.ReadFile(secretPatch.filepath)
+		if err != nil {
+			messages = append(messages, newError(secretPatch.filepath, "", "failed to read file: %s", err))
+			continue
+		}
+
+		for i, line := range strings.Split(string(body), "\n") {
+			var key string
+			if m := yamlKeyPattern.FindStringSubmatch(line); m != nil {
+				key = m[1]
+			}
+
+			for _, ciphertext := range decrypt.FindVaultCiphertexts(line) {
+				version, ok := decrypt.CiphertextKeyVersion(ciphertext)
+				if !ok || version >= d.MinKeyVersion {
+					continue
+				}
 				messages = append(
 					messages,
-					newError(secretFile.filepath, path, "is not encrypted with Vault").
-						WithDescription("valid secret values are encrypted with installation Vault's token and start with \"vault:v1:\" prefix"),
+					newError(fmt.Sprintf("%s:%d", secretPatch.filepath, i+1), key, "is encrypted with key version %d lower than minimum %d", version, d.MinKeyVersion).
+						WithDescription(description),
 				)
 			}
 		}
 	}
+
 	return messages
 }
 
@@ -313,25 +389,35 @@ func lintUndefinedTemplatePatchValues(d *discovery) (messages LinterMessages) {
 }
 
 func lintIncludeFiles(d *discovery) (messages LinterMessages) {
-	used := map[string]bool{}
 	exist := map[string]bool{}
+	graph := map[string][]string{}
 	for _, includeFile := range d.Include {
 		exist[includeFile.filepath] = true
-		for _, filepath := range includeFile.includes {
-			used[filepath] = true
-		}
+		graph[includeFile.filepath] = includeFile.includes
 	}
 
-	for _, template := range d.Templates {
-		for _, filepath := range template.includes {
-			used[filepath] = true
+	// include files used directly by templates and template patches
+	var queue []string
+	for _, templates := range [][]*templateFile{d.Templates, d.TemplatePatches, d.SecretTemplates, d.SecretTemplatePatches} {
+		for _, template := range templates {
+			queue = append(queue, template.includes...)
 		}
 	}
 
-	for _, templatePatch := range d.TemplatePatches {
-		for _, filepath := range templatePatch.includes {
-			used[filepath] = true
+	// follow transitive includes
+	used := map[string]bool{}
+	for len(queue) > 0 {
+		filepath := queue[0]
+		queue = queue[1:]
+		if used[filepath] {
+			continue
 		}
+		used[filepath] = true
+		queue = append(queue, graph[filepath]...)
+	}
+
+	for _, cycle := range findIncludeCycles(graph) {
+		messages = append(messages, newError(cycle[0], "*", "is part of include cycle %s", strings.Join(cycle, " -> ")))
 	}
 
 	if reflect.DeepEqual(exist, used) {
@@ -353,6 +439,91 @@ func lintIncludeFiles(d *discovery) (messages LinterMessages) {
 	return messages
 }
 
+// findIncludeCycles returns include cycles found in the graph of include
+// files. Each cycle is reported once, starting and ending with the same
+// file.
+func findIncludeCycles(graph map[string][]string) (cycles [][]string) {
+	var nodes []string
+	for n := range graph {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+
+	done := map[string]bool{}
+	found := map[string]bool{}
+	var visit func(n string, chain []string)
+	visit = func(n string, chain []string) {
+		for i, c := range chain {
+			if c == n {
+				cycle := append(append([]string{}, chain[i:]...), n)
+				if key := strings.Join(cycle, "\n"); !found[key] {
+					found[key] = true
+					cycles = append(cycles, cycle)
+				}
+				return
+			}
+		}
+		if done[n] {
+			return
+		}
+
+		chain = append(chain, n)
+		for _, next := range graph[n] {
+			visit(next, chain)
+		}
+		done[n] = true
+	}
+
+	for _, n := range nodes {
+		visit(n, nil)
+	}
+
+	return cycles
+}
+
+func lintValuesSchema(d *discovery) (messages LinterMessages) {
+	for _, app := range d.Apps {
+		schemaFilepath, ok := d.SchemasPerApp[app]
+		if !ok {
+			continue
+		}
+
+		for _, installation := range d.Installations {
+			c := generator.Config{
+				Fs:               d.fs,
+				DecryptTraverser: noopTraverser{},
+
+				Installation:      installation,
+				SecretRefResolver: generator.PlaceholderSecretRefResolver{},
+				VaultKVReader:     generator.PlaceholderVaultKVReader{},
+			}
+			g, err := generator.New(c)
+			if err != nil {
+				messages = append(messages, newError(schemaFilepath, "*", "can not be validated for %s: %s", installation, err))
+				continue
+			}
+
+			violations, err := g.Validate(context.Background(), app)
+			if err != nil {
+				messages = append(messages, newError(schemaFilepath, "*", "can not be validated for %s: %s", installation, err))
+				continue
+			}
+
+			for _, v := range violations {
+				// Secret values are not decrypted by linter. Their
+				// ciphertexts and placeholders are checked only
+				// for the structure of the values.
+				if v.Secret && v.ValueDependent {
+					continue
+				}
+				path := strings.TrimPrefix(strings.TrimPrefix(v.Path, "$"), ".")
+				messages = append(messages, newError(schemaFilepath, path, "is invalid for %s: %s", installation, v.Message))
+			}
+		}
+	}
+	return messages
+}
+
 //------ helper funcs -------
 func getFilteredLinterFunctions(filters []string) []linterFunc {
 	if len(filters) == 0 {
@@ -384,3 +555,9 @@ func skipValidation(msg string, matchers []*regexp.Regexp) bool {
 
 	return false
 }
+
+type noopTraverser struct{}
+
+func (t noopTraverser) Traverse(ctx context.Context, data []byte) ([]byte, error) {
+	return data, nil
+}
@@ -0,0 +1,252 @@
+package lint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/giantswarm/config-controller/pkg/localfs"
+)
+
+func Test_linterFunctions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		caseFile string
+		linter   linterFunc
+
+		expectedMessages []string
+	}{
+		{
+			name:     "case 0: layer values overshadowed by installation are unused",
+			caseFile: "testdata/layers.yaml",
+			linter:   lintUnusedConfigPatchValues,
+
+			expectedMessages: []string{
+				"providers/aws/config.yaml.patch: unused: is unused",
+				"regions/eu-west-1/config.yaml.patch: region: is unused",
+			},
+		},
+		{
+			name:     "case 1: default values overshadowed by layers",
+			caseFile: "testdata/layers.yaml",
+			linter:   lintovershadowedconfigValues,
+
+			expectedMessages: []string{
+				"default/config.yaml: provider: is overshadowed by all config.yaml.patch files",
+				"default/config.yaml: region: is overshadowed by all config.yaml.patch files",
+			},
+		},
+		{
+			name:     "case 2: deletion directives are not unused",
+			caseFile: "testdata/delete.yaml",
+			linter:   lintUnusedConfigPatchValues,
+		},
+		{
+			name:     "case 3: deleted values are not overshadowed",
+			caseFile: "testdata/delete.yaml",
+			linter:   lintovershadowedconfigValues,
+		},
+		{
+			name:     "case 4: values deleted or patched in all installations are unused",
+			caseFile: "testdata/delete.yaml",
+			linter:   lintUnusedconfigValues,
+
+			expectedMessages: []string{
+				"default/config.yaml: annotations.team: is used by just one app: operator",
+				"default/config.yaml: debug: is unused",
+			},
+		},
+		{
+			name:     "case 5: values schema violations of encrypted secret values",
+			caseFile: "testdata/schema.yaml",
+			linter:   lintValuesSchema,
+
+			expectedMessages: []string{
+				"default/apps/operator/values.schema.json: database.user: is invalid for puma: user is required",
+				"default/apps/operator/values.schema.json: replicas: is invalid for puma: Invalid type. Expected: integer, given: string",
+				"default/apps/operator/values.schema.json: token: is invalid for puma: Invalid type. Expected: object, given: string",
+			},
+		},
+		{
+			name:     "case 6: transitive, unused nested and missing includes",
+			caseFile: "testdata/includes.yaml",
+			linter:   lintIncludeFiles,
+
+			expectedMessages: []string{
+				"include/missing.yaml.template: *: is included but does not exist",
+				"include/nested.yaml.template: *: is never included",
+				"include/unused.yaml.template: *: is never included",
+			},
+		},
+		{
+			name:     "case 7: include cycle",
+			caseFile: "testdata/include_cycle.yaml",
+			linter:   lintIncludeFiles,
+
+			expectedMessages: []string{
+				"include/a.yaml.template: *: is part of include cycle include/a.yaml.template -> include/b.yaml.template -> include/c.yaml.template -> include/a.yaml.template",
+			},
+		},
+		{
+			name:     "case 8: plaintext secret values allowed by permissive policy",
+			caseFile: "testdata/secret_policy.yaml",
+			linter:   lintUnencryptedSecretValues,
+
+			expectedMessages: []string{
+				"installations/lion/secret.yaml: user: is not encrypted",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			tmpDir, err := ioutil.TempDir("", "config-controller-test")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			defer os.RemoveAll(tmpDir)
+
+			d := newTestDiscovery(t, tmpDir, tc.caseFile)
+
+			messages := messageStrings(tc.linter(d))
+			if !cmp.Equal(messages, tc.expectedMessages) {
+				t.Fatalf("messages not expected, got:\n%s", cmp.Diff(tc.expectedMessages, messages))
+			}
+		})
+	}
+}
+
+func Test_findIncludeCycles(t *testing.T) {
+	testCases := []struct {
+		name  string
+		graph map[string][]string
+
+		expectedCycles [][]string
+	}{
+		{
+			name: "case 0: no cycles",
+			graph: map[string][]string{
+				"a": {"b", "c"},
+				"b": {"c"},
+				"c": {},
+			},
+		},
+		{
+			name: "case 1: self include",
+			graph: map[string][]string{
+				"a": {"a"},
+			},
+			expectedCycles: [][]string{
+				{"a", "a"},
+			},
+		},
+		{
+			name: "case 2: cycle reported once",
+			graph: map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {"a"},
+			},
+			expectedCycles: [][]string{
+				{"a", "b", "c", "a"},
+			},
+		},
+		{
+			name: "case 3: cycles sharing a file",
+			graph: map[string][]string{
+				"a": {"b"},
+				"b": {"a", "c"},
+				"c": {"b"},
+			},
+			expectedCycles: [][]string{
+				{"a", "b", "a"},
+				{"b", "c", "b"},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			cycles := findIncludeCycles(tc.graph)
+			if !cmp.Equal(cycles, tc.expectedCycles) {
+				t.Fatalf("cycles not expected, got:\n%s", cmp.Diff(tc.expectedCycles, cycles))
+			}
+		})
+	}
+}
+
+type testFile struct {
+	Path string `json:"path"`
+	Data string `json:"data"`
+}
+
+// newTestDiscovery writes files of the case file to dir and discovers them.
+// Case files are YAML documents with path and data of every file.
+func newTestDiscovery(t *testing.T, dir, caseFile string) *discovery {
+	for _, p := range []string{"default/apps", "installations", "include"} {
+		err := os.MkdirAll(path.Join(dir, p), 0755)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	rawData, err := ioutil.ReadFile(caseFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	for _, rawYaml := range strings.Split(string(rawData), "\n---\n") {
+		var file testFile
+		err := yaml.Unmarshal([]byte(rawYaml), &file)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		p := path.Join(dir, file.Path)
+		err = os.MkdirAll(path.Dir(p), 0755)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		err = ioutil.WriteFile(p, []byte(file.Data), 0644) // nolint:gosec
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	fs, err := localfs.New(localfs.Config{Path: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	d, err := newDiscovery(fs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+	}
+
+	return d
+}
+
+// messageStrings formats messages as "<file>: <path>: <message>" sorted
+// alphabetically.
+func messageStrings(messages LinterMessages) []string {
+	var s []string
+	for _, m := range messages {
+		s = append(s, fmt.Sprintf("%s: %s: %s", m.sourceFile, m.path, m.message))
+	}
+	sort.Strings(s)
+
+	return s
+}
@@ -0,0 +1,95 @@
+// Package localfs provides a generator.Filesystem implementation backed by a
+// directory on disk, e.g. a working copy of the config repository.
+package localfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+)
+
+type Config struct {
+	// Path is the root directory of the config repository.
+	Path string
+}
+
+type Store struct {
+	path string
+}
+
+func New(config Config) (*Store, error) {
+	if config.Path == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Path must not be empty", config)
+	}
+
+	stat, err := os.Stat(config.Path)
+	if os.IsNotExist(err) {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Path %#q does not exist", config, config.Path)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if !stat.IsDir() {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Path %#q is not a directory", config, config.Path)
+	}
+
+	s := &Store{
+		path: config.Path,
+	}
+
+	return s, nil
+}
+
+// ReadDir is similar to io/ioutil.ReadDir but it returns error matched by
+// IsNotFound if the directory does not exist.
+func (s *Store) ReadDir(dirpath string) ([]os.FileInfo, error) {
+	p := s.join(dirpath)
+
+	stat, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, microerror.Maskf(notFoundError, "file %#q does not exist", dirpath)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if !stat.IsDir() {
+		return nil, microerror.Maskf(executionFailedError, "file %#q is not a directory", dirpath)
+	}
+
+	fs, err := ioutil.ReadDir(p)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return fs, nil
+}
+
+// ReadFile is similar to io/ioutil.ReadFile but it returns error matched by
+// IsNotFound if the file does not exist.
+func (s *Store) ReadFile(filepath string) ([]byte, error) {
+	p := s.join(filepath)
+
+	stat, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, microerror.Maskf(notFoundError, "file %#q does not exist", filepath)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if stat.IsDir() {
+		return nil, microerror.Maskf(executionFailedError, "file %#q is a directory", filepath)
+	}
+
+	bs, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return bs, nil
+}
+
+// join resolves the slash separated path relative to the store root. The path
+// is cleaned as if it was absolute so it can not escape the root directory.
+func (s *Store) join(p string) string {
+	return filepath.Join(s.path, filepath.FromSlash(path.Clean("/"+p)))
+}
@@ -0,0 +1,101 @@
+package localfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_Store_ReadFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = os.MkdirAll(filepath.Join(tmpDir, "default", "apps"), 0755)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "default", "config.yaml"), []byte("key: value\n"), 0644) // nolint:gosec
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	store, err := New(Config{Path: tmpDir})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		expectedData string
+		errorMatcher func(err error) bool
+	}{
+		{
+			name:         "case 0: existing file",
+			path:         "default/config.yaml",
+			expectedData: "key: value\n",
+		},
+		{
+			name:         "case 1: missing file",
+			path:         "installations/puma/config.yaml.patch",
+			errorMatcher: IsNotFound,
+		},
+		{
+			name:         "case 2: directory",
+			path:         "default/apps",
+			errorMatcher: func(err error) bool { return err != nil && !IsNotFound(err) },
+		},
+		{
+			name:         "case 3: path does not escape the root",
+			path:         "../../default/config.yaml",
+			expectedData: "key: value\n",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			data, err := store.ReadFile(tc.path)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if string(data) != tc.expectedData {
+				t.Fatalf("data = %q, want %q", data, tc.expectedData)
+			}
+		})
+	}
+
+	_, err = store.ReadDir("installations")
+	if !IsNotFound(err) {
+		t.Fatalf("error == %#v, want matching IsNotFound", err)
+	}
+
+	files, err := store.ReadDir("default")
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	if len(files) != 2 {
+		t.Fatalf("len(files) = %d, want %d", len(files), 2)
+	}
+}
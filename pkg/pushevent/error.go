@@ -0,0 +1,30 @@
+package pushevent
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidPayloadError = &microerror.Error{
+	Kind: "invalidPayloadError",
+}
+
+// IsInvalidPayload asserts invalidPayloadError.
+func IsInvalidPayload(err error) bool {
+	return microerror.Cause(err) == invalidPayloadError
+}
+
+var invalidSignatureError = &microerror.Error{
+	Kind: "invalidSignatureError",
+}
+
+// IsInvalidSignature asserts invalidSignatureError.
+func IsInvalidSignature(err error) bool {
+	return microerror.Cause(err) == invalidSignatureError
+}
@@ -0,0 +1,191 @@
+package pushevent
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// maxPayloadSize is the maximum size of webhook payloads sent by
+	// GitHub.
+	maxPayloadSize = 25 << 20
+
+	ProviderGitea  = "gitea"
+	ProviderGitHub = "github"
+
+	branchRefPrefix = "refs/heads/"
+	tagRefPrefix    = "refs/tags/"
+)
+
+type Config struct {
+	// Secret is the webhook secret used to verify HMAC signatures of
+	// payloads.
+	Secret string
+}
+
+// Parser verifies and parses GitHub and Gitea webhooks sent for push,
+// create and delete events.
+type Parser struct {
+	secret []byte
+}
+
+func New(config Config) (*Parser, error) {
+	if config.Secret == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Secret must not be empty", config)
+	}
+
+	p := &Parser{
+		secret: []byte(config.Secret),
+	}
+
+	return p, nil
+}
+
+// Event is a change of a branch or a tag of a repository.
+type Event struct {
+	// Provider is the sender of the webhook, either ProviderGitHub or
+	// ProviderGitea.
+	Provider string
+	// Ref is the full name of the changed reference, e.g.
+	// "refs/heads/main" or "refs/tags/v1.2.3".
+	Ref string
+	// RepositoryURLs are the clone and web URLs of the repository.
+	RepositoryURLs []string
+}
+
+// Branch returns the name of the changed branch. ok is false for events of
+// tags.
+func (e Event) Branch() (branch string, ok bool) {
+	if !strings.HasPrefix(e.Ref, branchRefPrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(e.Ref, branchRefPrefix), true
+}
+
+// Tag returns the name of the changed tag. ok is false for events of
+// branches.
+func (e Event) Tag() (tag string, ok bool) {
+	if !strings.HasPrefix(e.Ref, tagRefPrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(e.Ref, tagRefPrefix), true
+}
+
+type payload struct {
+	Ref        string `json:"ref"`
+	RefType    string `json:"ref_type"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+		HTMLURL  string `json:"html_url"`
+		SSHURL   string `json:"ssh_url"`
+	} `json:"repository"`
+}
+
+// Parse verifies the signature of the webhook request and returns the
+// event it was sent for. The returned event is nil for other event types,
+// e.g. "ping". It returns an error matched by IsInvalidSignature when the
+// request is not signed with the configured secret and an error matched by
+// IsInvalidPayload when the request is malformed.
+func (p *Parser) Parse(r *http.Request) (*Event, error) {
+	provider, eventType, signature := requestHeaders(r)
+	if provider == "" {
+		return nil, microerror.Maskf(invalidPayloadError, "request is neither GitHub nor Gitea webhook")
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = p.verify(body, signature)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	switch eventType {
+	case "push", "create", "delete":
+	default:
+		return nil, nil
+	}
+
+	var pl payload
+	err = json.Unmarshal(body, &pl)
+	if err != nil {
+		return nil, microerror.Maskf(invalidPayloadError, "%s", err)
+	}
+
+	// Push events contain full reference names. Create and delete events
+	// contain short names with their type.
+	ref := pl.Ref
+	if eventType != "push" {
+		switch pl.RefType {
+		case "branch":
+			ref = branchRefPrefix + pl.Ref
+		case "tag":
+			ref = tagRefPrefix + pl.Ref
+		default:
+			return nil, microerror.Maskf(invalidPayloadError, "unknown ref_type %#q", pl.RefType)
+		}
+	}
+	if !strings.HasPrefix(ref, branchRefPrefix) && !strings.HasPrefix(ref, tagRefPrefix) {
+		return nil, nil
+	}
+
+	var urls []string
+	for _, u := range []string{pl.Repository.CloneURL, pl.Repository.SSHURL, pl.Repository.HTMLURL} {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	e := &Event{
+		Provider:       provider,
+		Ref:            ref,
+		RepositoryURLs: urls,
+	}
+
+	return e, nil
+}
+
+func (p *Parser) verify(body []byte, signature string) error {
+	if signature == "" {
+		return microerror.Maskf(invalidSignatureError, "signature is missing")
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return microerror.Maskf(invalidSignatureError, "signature is not hex encoded")
+	}
+
+	mac := hmac.New(sha256.New, p.secret)
+	_, _ = mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return microerror.Maskf(invalidSignatureError, "signature does not match")
+	}
+
+	return nil
+}
+
+// requestHeaders returns the provider, the event type and the hex encoded
+// HMAC-SHA256 signature of the webhook request. Gitea also sends GitHub
+// headers so its headers are checked first.
+func requestHeaders(r *http.Request) (provider, eventType, signature string) {
+	if e := r.Header.Get("X-Gitea-Event"); e != "" {
+		return ProviderGitea, e, r.Header.Get("X-Gitea-Signature")
+	}
+	if e := r.Header.Get("X-GitHub-Event"); e != "" {
+		return ProviderGitHub, e, strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	}
+
+	return "", "", ""
+}
@@ -0,0 +1,123 @@
+package pushevent
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+const testSecret = "secret"
+
+func TestParser_Parse(t *testing.T) {
+	const repository = `"repository": {"clone_url": "https://github.com/giantswarm/config.git", "ssh_url": "git@github.com:giantswarm/config.git"}`
+
+	testCases := []struct {
+		name          string
+		headers       map[string]string
+		body          string
+		secret        string
+		expectedEvent *Event
+		errorMatcher  func(error) bool
+	}{
+		{
+			name:    "case 0: GitHub branch push",
+			headers: map[string]string{"X-GitHub-Event": "push"},
+			body:    `{"ref": "refs/heads/main", ` + repository + `}`,
+			expectedEvent: &Event{
+				Provider:       ProviderGitHub,
+				Ref:            "refs/heads/main",
+				RepositoryURLs: []string{"https://github.com/giantswarm/config.git", "git@github.com:giantswarm/config.git"},
+			},
+		},
+		{
+			name:    "case 1: Gitea tag create",
+			headers: map[string]string{"X-Gitea-Event": "create", "X-GitHub-Event": "create"},
+			body:    `{"ref": "v1.2.3", "ref_type": "tag", ` + repository + `}`,
+			expectedEvent: &Event{
+				Provider:       ProviderGitea,
+				Ref:            "refs/tags/v1.2.3",
+				RepositoryURLs: []string{"https://github.com/giantswarm/config.git", "git@github.com:giantswarm/config.git"},
+			},
+		},
+		{
+			name:          "case 2: ping ignored",
+			headers:       map[string]string{"X-GitHub-Event": "ping"},
+			body:          `{"zen": "Keep it logically awesome."}`,
+			expectedEvent: nil,
+		},
+		{
+			name:         "case 3: wrong secret",
+			headers:      map[string]string{"X-GitHub-Event": "push"},
+			body:         `{"ref": "refs/heads/main", ` + repository + `}`,
+			secret:       "other",
+			errorMatcher: IsInvalidSignature,
+		},
+		{
+			name:         "case 4: not a webhook",
+			headers:      map[string]string{},
+			body:         `{}`,
+			errorMatcher: IsInvalidPayload,
+		},
+		{
+			name:         "case 5: malformed payload",
+			headers:      map[string]string{"X-GitHub-Event": "push"},
+			body:         `{"ref": `,
+			errorMatcher: IsInvalidPayload,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			p, err := New(Config{Secret: testSecret})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			secret := tc.secret
+			if secret == "" {
+				secret = testSecret
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tc.body))
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			signature := sign(secret, tc.body)
+			r.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+			r.Header.Set("X-Gitea-Signature", signature)
+
+			event, err := p.Parse(r)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", microerror.Pretty(err, true))
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(event, tc.expectedEvent) {
+				t.Fatalf("event = %#v, want %#v", event, tc.expectedEvent)
+			}
+		})
+	}
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
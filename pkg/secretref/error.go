@@ -0,0 +1,30 @@
+package secretref
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var forbiddenError = &microerror.Error{
+	Kind: "forbiddenError",
+}
+
+// IsForbidden asserts forbiddenError.
+func IsForbidden(err error) bool {
+	return microerror.Cause(err) == forbiddenError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
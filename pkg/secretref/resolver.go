@@ -0,0 +1,83 @@
+package secretref
+
+import (
+	"context"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/config-controller/pkg/generator"
+)
+
+type Config struct {
+	K8sClient kubernetes.Interface
+
+	// Allowed lists Secrets which may be referenced. Entries are either
+	// "<namespace>" allowing all Secrets of the namespace or
+	// "<namespace>/<name>" allowing a single Secret. References to other
+	// Secrets are rejected. When it is empty all references are rejected.
+	Allowed []string
+}
+
+// Resolver reads values referenced by secretRef objects of installation
+// secret.yaml from Kubernetes Secrets.
+type Resolver struct {
+	k8sClient kubernetes.Interface
+
+	allowedNamespaces map[string]bool
+	allowedSecrets    map[string]bool
+}
+
+var _ generator.SecretRefResolver = &Resolver{}
+
+func New(config Config) (*Resolver, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+
+	allowedNamespaces := map[string]bool{}
+	allowedSecrets := map[string]bool{}
+	for _, a := range config.Allowed {
+		parts := strings.Split(a, "/")
+		switch {
+		case len(parts) == 1 && parts[0] != "":
+			allowedNamespaces[a] = true
+		case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+			allowedSecrets[a] = true
+		default:
+			return nil, microerror.Maskf(invalidConfigError, "%T.Allowed entry %#q must be a namespace or namespace/name", config, a)
+		}
+	}
+
+	r := &Resolver{
+		k8sClient: config.K8sClient,
+
+		allowedNamespaces: allowedNamespaces,
+		allowedSecrets:    allowedSecrets,
+	}
+
+	return r, nil
+}
+
+func (r *Resolver) Resolve(ctx context.Context, ref generator.SecretRef) (string, error) {
+	if !r.allowedNamespaces[ref.Namespace] && !r.allowedSecrets[ref.Namespace+"/"+ref.Name] {
+		return "", microerror.Maskf(forbiddenError, "secret %s/%s is not allowed to be referenced", ref.Namespace, ref.Name)
+	}
+
+	secret, err := r.k8sClient.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", microerror.Maskf(notFoundError, "secret %s/%s", ref.Namespace, ref.Name)
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", microerror.Maskf(notFoundError, "key %#q of secret %s/%s", ref.Key, ref.Namespace, ref.Name)
+	}
+
+	return string(value), nil
+}
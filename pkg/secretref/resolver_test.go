@@ -0,0 +1,178 @@
+package secretref
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/giantswarm/config-controller/pkg/generator"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "giantswarm",
+				Name:      "database-credentials",
+			},
+			Data: map[string][]byte{
+				"password": []byte("s3cret"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "kube-system",
+				Name:      "bootstrap-token",
+			},
+			Data: map[string][]byte{
+				"token": []byte("t0ken"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "monitoring",
+				Name:      "grafana-credentials",
+			},
+			Data: map[string][]byte{
+				"password": []byte("gr4fana"),
+			},
+		},
+	)
+
+	c := Config{
+		K8sClient: k8sClient,
+		Allowed:   []string{"giantswarm", "default", "monitoring/grafana-credentials"},
+	}
+
+	r, err := New(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name string
+		ref  generator.SecretRef
+
+		expectedValue string
+		errorMatcher  func(error) bool
+	}{
+		{
+			name: "case 0: existing key",
+			ref:  generator.SecretRef{Namespace: "giantswarm", Name: "database-credentials", Key: "password"},
+
+			expectedValue: "s3cret",
+		},
+		{
+			name: "case 1: missing key",
+			ref:  generator.SecretRef{Namespace: "giantswarm", Name: "database-credentials", Key: "username"},
+
+			errorMatcher: IsNotFound,
+		},
+		{
+			name: "case 2: missing secret",
+			ref:  generator.SecretRef{Namespace: "default", Name: "database-credentials", Key: "password"},
+
+			errorMatcher: IsNotFound,
+		},
+		{
+			name: "case 3: secret of namespace which is not allowed",
+			ref:  generator.SecretRef{Namespace: "kube-system", Name: "bootstrap-token", Key: "token"},
+
+			errorMatcher: IsForbidden,
+		},
+		{
+			name: "case 4: allowed secret",
+			ref:  generator.SecretRef{Namespace: "monitoring", Name: "grafana-credentials", Key: "password"},
+
+			expectedValue: "gr4fana",
+		},
+		{
+			name: "case 5: secret which is not allowed in namespace with allowed secret",
+			ref:  generator.SecretRef{Namespace: "monitoring", Name: "prometheus-credentials", Key: "password"},
+
+			errorMatcher: IsForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Log(tc.name)
+
+			value, err := r.Resolve(context.Background(), tc.ref)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if value != tc.expectedValue {
+				t.Fatalf("value = %q, want %q", value, tc.expectedValue)
+			}
+		})
+	}
+}
+
+func TestNew_allowed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		allowed []string
+
+		errorMatcher func(error) bool
+	}{
+		{
+			name:    "case 0: namespaces and secrets",
+			allowed: []string{"giantswarm", "monitoring/grafana-credentials"},
+		},
+		{
+			name: "case 1: nothing allowed",
+		},
+		{
+			name:         "case 2: empty entry",
+			allowed:      []string{""},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 3: empty secret name",
+			allowed:      []string{"monitoring/"},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 4: too many segments",
+			allowed:      []string{"monitoring/grafana/password"},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Log(tc.name)
+
+			c := Config{
+				K8sClient: fake.NewSimpleClientset(),
+				Allowed:   tc.allowed,
+			}
+
+			_, err := New(c)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+// Package vaultauth authenticates Vault clients and keeps their tokens
+// valid.
+package vaultauth
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	// MethodToken uses a static token. The token is renewed when it is
+	// renewable but it can not be recreated after it expires.
+	MethodToken = "token"
+	// MethodKubernetes logs in with the pod's service account token.
+	MethodKubernetes = "kubernetes"
+	// MethodAppRole logs in with AppRole role ID and secret ID.
+	MethodAppRole = "approle"
+)
+
+// Methods lists supported values of Config.Method.
+var Methods = []string{
+	MethodToken,
+	MethodKubernetes,
+	MethodAppRole,
+}
+
+const (
+	defaultAppRoleMountPath        = "approle"
+	defaultKubernetesMountPath     = "kubernetes"
+	defaultKubernetesJWTFile       = "/var/run/secrets/kubernetes.io/serviceaccount/token" //nolint:gosec
+	defaultWaitTimeout             = 30 * time.Second
+	defaultReauthenticationBackoff = 5 * time.Second
+)
+
+type Config struct {
+	Logger      micrologger.Logger
+	VaultClient *vaultapi.Client
+
+	// Method is one of Methods.
+	Method string
+
+	// Token is required for MethodToken.
+	Token string
+
+	// KubernetesRole is required for MethodKubernetes.
+	KubernetesRole string
+	// KubernetesMountPath defaults to "kubernetes".
+	KubernetesMountPath string
+	// KubernetesJWTFile defaults to the service account token file
+	// mounted in pods.
+	KubernetesJWTFile string
+
+	// AppRoleRoleID and AppRoleSecretID are required for MethodAppRole.
+	AppRoleRoleID   string
+	AppRoleSecretID string
+	// AppRoleMountPath defaults to "approle".
+	AppRoleMountPath string
+
+	// WaitTimeout is the maximum time Wait blocks while re-authentication
+	// is in progress. Defaults to 30s.
+	WaitTimeout time.Duration
+}
+
+// Authenticator sets a token on the Vault client and keeps it valid. Login
+// must be called before Boot.
+type Authenticator struct {
+	logger      micrologger.Logger
+	vaultClient *vaultapi.Client
+
+	method string
+
+	token string
+
+	kubernetesRole      string
+	kubernetesMountPath string
+	kubernetesJWTFile   string
+
+	appRoleRoleID    string
+	appRoleSecretID  string
+	appRoleMountPath string
+
+	waitTimeout time.Duration
+
+	// mutex guards secret and ready.
+	mutex sync.Mutex
+	// secret is the result of the last login.
+	secret *vaultapi.Secret
+	// ready is closed when the client has a valid token. It is replaced
+	// with an open channel while re-authentication is in progress.
+	ready chan struct{}
+}
+
+func New(config Config) (*Authenticator, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	switch config.Method {
+	case MethodToken:
+		if config.Token == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Token must not be empty when %T.Method is %#q", config, config, MethodToken)
+		}
+	case MethodKubernetes:
+		if config.KubernetesRole == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.KubernetesRole must not be empty when %T.Method is %#q", config, config, MethodKubernetes)
+		}
+	case MethodAppRole:
+		if config.AppRoleRoleID == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.AppRoleRoleID must not be empty when %T.Method is %#q", config, config, MethodAppRole)
+		}
+		if config.AppRoleSecretID == "" {
+			return nil, microerror.Maskf(invalidConfigError, "%T.AppRoleSecretID must not be empty when %T.Method is %#q", config, config, MethodAppRole)
+		}
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "%T.Method must be one of %v, got %#q", config, Methods, config.Method)
+	}
+
+	if config.KubernetesMountPath == "" {
+		config.KubernetesMountPath = defaultKubernetesMountPath
+	}
+	if config.KubernetesJWTFile == "" {
+		config.KubernetesJWTFile = defaultKubernetesJWTFile
+	}
+	if config.AppRoleMountPath == "" {
+		config.AppRoleMountPath = defaultAppRoleMountPath
+	}
+	if config.WaitTimeout == 0 {
+		config.WaitTimeout = defaultWaitTimeout
+	}
+
+	a := &Authenticator{
+		logger:      config.Logger,
+		vaultClient: config.VaultClient,
+
+		method: config.Method,
+
+		token: config.Token,
+
+		kubernetesRole:      config.KubernetesRole,
+		kubernetesMountPath: strings.Trim(config.KubernetesMountPath, "/"),
+		kubernetesJWTFile:   config.KubernetesJWTFile,
+
+		appRoleRoleID:    config.AppRoleRoleID,
+		appRoleSecretID:  config.AppRoleSecretID,
+		appRoleMountPath: strings.Trim(config.AppRoleMountPath, "/"),
+
+		waitTimeout: config.WaitTimeout,
+
+		ready: make(chan struct{}),
+	}
+
+	return a, nil
+}
+
+// Login authenticates the Vault client and unblocks Wait.
+func (a *Authenticator) Login(ctx context.Context) error {
+	secret, err := a.login(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.secret = secret
+	select {
+	case <-a.ready:
+	default:
+		close(a.ready)
+	}
+
+	return nil
+}
+
+// Boot renews the token until ctx is done. When the token can not be renewed
+// anymore, e.g. because it reached its max TTL, it re-authenticates. Calls to
+// Wait block while re-authentication is in progress.
+func (a *Authenticator) Boot(ctx context.Context) {
+	for {
+		a.mutex.Lock()
+		secret := a.secret
+		a.mutex.Unlock()
+
+		err := a.watch(ctx, secret)
+		if err != nil {
+			a.logger.Errorf(ctx, err, "failed to renew Vault token")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if a.method == MethodToken {
+			// Static token can not be recreated. Keep using it until it
+			// expires.
+			a.logger.Debugf(ctx, "Vault token can not be renewed anymore and will expire")
+			return
+		}
+
+		a.reauthenticate(ctx)
+	}
+}
+
+// Wait blocks until the Vault client has a valid token. It returns an error
+// matched by IsWaitTimeout when re-authentication takes longer than
+// Config.WaitTimeout.
+func (a *Authenticator) Wait(ctx context.Context) error {
+	a.mutex.Lock()
+	ready := a.ready
+	a.mutex.Unlock()
+
+	timer := time.NewTimer(a.waitTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return microerror.Mask(ctx.Err())
+	case <-timer.C:
+		return microerror.Maskf(waitTimeoutError, "Vault authentication did not finish in %s", a.waitTimeout)
+	}
+}
+
+func (a *Authenticator) reauthenticate(ctx context.Context) {
+	a.mutex.Lock()
+	a.ready = make(chan struct{})
+	a.mutex.Unlock()
+
+	for {
+		a.logger.Debugf(ctx, "re-authenticating to Vault with %#q method", a.method)
+
+		err := a.Login(ctx)
+		if err == nil {
+			a.logger.Debugf(ctx, "re-authenticated to Vault with %#q method", a.method)
+			return
+		}
+		a.logger.Errorf(ctx, err, "failed to re-authenticate to Vault with %#q method", a.method)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(defaultReauthenticationBackoff):
+		}
+	}
+}
+
+// watch renews the token until it can not be renewed anymore or ctx is done.
+func (a *Authenticator) watch(ctx context.Context, secret *vaultapi.Secret) error {
+	if secret == nil || secret.Auth == nil || !secret.Auth.Renewable {
+		// Wait until the token expires.
+		ttl, err := tokenTTL(secret)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if ttl == 0 {
+			// Token never expires.
+			<-ctx.Done()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(ttl * 2 / 3):
+		}
+		return nil
+	}
+
+	watcher, err := a.vaultClient.NewLifetimeWatcher(&vaultapi.LifetimeWatcherInput{
+		Secret: secret,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	go watcher.Start()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.DoneCh():
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			return nil
+		case <-watcher.RenewCh():
+			a.logger.Debugf(ctx, "renewed Vault token")
+		}
+	}
+}
+
+func (a *Authenticator) login(ctx context.Context) (*vaultapi.Secret, error) {
+	switch a.method {
+	case MethodKubernetes:
+		jwt, err := ioutil.ReadFile(a.kubernetesJWTFile)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		data := map[string]interface{}{
+			"role": a.kubernetesRole,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+		secret, err := a.loginWith(ctx, "auth/"+a.kubernetesMountPath+"/login", data)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return secret, nil
+	case MethodAppRole:
+		data := map[string]interface{}{
+			"role_id":   a.appRoleRoleID,
+			"secret_id": a.appRoleSecretID,
+		}
+		secret, err := a.loginWith(ctx, "auth/"+a.appRoleMountPath+"/login", data)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return secret, nil
+	default:
+		a.vaultClient.SetToken(a.token)
+
+		// Turn the token into a secret with auth information so it can
+		// be renewed by the lifetime watcher.
+		secret, err := a.vaultClient.Auth().Token().LookupSelf()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		renewable, err := secret.TokenIsRenewable()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		ttl, err := secret.TokenTTL()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		secret.Auth = &vaultapi.SecretAuth{
+			ClientToken:   a.token,
+			Renewable:     renewable,
+			LeaseDuration: int(ttl.Seconds()),
+		}
+
+		return secret, nil
+	}
+}
+
+func (a *Authenticator) loginWith(ctx context.Context, path string, data map[string]interface{}) (*vaultapi.Secret, error) {
+	secret, err := a.vaultClient.Logical().Write(path, data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, microerror.Maskf(executionFailedError, "Vault login at %#q returned no token", path)
+	}
+
+	a.vaultClient.SetToken(secret.Auth.ClientToken)
+
+	return secret, nil
+}
+
+func tokenTTL(secret *vaultapi.Secret) (time.Duration, error) {
+	if secret == nil {
+		return 0, nil
+	}
+	if secret.Auth != nil {
+		return time.Duration(secret.Auth.LeaseDuration) * time.Second, nil
+	}
+
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return ttl, nil
+}
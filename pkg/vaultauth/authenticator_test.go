@@ -0,0 +1,152 @@
+package vaultauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestAuthenticator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-controller-test")
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	defer os.RemoveAll(dir)
+
+	jwtFile := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(jwtFile, []byte("jwt\n"), 0600)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	testCases := []struct {
+		name   string
+		config Config
+
+		expectedPath string
+		expectedData map[string]interface{}
+	}{
+		{
+			name: "case 0: kubernetes",
+			config: Config{
+				Method:            MethodKubernetes,
+				KubernetesRole:    "config-controller",
+				KubernetesJWTFile: jwtFile,
+			},
+
+			expectedPath: "/v1/auth/kubernetes/login",
+			expectedData: map[string]interface{}{"role": "config-controller", "jwt": "jwt"},
+		},
+		{
+			name: "case 1: approle with custom mount path",
+			config: Config{
+				Method:           MethodAppRole,
+				AppRoleMountPath: "/custom/",
+				AppRoleRoleID:    "role",
+				AppRoleSecretID:  "secret",
+			},
+
+			expectedPath: "/v1/auth/custom/login",
+			expectedData: map[string]interface{}{"role_id": "role", "secret_id": "secret"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var logins int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.URL.Path != tc.expectedPath {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				var data map[string]interface{}
+				err := json.NewDecoder(r.Body).Decode(&data)
+				if err != nil || fmt.Sprint(data) != fmt.Sprint(tc.expectedData) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				mutex.Lock()
+				logins++
+				token := fmt.Sprintf("token-%d", logins)
+				mutex.Unlock()
+
+				// Short non-renewable lease forces re-authentication.
+				resp := map[string]interface{}{
+					"auth": map[string]interface{}{
+						"client_token":   token,
+						"lease_duration": 1,
+						"renewable":      false,
+					},
+				}
+				_ = json.NewEncoder(w).Encode(resp)
+			}))
+			defer server.Close()
+
+			c := vaultapi.DefaultConfig()
+			c.Address = server.URL
+			vaultClient, err := vaultapi.NewClient(c)
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			config := tc.config
+			config.Logger = microloggertest.New()
+			config.VaultClient = vaultClient
+			config.WaitTimeout = 50 * time.Millisecond
+
+			a, err := New(config)
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Wait times out before the first login.
+			err = a.Wait(ctx)
+			if !IsWaitTimeout(err) {
+				t.Fatalf("err = %#v, want wait timeout", err)
+			}
+
+			err = a.Login(ctx)
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+			if tok := vaultClient.Token(); tok != "token-1" {
+				t.Fatalf("token = %q, want %q", tok, "token-1")
+			}
+			err = a.Wait(ctx)
+			if err != nil {
+				t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+			}
+
+			go a.Boot(ctx)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for vaultClient.Token() == "token-1" {
+				if time.Now().After(deadline) {
+					t.Fatalf("token was not renewed by re-authentication")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if tok := vaultClient.Token(); tok != "token-2" {
+				t.Fatalf("token = %q, want %q", tok, "token-2")
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package vaultauth
+
+import "github.com/giantswarm/microerror"
+
+// executionFailedError should never be matched against and therefore there is
+// no matcher implement. For further information see:
+//
+//	https://github.com/giantswarm/fmt/blob/master/go/errors.md#matching-errors
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var waitTimeoutError = &microerror.Error{
+	Kind: "waitTimeoutError",
+}
+
+// IsWaitTimeout asserts waitTimeoutError.
+func IsWaitTimeout(err error) bool {
+	return microerror.Cause(err) == waitTimeoutError
+}
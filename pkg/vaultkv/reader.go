@@ -0,0 +1,114 @@
+package vaultkv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+)
+
+type Config struct {
+	// AuthWaiter is optional. When set, requests wait until it reports
+	// a valid token.
+	AuthWaiter  decrypt.VaultAuthWaiter
+	VaultClient *vaultapi.Client
+}
+
+// Reader reads secrets from Vault KV secrets engine. Both versions of the
+// engine are supported. Paths of KV version 2 secrets must contain the
+// "data/" segment, e.g. "secret/data/my-app".
+type Reader struct {
+	authWaiter  decrypt.VaultAuthWaiter
+	vaultClient *vaultapi.Client
+}
+
+func New(config Config) (*Reader, error) {
+	if config.VaultClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
+	}
+
+	r := &Reader{
+		authWaiter:  config.AuthWaiter,
+		vaultClient: config.VaultClient,
+	}
+
+	return r, nil
+}
+
+// Read returns the value of the key of the secret under path. Non-string
+// values are returned JSON encoded.
+func (r *Reader) Read(ctx context.Context, path, key string) (string, error) {
+	if r.authWaiter != nil {
+		err := r.authWaiter.Wait(ctx)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+	}
+
+	path = strings.Trim(path, "/")
+
+	httpReq := r.vaultClient.NewRequest("GET", "/v1/"+path)
+	httpResp, err := r.vaultClient.RawRequestWithContext(ctx, httpReq)
+	if httpResp != nil {
+		defer httpResp.Body.Close()
+	}
+	if httpResp != nil && httpResp.StatusCode == http.StatusNotFound {
+		return "", microerror.Maskf(notFoundError, "secret %#q", path)
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return "", microerror.Maskf(executionFailedError, "expected status code = 200, got %d", httpResp.StatusCode)
+	}
+
+	resp := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	err = httpResp.DecodeJSON(&resp)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	data := resp.Data
+	if isKVv2Data(data) {
+		data = data["data"].(map[string]interface{})
+	}
+
+	value, ok := data[key]
+	if !ok || value == nil {
+		return "", microerror.Maskf(notFoundError, "key %#q of secret %#q", key, path)
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(bytes), nil
+}
+
+// isKVv2Data returns true when data is a response of KV version 2 secrets
+// engine, which nests the secret data together with its metadata.
+func isKVv2Data(data map[string]interface{}) bool {
+	if len(data) != 2 {
+		return false
+	}
+
+	_, ok := data["data"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = data["metadata"].(map[string]interface{})
+
+	return ok
+}
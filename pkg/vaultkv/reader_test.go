@@ -0,0 +1,125 @@
+package vaultkv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestReader_Read(t *testing.T) {
+	secrets := map[string]interface{}{
+		"/v1/kv/my-app": map[string]interface{}{
+			"password": "p4ss",
+			"port":     5432,
+		},
+		"/v1/secret/data/my-app": map[string]interface{}{
+			"data": map[string]interface{}{
+				"password": "p4ss-v2",
+			},
+			"metadata": map[string]interface{}{
+				"version": 3,
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	c := vaultapi.DefaultConfig()
+	c.Address = server.URL
+	vaultClient, err := vaultapi.NewClient(c)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+	vaultClient.SetToken("token")
+
+	r, err := New(Config{VaultClient: vaultClient})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", microerror.Pretty(err, true), nil)
+	}
+
+	testCases := []struct {
+		name string
+		path string
+		key  string
+
+		expectedValue string
+		errorMatcher  func(error) bool
+	}{
+		{
+			name: "case 0: KV version 1",
+			path: "kv/my-app",
+			key:  "password",
+
+			expectedValue: "p4ss",
+		},
+		{
+			name: "case 1: KV version 2",
+			path: "/secret/data/my-app/",
+			key:  "password",
+
+			expectedValue: "p4ss-v2",
+		},
+		{
+			name: "case 2: non-string value",
+			path: "kv/my-app",
+			key:  "port",
+
+			expectedValue: "5432",
+		},
+		{
+			name: "case 3: missing key",
+			path: "kv/my-app",
+			key:  "username",
+
+			errorMatcher: IsNotFound,
+		},
+		{
+			name: "case 4: missing secret",
+			path: "kv/other-app",
+			key:  "password",
+
+			errorMatcher: IsNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Log(tc.name)
+
+			value, err := r.Read(context.Background(), tc.path, tc.key)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if value != tc.expectedValue {
+				t.Fatalf("value = %q, want %q", value, tc.expectedValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package versionspec
+
+import "github.com/giantswarm/microerror"
+
+var invalidSpecError = &microerror.Error{
+	Kind: "invalidSpecError",
+}
+
+// IsInvalidSpec asserts invalidSpecError.
+func IsInvalidSpec(err error) bool {
+	return microerror.Cause(err) == invalidSpecError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
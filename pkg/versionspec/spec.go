@@ -0,0 +1,225 @@
+// Package versionspec interprets config versions. A config version is
+// either a branch name or a constraint for version tags ("v<semver>") of
+// the config repository:
+//
+//	2.3.1             exact version
+//	2.x.x             major version range
+//	2.3.x             minor version range
+//	~2.3, ^2.3        tilde and caret ranges
+//	>=2.3.0 <3.0.0    constraint expression
+//	3.0.0-rc.x        prereleases of the version with the given identifier
+//
+// Prerelease tags match only constraints which opt in with a prerelease
+// version, e.g. "3.0.0-rc.x" or ">=3.0.0-rc.1".
+//
+// Config versions which are not valid constraints, e.g. "100.not-x.x",
+// are branch names. Constraints which are also branch names, e.g.
+// "123-fix" or "2024.10-hotfix", fall back to the branch when no tag
+// satisfies them.
+package versionspec
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/giantswarm/microerror"
+)
+
+const tagPrefix = "v"
+
+var (
+	// constraintPattern matches config versions which may be
+	// constraints. Other config versions are branch names.
+	constraintPattern = regexp.MustCompile(`^(\d+([.\s-]|$)|[=<>~^!])`)
+	// branchNamePattern matches config versions which may be branch
+	// names.
+	branchNamePattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._/-]*$`)
+	// branchFallbackPattern matches branch names which are also valid
+	// constraints, e.g. "123-fix". Versions made of numbers and wildcards
+	// only, e.g. "2.x.x", are never branches.
+	branchFallbackPattern = regexp.MustCompile(`[A-WYZa-wyz]`)
+	// prereleaseRangePattern matches "<version>-<identifier>.x".
+	prereleaseRangePattern = regexp.MustCompile(`^(\d+\.\d+\.\d+)-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)\.x$`)
+	// singleMajorPattern matches constraints allowing only versions of a
+	// single major version. The submatch is the major version.
+	singleMajorPattern = regexp.MustCompile(`^[=~^]?\s*(\d+)(\.[0-9xX*]+){0,2}$`)
+	// vPrefixPattern matches config versions mistakenly written as tags.
+	vPrefixPattern = regexp.MustCompile(`^v\d+(\.|$)`)
+)
+
+// Spec is a parsed config version.
+type Spec struct {
+	raw string
+
+	// branch is set for branch names and for constraints which are also
+	// branch names.
+	branch      string
+	constraints *semver.Constraints
+	// prereleaseVersion and prereleaseID are set for prerelease ranges
+	// like "3.0.0-rc.x".
+	prereleaseVersion *semver.Version
+	prereleaseID      string
+}
+
+// Parse parses the config version. It returns an error matched by
+// IsInvalidSpec when the config version looks like a constraint but is
+// neither a valid one nor a branch name.
+func Parse(configVersion string) (*Spec, error) {
+	raw := strings.TrimSpace(configVersion)
+
+	if vPrefixPattern.MatchString(raw) {
+		return nil, microerror.Maskf(invalidSpecError, "configuration version for a tag range should not start with %#q prefix got %q", tagPrefix, configVersion)
+	}
+
+	// fallbackBranch is set for constraints which are also branch names.
+	var fallbackBranch string
+	if branchNamePattern.MatchString(raw) && branchFallbackPattern.MatchString(raw) {
+		fallbackBranch = raw
+	}
+
+	if !constraintPattern.MatchString(raw) {
+		s := &Spec{
+			raw:    raw,
+			branch: raw,
+		}
+
+		return s, nil
+	}
+
+	if subs := prereleaseRangePattern.FindStringSubmatch(raw); len(subs) == 3 {
+		v, err := semver.StrictNewVersion(subs[1])
+		if err != nil {
+			return nil, microerror.Maskf(invalidSpecError, "configuration version %q: %s", configVersion, err)
+		}
+
+		s := &Spec{
+			raw:               raw,
+			branch:            fallbackBranch,
+			prereleaseVersion: v,
+			prereleaseID:      subs[2],
+		}
+
+		return s, nil
+	}
+
+	c, err := semver.NewConstraint(raw)
+	if err != nil && branchNamePattern.MatchString(raw) {
+		s := &Spec{
+			raw:    raw,
+			branch: raw,
+		}
+
+		return s, nil
+	} else if err != nil {
+		return nil, microerror.Maskf(invalidSpecError, "configuration version %q: %s", configVersion, err)
+	}
+
+	s := &Spec{
+		raw:         raw,
+		branch:      fallbackBranch,
+		constraints: c,
+	}
+
+	return s, nil
+}
+
+// IsBranch returns true when the config version is a branch name.
+func (s *Spec) IsBranch() bool {
+	return s.constraints == nil && s.prereleaseVersion == nil
+}
+
+// Branch returns the branch name. For constraints which are also branch
+// names, e.g. "123-fix", it returns the branch to use when no tag satisfies
+// the constraint. It is empty for other constraints.
+func (s *Spec) Branch() string {
+	return s.branch
+}
+
+func (s *Spec) String() string {
+	return s.raw
+}
+
+// Matches returns true when the tag satisfies the constraint. It is always
+// false for branches and for tags which are not versions.
+func (s *Spec) Matches(tag string) bool {
+	v, ok := parseTag(tag)
+	if !ok {
+		return false
+	}
+
+	return s.matches(v)
+}
+
+// Latest returns the highest version tag satisfying the constraint. It
+// returns an error matched by IsNotFound when no tag satisfies it.
+func (s *Spec) Latest(tags []string) (string, error) {
+	var latest string
+	var latestVersion *semver.Version
+	for _, t := range tags {
+		v, ok := parseTag(t)
+		if !ok || !s.matches(v) {
+			continue
+		}
+
+		if latestVersion == nil || v.GreaterThan(latestVersion) {
+			latest = t
+			latestVersion = v
+		}
+	}
+
+	if latestVersion == nil {
+		return "", microerror.Maskf(notFoundError, "no tag satisfies %#q", s.raw)
+	}
+
+	return latest, nil
+}
+
+// TagFilter returns a prefix all tags satisfying the constraint share,
+// e.g. "v2." for "2.3.x". It is meant to narrow down tags listed from
+// the repository and is empty when there is no such prefix.
+func (s *Spec) TagFilter() string {
+	if s.prereleaseVersion != nil {
+		return tagPrefix + s.prereleaseVersion.String() + "-" + s.prereleaseID + "."
+	}
+	if subs := singleMajorPattern.FindStringSubmatch(s.raw); s.constraints != nil && len(subs) == 3 {
+		return tagPrefix + subs[1] + "."
+	}
+
+	return ""
+}
+
+func (s *Spec) matches(v *semver.Version) bool {
+	switch {
+	case s.prereleaseVersion != nil:
+		if v.Major() != s.prereleaseVersion.Major() || v.Minor() != s.prereleaseVersion.Minor() || v.Patch() != s.prereleaseVersion.Patch() {
+			return false
+		}
+		n := strings.TrimPrefix(v.Prerelease(), s.prereleaseID+".")
+		if n == v.Prerelease() {
+			return false
+		}
+		_, err := strconv.ParseUint(n, 10, 64)
+		return err == nil
+	case s.constraints != nil:
+		return s.constraints.Check(v)
+	default:
+		return false
+	}
+}
+
+// parseTag parses tags in format "v<semver>", e.g. "v1.2.3" or
+// "v3.0.0-rc.1".
+func parseTag(tag string) (*semver.Version, bool) {
+	if !strings.HasPrefix(tag, tagPrefix) {
+		return nil, false
+	}
+
+	v, err := semver.StrictNewVersion(strings.TrimPrefix(tag, tagPrefix))
+	if err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,271 @@
+package versionspec
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_Parse(t *testing.T) {
+	testCases := []struct {
+		name              string
+		configVersion     string
+		expectedIsBranch  bool
+		expectedBranch    string
+		expectedTagFilter string
+		errorMatcher      func(err error) bool
+	}{
+		{
+			name:              "case 0: major range",
+			configVersion:     "3.x.x",
+			expectedTagFilter: "v3.",
+		},
+		{
+			name:             "case 1: branch",
+			configVersion:    "my-branch",
+			expectedIsBranch: true,
+			expectedBranch:   "my-branch",
+		},
+		{
+			name:             "case 2: empty string",
+			configVersion:    "",
+			expectedIsBranch: true,
+		},
+		{
+			name:             "case 3: branch starting with number but no dot",
+			configVersion:    "3abc",
+			expectedIsBranch: true,
+			expectedBranch:   "3abc",
+		},
+		{
+			name:             "case 4: branch starting with v and number but no dot",
+			configVersion:    "v3abc",
+			expectedIsBranch: true,
+			expectedBranch:   "v3abc",
+		},
+		{
+			name:          "case 5: invalid - starts with v, number and dot",
+			configVersion: "v3.x.x",
+			errorMatcher:  IsInvalidSpec,
+		},
+		{
+			name:          "case 6: invalid - starts with v, number and dot",
+			configVersion: "v3.abc",
+			errorMatcher:  IsInvalidSpec,
+		},
+		{
+			name:              "case 7: minor range",
+			configVersion:     "1.2.x",
+			expectedTagFilter: "v1.",
+		},
+		{
+			name:              "case 8: exact version",
+			configVersion:     "1.2.3",
+			expectedTagFilter: "v1.",
+		},
+		{
+			name:             "case 9: branch starting with number and dot",
+			configVersion:    "100.not-x.x",
+			expectedIsBranch: true,
+			expectedBranch:   "100.not-x.x",
+		},
+		{
+			name:              "case 10: constraint expression",
+			configVersion:     ">=2.3.0 <3.0.0",
+			expectedTagFilter: "",
+		},
+		{
+			name:              "case 11: tilde range",
+			configVersion:     "~2.3",
+			expectedTagFilter: "v2.",
+		},
+		{
+			name:              "case 12: prerelease range",
+			configVersion:     "3.0.0-rc.x",
+			expectedBranch:    "3.0.0-rc.x",
+			expectedTagFilter: "v3.0.0-rc.",
+		},
+		{
+			name:          "case 13: invalid - v prefixed major",
+			configVersion: "v2",
+			errorMatcher:  IsInvalidSpec,
+		},
+		{
+			name:              "case 14: constraint falling back to branch",
+			configVersion:     "123-fix",
+			expectedBranch:    "123-fix",
+			expectedTagFilter: "",
+		},
+		{
+			name:              "case 15: constraint with minor falling back to branch",
+			configVersion:     "2024.10-hotfix",
+			expectedBranch:    "2024.10-hotfix",
+			expectedTagFilter: "",
+		},
+		{
+			name:          "case 16: invalid - constraint expression",
+			configVersion: ">=2.x.y",
+			errorMatcher:  IsInvalidSpec,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			spec, err := Parse(tc.configVersion)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", microerror.Pretty(err, true))
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if spec.IsBranch() != tc.expectedIsBranch {
+				t.Fatalf("IsBranch() = %t, want %t", spec.IsBranch(), tc.expectedIsBranch)
+			}
+			if spec.Branch() != tc.expectedBranch {
+				t.Fatalf("Branch() = %q, want %q", spec.Branch(), tc.expectedBranch)
+			}
+			if spec.TagFilter() != tc.expectedTagFilter {
+				t.Fatalf("TagFilter() = %q, want %q", spec.TagFilter(), tc.expectedTagFilter)
+			}
+		})
+	}
+}
+
+func TestSpec_Latest(t *testing.T) {
+	tags := []string{
+		"non-version-tag",
+		"v1.5.500",
+		"v2.3.0",
+		"v2.3.1",
+		"v2.4.0",
+		"v2.10.3",
+		"v3.0.0-beta.1",
+		"v3.0.0-rc.1",
+		"v3.0.0-rc.2",
+		"v3.0.0-rc.10",
+		"3.1.0",
+	}
+
+	testCases := []struct {
+		name          string
+		configVersion string
+		tags          []string
+		expectedTag   string
+		errorMatcher  func(err error) bool
+	}{
+		{
+			name:          "case 0: no tags",
+			configVersion: "1.x.x",
+			tags:          []string{},
+			errorMatcher:  IsNotFound,
+		},
+		{
+			name:          "case 1: ignore pre-releases",
+			configVersion: "1.x.x",
+			tags:          []string{"v1.0.0-pre-release"},
+			errorMatcher:  IsNotFound,
+		},
+		{
+			name:          "case 2: ignore pre-releases",
+			configVersion: ">=1.0.0",
+			tags:          []string{"v2.0.0-pre-release", "v1.0.0"},
+			expectedTag:   "v1.0.0",
+		},
+		{
+			name:          "case 3: major range",
+			configVersion: "2.x.x",
+			expectedTag:   "v2.10.3",
+		},
+		{
+			name:          "case 4: patch",
+			configVersion: "2.10.x",
+			tags:          []string{"non-version-tag", "v2.10.300", "v1.5.500", "v2.10.370", "v2.10.372", "v2.10.301", "v2.10.360"},
+			expectedTag:   "v2.10.372",
+		},
+		{
+			name:          "case 5: exact version",
+			configVersion: "2.3.1",
+			expectedTag:   "v2.3.1",
+		},
+		{
+			name:          "case 6: minor range",
+			configVersion: "2.3.x",
+			expectedTag:   "v2.3.1",
+		},
+		{
+			name:          "case 7: tilde range",
+			configVersion: "~2.3",
+			expectedTag:   "v2.3.1",
+		},
+		{
+			name:          "case 8: constraint expression",
+			configVersion: ">=2.3.0 <2.10.0",
+			expectedTag:   "v2.4.0",
+		},
+		{
+			name:          "case 9: prerelease range",
+			configVersion: "3.0.0-rc.x",
+			expectedTag:   "v3.0.0-rc.10",
+		},
+		{
+			name:          "case 10: prereleases not opted in",
+			configVersion: "3.x.x",
+			errorMatcher:  IsNotFound,
+		},
+		{
+			name:          "case 11: exact version not found",
+			configVersion: "2.3.2",
+			errorMatcher:  IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			spec, err := Parse(tc.configVersion)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", microerror.Pretty(err, true))
+			}
+
+			// Cases without tags use the shared list.
+			if tc.tags == nil {
+				tc.tags = tags
+			}
+
+			tag, err := spec.Latest(tc.tags)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", microerror.Pretty(err, true))
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tag != tc.expectedTag {
+				t.Fatalf("tag = %q, want %q", tag, tc.expectedTag)
+			}
+
+			if tc.expectedTag != "" && !spec.Matches(tc.expectedTag) {
+				t.Fatalf("Matches(%q) = false, want true", tc.expectedTag)
+			}
+		})
+	}
+}
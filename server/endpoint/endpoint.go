@@ -6,6 +6,7 @@ import (
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 
+	"github.com/giantswarm/config-controller/server/endpoint/webhook"
 	"github.com/giantswarm/config-controller/service"
 )
 
@@ -17,6 +18,8 @@ type Config struct {
 type Endpoint struct {
 	Healthz *healthz.Endpoint
 	Version *version.Endpoint
+	// Webhook is nil when the webhook is disabled.
+	Webhook *webhook.Endpoint
 }
 
 func New(config Config) (*Endpoint, error) {
@@ -47,9 +50,23 @@ func New(config Config) (*Endpoint, error) {
 		}
 	}
 
+	var webhookEndpoint *webhook.Endpoint
+	if config.Service.Webhook != nil {
+		c := webhook.Config{
+			Logger:  config.Logger,
+			Service: config.Service.Webhook,
+		}
+
+		webhookEndpoint, err = webhook.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	e := &Endpoint{
 		Healthz: healthzEndpoint,
 		Version: versionEndpoint,
+		Webhook: webhookEndpoint,
 	}
 
 	return e, nil
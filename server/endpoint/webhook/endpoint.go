@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/giantswarm/config-controller/pkg/pushevent"
+	"github.com/giantswarm/config-controller/service/webhook"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "POST"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "webhook"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/webhook"
+)
+
+type Config struct {
+	Logger  micrologger.Logger
+	Service *webhook.Service
+}
+
+// Endpoint receives GitHub and Gitea push webhooks of the config
+// repository.
+type Endpoint struct {
+	logger  micrologger.Logger
+	service *webhook.Service
+}
+
+func New(config Config) (*Endpoint, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Service must not be empty", config)
+	}
+
+	e := &Endpoint{
+		logger:  config.Logger,
+		service: config.Service,
+	}
+
+	return e, nil
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		event, err := e.service.ParseEvent(r)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return event, nil
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		event, ok := request.(*pushevent.Event)
+		if !ok {
+			return nil, microerror.Maskf(wrongTypeError, "expected %T, got %T", event, request)
+		}
+
+		// Events other than pushes, e.g. pings, are acknowledged
+		// without any action.
+		if event == nil {
+			return webhook.HandleResult{Ignored: true}, nil
+		}
+
+		result, err := e.service.Handle(ctx, *event)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return result, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/giantswarm/config-controller/pkg/project"
+	"github.com/giantswarm/config-controller/pkg/pushevent"
 	"github.com/giantswarm/config-controller/server/endpoint"
 	"github.com/giantswarm/config-controller/service"
 )
@@ -40,6 +41,14 @@ func New(config Config) (microserver.Server, error) {
 		}
 	}
 
+	endpoints := []microserver.Endpoint{
+		endpointCollection.Healthz,
+		endpointCollection.Version,
+	}
+	if endpointCollection.Webhook != nil {
+		endpoints = append(endpoints, endpointCollection.Webhook)
+	}
+
 	s := &server{
 		logger: config.Logger,
 
@@ -49,10 +58,7 @@ func New(config Config) (microserver.Server, error) {
 			ServiceName: project.Name(),
 			Viper:       config.Viper,
 
-			Endpoints: []microserver.Endpoint{
-				endpointCollection.Healthz,
-				endpointCollection.Version,
-			},
+			Endpoints:    endpoints,
 			ErrorEncoder: encodeError,
 		},
 		shutdownOnce: sync.Once{},
@@ -91,7 +97,17 @@ func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	rErr := err.(microserver.ResponseError)
 	uErr := rErr.Underlying()
 
-	rErr.SetCode(microserver.CodeInternalError)
 	rErr.SetMessage(uErr.Error())
-	w.WriteHeader(http.StatusInternalServerError)
+
+	switch {
+	case pushevent.IsInvalidSignature(uErr):
+		rErr.SetCode(microserver.CodePermissionDenied)
+		w.WriteHeader(http.StatusUnauthorized)
+	case pushevent.IsInvalidPayload(uErr):
+		rErr.SetCode(microserver.CodeInvalidInput)
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		rErr.SetCode(microserver.CodeInternalError)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 }
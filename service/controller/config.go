@@ -12,7 +12,9 @@ import (
 
 	vaultapi "github.com/hashicorp/vault/api"
 
+	"github.com/giantswarm/config-controller/internal/generator/github/cache"
 	"github.com/giantswarm/config-controller/internal/meta"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
 	"github.com/giantswarm/config-controller/pkg/project"
 	"github.com/giantswarm/config-controller/service/controller/handler/configuration"
 )
@@ -21,10 +23,30 @@ type ConfigConfig struct {
 	K8sClient   k8sclient.Interface
 	Logger      micrologger.Logger
 	VaultClient *vaultapi.Client
-
-	GitHubToken  string
-	Installation string
-	UniqueApp    bool
+	// VaultAuthWaiter is optional.
+	VaultAuthWaiter   decrypt.VaultAuthWaiter
+	VaultKeyName      string
+	VaultTransitMount string
+
+	AgeKeyFile        string
+	DecryptionBackend string
+	// DecryptionCache is optional. See generator.Config for details.
+	DecryptionCache *decrypt.Cache
+
+	GitHubAppID             int64
+	GitHubAppInstallationID int64
+	GitHubAppPrivateKeyFile string
+	GitHubToken             string
+	Installation            string
+	RepositoryPassword      string
+	RepositoryUsername      string
+	RepositoryCacheDir      string
+	RepositoryCacheMaxSize  int64
+	RepositoryFilesCache    *cache.Repository
+	RepositoryTagCache      *cache.Tag
+	RepositoryURL           string
+	SecretRefAllowed        []string
+	UniqueApp               bool
 }
 
 type Config struct {
@@ -76,12 +98,30 @@ func newConfigHandlers(config ConfigConfig) ([]resource.Interface, error) {
 		c := configuration.Config{
 			Logger: config.Logger,
 
-			K8sClient:   config.K8sClient,
-			VaultClient: config.VaultClient,
-
-			GitHubToken:  config.GitHubToken,
-			Installation: config.Installation,
-			UniqueApp:    config.UniqueApp,
+			K8sClient:         config.K8sClient,
+			VaultClient:       config.VaultClient,
+			VaultAuthWaiter:   config.VaultAuthWaiter,
+			VaultKeyName:      config.VaultKeyName,
+			VaultTransitMount: config.VaultTransitMount,
+
+			AgeKeyFile:        config.AgeKeyFile,
+			DecryptionBackend: config.DecryptionBackend,
+			DecryptionCache:   config.DecryptionCache,
+
+			GitHubAppID:             config.GitHubAppID,
+			GitHubAppInstallationID: config.GitHubAppInstallationID,
+			GitHubAppPrivateKeyFile: config.GitHubAppPrivateKeyFile,
+			GitHubToken:             config.GitHubToken,
+			Installation:            config.Installation,
+			RepositoryPassword:      config.RepositoryPassword,
+			RepositoryUsername:      config.RepositoryUsername,
+			RepositoryCacheDir:      config.RepositoryCacheDir,
+			RepositoryCacheMaxSize:  config.RepositoryCacheMaxSize,
+			RepositoryFilesCache:    config.RepositoryFilesCache,
+			RepositoryTagCache:      config.RepositoryTagCache,
+			RepositoryURL:           config.RepositoryURL,
+			SecretRefAllowed:        config.SecretRefAllowed,
+			UniqueApp:               config.UniqueApp,
 		}
 
 		configurationHandler, err = configuration.New(c)
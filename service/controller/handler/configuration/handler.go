@@ -13,9 +13,14 @@ import (
 
 	"github.com/giantswarm/config-controller/internal/configversion"
 	"github.com/giantswarm/config-controller/internal/generator"
+	"github.com/giantswarm/config-controller/internal/generator/github/cache"
 	"github.com/giantswarm/config-controller/internal/meta"
 
+	"github.com/giantswarm/config-controller/pkg/decrypt"
+	"github.com/giantswarm/config-controller/pkg/generator/tracer"
+	"github.com/giantswarm/config-controller/pkg/github"
 	"github.com/giantswarm/config-controller/pkg/k8sresource"
+	"github.com/giantswarm/config-controller/pkg/secretref"
 )
 
 const (
@@ -27,10 +32,46 @@ type Config struct {
 
 	K8sClient   k8sclient.Interface
 	VaultClient *vaultapi.Client
-
+	// VaultAuthWaiter is optional.
+	VaultAuthWaiter   decrypt.VaultAuthWaiter
+	VaultKeyName      string
+	VaultTransitMount string
+
+	AgeKeyFile        string
+	DecryptionBackend string
+	// DecryptionCache is optional. See generator.Config for details.
+	DecryptionCache *decrypt.Cache
+
+	// GitHubAppID, GitHubAppInstallationID and GitHubAppPrivateKeyFile
+	// configure authentication as a GitHub App. See generator.Config for
+	// details.
+	GitHubAppID             int64
+	GitHubAppInstallationID int64
+	GitHubAppPrivateKeyFile string
+	// GitHubToken or GitHub App is required when RepositoryURL is a GitHub
+	// repository.
 	GitHubToken  string
 	Installation string
-	UniqueApp    bool
+	// RepositoryURL is the URL of the config repository. Defaults to the
+	// giantswarm/config GitHub repository.
+	RepositoryURL string
+	// RepositoryUsername and RepositoryPassword are optional credentials
+	// of repositories not hosted on GitHub. See generator.Config for
+	// details.
+	RepositoryPassword string
+	RepositoryUsername string
+	// RepositoryCacheDir and RepositoryCacheMaxSize are optional. See
+	// generator.Config for details.
+	RepositoryCacheDir     string
+	RepositoryCacheMaxSize int64
+	// RepositoryFilesCache and RepositoryTagCache are optional. They are
+	// shared with the webhook service which invalidates their entries.
+	RepositoryFilesCache *cache.Repository
+	RepositoryTagCache   *cache.Tag
+	// SecretRefAllowed lists Kubernetes Secrets which may be referenced
+	// by secretRef objects. See secretref.Config.Allowed for details.
+	SecretRefAllowed []string
+	UniqueApp        bool
 }
 
 type Handler struct {
@@ -57,8 +98,11 @@ func New(config Config) (*Handler, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", config)
 	}
 
-	if config.GitHubToken == "" {
-		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken must not be empty", config)
+	if config.RepositoryURL == "" {
+		config.RepositoryURL = github.DefaultURL
+	}
+	if config.GitHubToken == "" && config.GitHubAppID == 0 && github.IsGitHubURL(config.RepositoryURL) {
+		return nil, microerror.Maskf(invalidConfigError, "%T.GitHubToken or %T.GitHubAppID must not be empty when %T.RepositoryURL is a GitHub repository", config, config, config)
 	}
 	if config.Installation == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Installation must not be empty", config)
@@ -78,13 +122,58 @@ func New(config Config) (*Handler, error) {
 		}
 	}
 
+	var genTracer *tracer.Logger
+	{
+		c := tracer.LoggerConfig{
+			Logger: config.Logger,
+		}
+
+		genTracer, err = tracer.NewLogger(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var secretRefResolver *secretref.Resolver
+	{
+		c := secretref.Config{
+			K8sClient: config.K8sClient.K8sClient(),
+			Allowed:   config.SecretRefAllowed,
+		}
+
+		secretRefResolver, err = secretref.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var gen *generator.Service
 	{
 		c := generator.Config{
-			VaultClient: config.VaultClient,
-
-			GitHubToken:  config.GitHubToken,
-			Installation: config.Installation,
+			VaultClient:       config.VaultClient,
+			VaultAuthWaiter:   config.VaultAuthWaiter,
+			VaultKeyName:      config.VaultKeyName,
+			VaultTransitMount: config.VaultTransitMount,
+
+			AgeKeyFile:        config.AgeKeyFile,
+			DecryptionBackend: config.DecryptionBackend,
+			DecryptionCache:   config.DecryptionCache,
+
+			GitHubAppID:             config.GitHubAppID,
+			GitHubAppInstallationID: config.GitHubAppInstallationID,
+			GitHubAppPrivateKeyFile: config.GitHubAppPrivateKeyFile,
+			GitHubToken:             config.GitHubToken,
+			Installation:            config.Installation,
+			RepositoryURL:           config.RepositoryURL,
+			SecretRefResolver:       secretRefResolver,
+			Tracer:                  genTracer,
+
+			RepositoryPassword:     config.RepositoryPassword,
+			RepositoryUsername:     config.RepositoryUsername,
+			RepositoryCacheDir:     config.RepositoryCacheDir,
+			RepositoryCacheMaxSize: config.RepositoryCacheMaxSize,
+			RepositoryFilesCache:   config.RepositoryFilesCache,
+			RepositoryTagCache:     config.RepositoryTagCache,
 		}
 
 		gen, err = generator.New(c)
@@ -1,8 +1,6 @@
 package key
 
 import (
-	"regexp"
-
 	applicationv1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/application/v1alpha1"
 	corev1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/core/v1alpha1"
 	"github.com/giantswarm/microerror"
@@ -16,10 +14,6 @@ const (
 	ObjectHashAnnotation = "config-controller.giantswarm.io/object-hash"
 )
 
-var (
-	tagConfigVersionPattern = regexp.MustCompile(`^(\d+)\.x\.x$`)
-)
-
 func ToAppCR(v interface{}) (applicationv1alpha1.App, error) {
 	if v == nil {
 		return applicationv1alpha1.App{}, microerror.Maskf(wrongTypeError, "expected non-nil, got %#v", v)
@@ -48,16 +42,6 @@ func ToConfigCR(v interface{}) (*corev1alpha1.Config, error) {
 	return p.DeepCopy(), nil
 }
 
-// TryVersionToTag translates config version: "<major>.x.x" to tagReference:
-// "v<major>" if possible. Otherwise returns empty string.
-func TryVersionToTag(version string) string {
-	matches := tagConfigVersionPattern.FindAllStringSubmatch(version, -1)
-	if len(matches) > 0 {
-		return "v" + matches[0][1]
-	}
-	return ""
-}
-
 func RemoveAnnotation(annotations map[string]string, key string) map[string]string {
 	if annotations == nil {
 		return nil
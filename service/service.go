@@ -18,9 +18,14 @@ import (
 	"k8s.io/client-go/rest"
 
 	"github.com/giantswarm/config-controller/flag"
+	"github.com/giantswarm/config-controller/internal/generator"
+	"github.com/giantswarm/config-controller/internal/generator/github/cache"
+	"github.com/giantswarm/config-controller/pkg/decrypt"
 	"github.com/giantswarm/config-controller/pkg/project"
+	"github.com/giantswarm/config-controller/pkg/vaultauth"
 	"github.com/giantswarm/config-controller/service/collector"
 	"github.com/giantswarm/config-controller/service/controller"
+	"github.com/giantswarm/config-controller/service/webhook"
 )
 
 // Config represents the configuration used to create a new service.
@@ -33,10 +38,13 @@ type Config struct {
 
 type Service struct {
 	Version *version.Service
+	// Webhook is nil when the webhook secret is not configured.
+	Webhook *webhook.Service
 
 	bootOnce          sync.Once
 	configController  *controller.Config
 	operatorCollector *collector.Set
+	vaultAuth         *vaultauth.Authenticator
 }
 
 // New creates a new configured service object.
@@ -108,19 +116,91 @@ func New(config Config) (*Service, error) {
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-		vaultClient.SetToken(config.Viper.GetString(config.Flag.Service.Vault.Token))
 	}
 
+	// Vault is used only by the vault decryption backend.
+	var vaultAuth *vaultauth.Authenticator
+	var vaultAuthWaiter decrypt.VaultAuthWaiter
+	if config.Viper.GetString(config.Flag.Service.Decryption.Backend) != generator.DecryptionBackendAge {
+		c := vaultauth.Config{
+			Logger:      config.Logger,
+			VaultClient: vaultClient,
+
+			Method: config.Viper.GetString(config.Flag.Service.Vault.Auth.Method),
+
+			Token: config.Viper.GetString(config.Flag.Service.Vault.Token),
+
+			KubernetesJWTFile:   config.Viper.GetString(config.Flag.Service.Vault.Auth.Kubernetes.JWTFile),
+			KubernetesMountPath: config.Viper.GetString(config.Flag.Service.Vault.Auth.Kubernetes.MountPath),
+			KubernetesRole:      config.Viper.GetString(config.Flag.Service.Vault.Auth.Kubernetes.Role),
+
+			AppRoleMountPath: config.Viper.GetString(config.Flag.Service.Vault.Auth.AppRole.MountPath),
+			AppRoleRoleID:    config.Viper.GetString(config.Flag.Service.Vault.Auth.AppRole.RoleID),
+			AppRoleSecretID:  config.Viper.GetString(config.Flag.Service.Vault.Auth.AppRole.SecretID),
+		}
+
+		vaultAuth, err = vaultauth.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = vaultAuth.Login(context.Background())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		vaultAuthWaiter = vaultAuth
+	}
+
+	// decryptionCache is shared by all controller handlers so every
+	// ciphertext is decrypted only once per TTL.
+	var decryptionCache *decrypt.Cache
+	{
+		c := decrypt.CacheConfig{
+			MaxEntries: config.Viper.GetInt(config.Flag.Service.Decryption.Cache.MaxEntries),
+			TTL:        config.Viper.GetDuration(config.Flag.Service.Decryption.Cache.TTL),
+		}
+
+		decryptionCache, err = decrypt.NewCache(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// Caches of the config repository are shared by the controller and
+	// the webhook service which invalidates them when the repository
+	// changes.
+	repositoryFilesCache := cache.NewRepository()
+	repositoryTagCache := cache.NewTag()
+
 	var configController *controller.Config
 	{
 		c := controller.ConfigConfig{
 			K8sClient:   k8sClient,
 			Logger:      config.Logger,
 			VaultClient: vaultClient,
+			// VaultAuthWaiter is nil when Vault is not used.
+			VaultAuthWaiter:   vaultAuthWaiter,
+			VaultKeyName:      config.Viper.GetString(config.Flag.Service.Vault.Transit.KeyName),
+			VaultTransitMount: config.Viper.GetString(config.Flag.Service.Vault.Transit.Mount),
+
+			AgeKeyFile:        config.Viper.GetString(config.Flag.Service.Decryption.AgeKeyFile),
+			DecryptionBackend: config.Viper.GetString(config.Flag.Service.Decryption.Backend),
+			DecryptionCache:   decryptionCache,
 
-			GitHubToken:  config.Viper.GetString(config.Flag.Service.GitHub.Token),
-			Installation: config.Viper.GetString(config.Flag.Service.Installation.Name),
-			UniqueApp:    config.Viper.GetBool(config.Flag.Service.App.Unique),
+			GitHubAppID:             config.Viper.GetInt64(config.Flag.Service.GitHub.App.ID),
+			GitHubAppInstallationID: config.Viper.GetInt64(config.Flag.Service.GitHub.App.InstallationID),
+			GitHubAppPrivateKeyFile: config.Viper.GetString(config.Flag.Service.GitHub.App.PrivateKeyFile),
+			GitHubToken:             config.Viper.GetString(config.Flag.Service.GitHub.Token),
+			Installation:            config.Viper.GetString(config.Flag.Service.Installation.Name),
+			RepositoryPassword:      config.Viper.GetString(config.Flag.Service.Repository.Password),
+			RepositoryUsername:      config.Viper.GetString(config.Flag.Service.Repository.Username),
+			RepositoryCacheDir:      config.Viper.GetString(config.Flag.Service.Repository.CacheDir),
+			RepositoryCacheMaxSize:  config.Viper.GetInt64(config.Flag.Service.Repository.CacheMaxSize),
+			RepositoryFilesCache:    repositoryFilesCache,
+			RepositoryTagCache:      repositoryTagCache,
+			RepositoryURL:           config.Viper.GetString(config.Flag.Service.Repository.URL),
+			SecretRefAllowed:        config.Viper.GetStringSlice(config.Flag.Service.SecretRef.Allowed),
+			UniqueApp:               config.Viper.GetBool(config.Flag.Service.App.Unique),
 		}
 
 		configController, err = controller.NewConfig(c)
@@ -129,6 +209,26 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	// Webhook is enabled only when its secret is configured.
+	var webhookService *webhook.Service
+	if config.Viper.GetString(config.Flag.Service.Webhook.Secret) != "" {
+		c := webhook.Config{
+			K8sClient: k8sClient,
+			Logger:    config.Logger,
+
+			RepositoryFilesCache: repositoryFilesCache,
+			RepositoryTagCache:   repositoryTagCache,
+			RepositoryURL:        config.Viper.GetString(config.Flag.Service.Repository.URL),
+			Secret:               config.Viper.GetString(config.Flag.Service.Webhook.Secret),
+			UniqueApp:            config.Viper.GetBool(config.Flag.Service.App.Unique),
+		}
+
+		webhookService, err = webhook.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var operatorCollector *collector.Set
 	{
 		c := collector.SetConfig{
@@ -160,10 +260,12 @@ func New(config Config) (*Service, error) {
 
 	s := &Service{
 		Version: versionService,
+		Webhook: webhookService,
 
 		bootOnce:          sync.Once{},
 		configController:  configController,
 		operatorCollector: operatorCollector,
+		vaultAuth:         vaultAuth,
 	}
 
 	return s, nil
@@ -171,6 +273,10 @@ func New(config Config) (*Service, error) {
 
 func (s *Service) Boot(ctx context.Context) {
 	s.bootOnce.Do(func() {
+		if s.vaultAuth != nil {
+			go s.vaultAuth.Boot(ctx)
+		}
+
 		go s.operatorCollector.Boot(ctx) // nolint:errcheck
 
 		go s.configController.Boot(ctx)
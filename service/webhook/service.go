@@ -0,0 +1,226 @@
+// Package webhook reacts to changes of the config repository reported by
+// push webhooks.
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/core/v1alpha1"
+	"github.com/giantswarm/k8sclient/v5/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/config-controller/internal/generator/github/cache"
+	"github.com/giantswarm/config-controller/internal/meta"
+	"github.com/giantswarm/config-controller/pkg/pushevent"
+	"github.com/giantswarm/config-controller/pkg/versionspec"
+)
+
+type Config struct {
+	K8sClient k8sclient.Interface
+	Logger    micrologger.Logger
+
+	// RepositoryFilesCache and RepositoryTagCache must be the caches used
+	// by the configuration handler.
+	RepositoryFilesCache *cache.Repository
+	RepositoryTagCache   *cache.Tag
+	RepositoryURL        string
+	// Secret is the webhook secret used to verify signatures of
+	// requests.
+	Secret    string
+	UniqueApp bool
+}
+
+type Service struct {
+	parser *pushevent.Parser
+
+	k8sClient k8sclient.Interface
+	logger    micrologger.Logger
+
+	filesCache    *cache.Repository
+	tagCache      *cache.Tag
+	repositoryURL string
+	uniqueApp     bool
+}
+
+func New(config Config) (*Service, error) {
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	if config.RepositoryFilesCache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.RepositoryFilesCache must not be empty", config)
+	}
+	if config.RepositoryTagCache == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.RepositoryTagCache must not be empty", config)
+	}
+	if config.RepositoryURL == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.RepositoryURL must not be empty", config)
+	}
+
+	var err error
+
+	var parser *pushevent.Parser
+	{
+		c := pushevent.Config{
+			Secret: config.Secret,
+		}
+
+		parser, err = pushevent.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	s := &Service{
+		parser: parser,
+
+		k8sClient: config.K8sClient,
+		logger:    config.Logger,
+
+		filesCache:    config.RepositoryFilesCache,
+		tagCache:      config.RepositoryTagCache,
+		repositoryURL: config.RepositoryURL,
+		uniqueApp:     config.UniqueApp,
+	}
+
+	return s, nil
+}
+
+// ParseEvent verifies the signature of the webhook request and returns its
+// event. See pushevent.Parser.Parse for details.
+func (s *Service) ParseEvent(r *http.Request) (*pushevent.Event, error) {
+	event, err := s.parser.Parse(r)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return event, nil
+}
+
+type HandleResult struct {
+	// Ignored is true when the event was sent for another repository or
+	// for a reference which is neither a branch nor a tag.
+	Ignored bool `json:"ignored"`
+	// Requeued contains "<namespace>/<name>" of requeued Config CRs.
+	Requeued []string `json:"requeued"`
+}
+
+// Handle invalidates cached files and tags of the config repository
+// changed by the event and requeues Config CRs with config version
+// matching the changed branch or tag.
+func (s *Service) Handle(ctx context.Context, event pushevent.Event) (HandleResult, error) {
+	if !s.isConfigRepository(event.RepositoryURLs) {
+		s.logger.Debugf(ctx, "ignoring %s event for %#q of repositories %v", event.Provider, event.Ref, event.RepositoryURLs)
+		return HandleResult{Ignored: true}, nil
+	}
+
+	var matches func(spec *versionspec.Spec) bool
+	if branch, ok := event.Branch(); ok {
+		s.filesCache.Delete(ctx, s.filesCache.Key(s.repositoryURL, branch))
+
+		matches = func(spec *versionspec.Spec) bool {
+			return spec.Branch() == branch
+		}
+	} else if tag, ok := event.Tag(); ok {
+		s.filesCache.Delete(ctx, s.filesCache.Key(s.repositoryURL, tag))
+
+		// The latest tag of every config version the new tag
+		// satisfies may change.
+		matches = func(spec *versionspec.Spec) bool {
+			if !spec.Matches(tag) {
+				return false
+			}
+
+			s.tagCache.Delete(ctx, s.tagCache.Key(s.repositoryURL, spec.String()))
+			return true
+		}
+	} else {
+		return HandleResult{Ignored: true}, nil
+	}
+
+	s.logger.Debugf(ctx, "invalidated cache for %#q", event.Ref)
+
+	requeued, err := s.requeue(ctx, matches)
+	if err != nil {
+		return HandleResult{}, microerror.Mask(err)
+	}
+
+	return HandleResult{Requeued: requeued}, nil
+}
+
+// requeue sets the requeue annotation on Config CRs reconciled by this
+// controller with config version in their status matching. Changed CRs are
+// reconciled immediately.
+func (s *Service) requeue(ctx context.Context, matches func(spec *versionspec.Spec) bool) ([]string, error) {
+	var list corev1alpha1.ConfigList
+	err := s.k8sClient.CtrlClient().List(ctx, &list)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	selector := meta.Label.Version.Selector(s.uniqueApp)
+	now := time.Now()
+
+	requeued := []string{}
+	for i := range list.Items {
+		config := &list.Items[i]
+		if !selector.Matches(labels.Set(config.Labels)) {
+			continue
+		}
+
+		// Config versions which can not be parsed fail to reconcile
+		// anyway.
+		spec, err := versionspec.Parse(config.Status.Version)
+		if err != nil || !matches(spec) {
+			continue
+		}
+
+		patch := client.MergeFrom(config.DeepCopy())
+
+		annotations := config.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[meta.Annotation.XRequeuedAt.Key()] = meta.Annotation.XRequeuedAt.Val(now)
+		config.SetAnnotations(annotations)
+
+		err = s.k8sClient.CtrlClient().Patch(ctx, config, patch)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		nn := config.Namespace + "/" + config.Name
+		s.logger.Debugf(ctx, "requeued Config %#q", nn)
+		requeued = append(requeued, nn)
+	}
+
+	return requeued, nil
+}
+
+func (s *Service) isConfigRepository(urls []string) bool {
+	for _, u := range urls {
+		if normalizeURL(u) == normalizeURL(s.repositoryURL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeURL(u string) string {
+	u = strings.TrimSuffix(u, "/")
+	u = strings.TrimSuffix(u, ".git")
+	return strings.ToLower(u)
+}